@@ -0,0 +1,338 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/OkanUysal/go-cache"
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-metrics"
+	"github.com/OkanUysal/go-starter-example-project/auth"
+	"github.com/OkanUysal/go-starter-example-project/config"
+	"github.com/OkanUysal/go-starter-example-project/handlers"
+	"github.com/OkanUysal/go-starter-example-project/health"
+	"github.com/OkanUysal/go-starter-example-project/lifecycle"
+	"github.com/OkanUysal/go-starter-example-project/migrations"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+	"github.com/OkanUysal/go-starter-example-project/websocket"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// Options holds the dependencies and settings an App is built from
+type Options struct {
+	// DB may be nil when both Users and Revocations are given (e.g. in-memory tests);
+	// the migrator and database health checks are then skipped
+	DB     *gorm.DB
+	Cache  *cache.Cache
+	Logger *logger.Logger
+
+	// Replicas serve lag-tolerant reads such as the admin user list (optional)
+	Replicas []*gorm.DB
+
+	// Users, Revocations, RoomStore, DirectMessages and Blocks override the SQL
+	// repositories built from DB (optional; without DB, game rooms, direct
+	// messages and blocks are kept in memory when not given)
+	Users          repository.UserRepository
+	Revocations    repository.RevocationRepository
+	RoomStore      repository.RoomRepository
+	DirectMessages repository.DirectMessageRepository
+	Blocks         repository.BlockRepository
+
+	// Metrics enables the /metrics and /health endpoints and HTTP metrics middleware (optional)
+	Metrics *metrics.Metrics
+
+	// TracerProvider enables tracing of HTTP requests, auth checks, cache and
+	// database calls and WebSocket messages (optional)
+	TracerProvider trace.TracerProvider
+
+	// ServiceName identifies the server in HTTP spans
+	ServiceName string
+
+	// RoomAuthEnabled requires an invitation to join game rooms
+	RoomAuthEnabled bool
+
+	// Backplane shares WebSocket rooms, messages and player counts with the
+	// other replicas (optional: without it the server runs as a single node)
+	Backplane websocket.Backplane
+
+	// ChatHistory stores chat messages (optional: defaults to an in-memory ring
+	// buffer per room), and ChatHistoryOnJoin of them are sent to a user joining a room
+	ChatHistory       repository.MessageRepository
+	ChatHistoryOnJoin int
+
+	// Friends lists who besides a user's rooms hears about their presence
+	// changes (optional), and TypingTimeout is how long a typing indicator
+	// lasts unless renewed (zero: 5s)
+	Friends       websocket.FriendSource
+	TypingTimeout time.Duration
+
+	// ReplayBufferSize is how many recent messages per room are kept for
+	// clients resuming after a reconnect (zero: 256)
+	ReplayBufferSize int
+
+	// MessageRate is how many WebSocket messages per second each user may
+	// send on average (zero: 20), in bursts of up to MessageBurst (zero: 40)
+	MessageRate  int
+	MessageBurst int
+
+	// Compression compresses WebSocket messages of at least
+	// CompressionMinSize bytes (zero: 256) for clients that offer it
+	Compression        bool
+	CompressionMinSize int
+
+	// ConnectionPolicy decides what happens when a user holding
+	// MaxConnections WebSocket connections (zero: 1) opens another one
+	// (default: allow_many, without a limit)
+	ConnectionPolicy websocket.ConnectionPolicy
+	MaxConnections   int
+
+	// DBQueryTimeout and CacheTimeout bound each repository and cache call (zero: no limit)
+	DBQueryTimeout time.Duration
+	CacheTimeout   time.Duration
+
+	// HealthCheckTimeout and HealthCacheTTL configure the readiness checks
+	HealthCheckTimeout time.Duration
+	HealthCacheTTL     time.Duration
+}
+
+// OptionsFromConfig returns options populated from the loaded configuration
+func OptionsFromConfig(db *gorm.DB, c *cache.Cache, log *logger.Logger) Options {
+	return Options{
+		DB:                 db,
+		Cache:              c,
+		Logger:             log,
+		ServiceName:        config.ServiceName,
+		RoomAuthEnabled:    config.RoomAuthEnabled,
+		ChatHistoryOnJoin:  config.WebSocketHistoryOnJoin,
+		TypingTimeout:      config.WebSocketTypingTimeout,
+		ReplayBufferSize:   config.WebSocketReplayBufferSize,
+		MessageRate:        config.WebSocketMessageRate,
+		MessageBurst:       config.WebSocketMessageBurst,
+		Compression:        config.WebSocketCompression,
+		CompressionMinSize: config.WebSocketCompressionMinSize,
+		ConnectionPolicy:   websocket.ConnectionPolicy(config.WebSocketConnectionPolicy),
+		MaxConnections:     config.WebSocketMaxConnections,
+		DBQueryTimeout:     config.DBQueryTimeout,
+		CacheTimeout:       config.CacheTimeout,
+		HealthCheckTimeout: config.HealthCheckTimeout,
+		HealthCacheTTL:     config.HealthCacheTTL,
+	}
+}
+
+// App owns every service of one server instance. Nothing is shared through
+// package globals, so several Apps can run side by side (e.g. in tests).
+type App struct {
+	DB             *gorm.DB
+	Replicas       []*gorm.DB
+	Cache          *config.Cache
+	Logger         *logger.Logger
+	Metrics        *metrics.Metrics
+	TracerProvider trace.TracerProvider
+	ServiceName    string
+
+	Users       repository.UserRepository
+	Revocations repository.RevocationRepository
+
+	Auth      *auth.Service
+	Rooms     *websocket.RoomManager
+	Migrator  *migrations.Migrator
+	Health    *health.Registry
+	Handlers  *handlers.Handler
+	WebSocket *websocket.Handler
+}
+
+// New wires an App from its dependencies
+func New(opts Options) (*App, error) {
+	if opts.Cache == nil || opts.Logger == nil {
+		return nil, errors.New("app: Cache and Logger are required")
+	}
+	if opts.DB == nil && (opts.Users == nil || opts.Revocations == nil) {
+		return nil, errors.New("app: DB is required unless Users and Revocations are given")
+	}
+	if opts.HealthCheckTimeout <= 0 {
+		opts.HealthCheckTimeout = 2 * time.Second
+	}
+
+	var migrator *migrations.Migrator
+	if opts.DB != nil {
+		var err error
+		if migrator, err = migrations.NewMigrator(opts.DB, opts.Logger); err != nil {
+			return nil, err
+		}
+	}
+
+	if (opts.Metrics != nil || opts.TracerProvider != nil) && opts.DB != nil {
+		if err := instrumentDatabases(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Users == nil {
+		opts.Users = repository.NewSQLUserRepository(opts.DB, opts.Replicas...)
+	}
+	if opts.Revocations == nil {
+		opts.Revocations = repository.NewSQLRevocationRepository(opts.DB)
+	}
+	if opts.RoomStore == nil && opts.DB != nil {
+		opts.RoomStore = repository.NewSQLRoomRepository(opts.DB)
+	}
+	if opts.DirectMessages == nil && opts.DB != nil {
+		opts.DirectMessages = repository.NewSQLDirectMessageRepository(opts.DB)
+	}
+	if opts.Blocks == nil && opts.DB != nil {
+		opts.Blocks = repository.NewSQLBlockRepository(opts.DB)
+	}
+	opts.Users = repository.UsersWithTimeout(opts.Users, opts.DBQueryTimeout)
+	opts.Revocations = repository.RevocationsWithTimeout(opts.Revocations, opts.DBQueryTimeout)
+	if opts.RoomStore != nil {
+		opts.RoomStore = repository.RoomsWithTimeout(opts.RoomStore, opts.DBQueryTimeout)
+	}
+	if opts.ChatHistory != nil {
+		opts.ChatHistory = repository.MessagesWithTimeout(opts.ChatHistory, opts.DBQueryTimeout)
+	}
+	if opts.DirectMessages != nil {
+		opts.DirectMessages = repository.DirectMessagesWithTimeout(opts.DirectMessages, opts.DBQueryTimeout)
+	}
+	if opts.Blocks != nil {
+		opts.Blocks = repository.BlocksWithTimeout(opts.Blocks, opts.DBQueryTimeout)
+	}
+
+	c := config.NewCache(opts.Cache, opts.CacheTimeout, opts.TracerProvider)
+	authService := auth.NewService(opts.Users, opts.Revocations, c, opts.Logger, opts.TracerProvider)
+
+	var wsMetrics *websocket.Metrics
+	if opts.Metrics != nil {
+		var err error
+		if wsMetrics, err = websocket.NewMetrics(opts.Metrics.Registry()); err != nil {
+			return nil, fmt.Errorf("failed to register websocket metrics: %w", err)
+		}
+	}
+	if opts.ConnectionPolicy != "" {
+		if _, err := websocket.ParseConnectionPolicy(string(opts.ConnectionPolicy)); err != nil {
+			return nil, err
+		}
+	}
+	rooms := websocket.NewRoomManager(opts.Logger, websocket.ManagerOptions{
+		RoomAuthEnabled:    opts.RoomAuthEnabled,
+		TracerProvider:     opts.TracerProvider,
+		Metrics:            wsMetrics,
+		Backplane:          opts.Backplane,
+		Store:              opts.RoomStore,
+		History:            opts.ChatHistory,
+		HistoryOnJoin:      opts.ChatHistoryOnJoin,
+		DirectMessages:     opts.DirectMessages,
+		Blocks:             opts.Blocks,
+		Friends:            opts.Friends,
+		TypingTimeout:      opts.TypingTimeout,
+		ReplayBufferSize:   opts.ReplayBufferSize,
+		MessageRate:        opts.MessageRate,
+		MessageBurst:       opts.MessageBurst,
+		Compression:        opts.Compression,
+		CompressionMinSize: opts.CompressionMinSize,
+		ConnectionPolicy:   opts.ConnectionPolicy,
+		MaxConnections:     opts.MaxConnections,
+	})
+
+	a := &App{
+		DB:             opts.DB,
+		Replicas:       opts.Replicas,
+		Cache:          c,
+		Logger:         opts.Logger,
+		Metrics:        opts.Metrics,
+		TracerProvider: opts.TracerProvider,
+		ServiceName:    opts.ServiceName,
+		Users:          opts.Users,
+		Revocations:    opts.Revocations,
+		Auth:           authService,
+		Rooms:          rooms,
+		Migrator:       migrator,
+		Health:         health.NewRegistry(opts.HealthCheckTimeout, opts.HealthCacheTTL),
+		Handlers:       handlers.New(authService, opts.Users, c, opts.Logger),
+		WebSocket:      websocket.NewHandler(rooms, authService, opts.Logger),
+	}
+
+	if a.DB != nil {
+		a.Health.Register("database", health.DatabaseCheck(a.DB))
+	}
+	for i, replica := range a.Replicas {
+		a.Health.Register(fmt.Sprintf("database_replica_%d", i+1), health.DatabaseCheck(replica))
+	}
+	// Probes use the untraced client so they don't add a trace every few seconds
+	a.Health.Register("cache", health.CacheCheck(a.Cache.Cache))
+	a.Health.Register("websocket_hub", health.RunningCheck(a.Rooms))
+	if opts.Backplane != nil {
+		a.Health.Register("websocket_backplane", opts.Backplane.Ping)
+	}
+	if a.Migrator != nil {
+		a.Health.Register("migrations", health.MigrationsCheck(a.Migrator))
+	}
+
+	return a, nil
+}
+
+// instrumentDatabases records query metrics (if enabled) and spans (if tracing
+// is enabled) for the primary database and each replica
+func instrumentDatabases(opts Options) error {
+	var dbMetrics *telemetry.GormMetrics
+	if opts.Metrics != nil {
+		var err error
+		if dbMetrics, err = telemetry.NewGormMetrics(opts.Metrics.Registry()); err != nil {
+			return fmt.Errorf("failed to register database metrics: %w", err)
+		}
+	}
+
+	if err := opts.DB.Use(dbMetrics.Plugin("primary", opts.TracerProvider)); err != nil {
+		return fmt.Errorf("failed to instrument database: %w", err)
+	}
+	for i, replica := range opts.Replicas {
+		if err := replica.Use(dbMetrics.Plugin(fmt.Sprintf("replica_%d", i+1), opts.TracerProvider)); err != nil {
+			return fmt.Errorf("failed to instrument read replica %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Start starts background services (the WebSocket hub)
+func (a *App) Start() error {
+	if err := a.Rooms.Start(); err != nil {
+		return err
+	}
+	a.Logger.Info("WebSocket room manager initialized")
+	return nil
+}
+
+// Close releases the cache and database connections the App was given,
+// for short-lived commands that never start the server
+func (a *App) Close() error {
+	return errors.Join(
+		config.CloseCache(a.Cache.Cache),
+		config.CloseDatabases(a.Replicas),
+		config.CloseDatabase(a.DB),
+	)
+}
+
+// RegisterShutdown adds the App's resources to the lifecycle manager,
+// telling WebSocket clients the server is going away, then closing the hub
+// before the cache and database it may still be using
+func (a *App) RegisterShutdown(lc *lifecycle.Manager) {
+	lc.OnShutdown("websocket shutdown notice", a.Rooms.NotifyShutdown)
+	lc.OnShutdown("websocket hub", a.Rooms.Shutdown)
+	lc.OnShutdown("cache", func(ctx context.Context) error {
+		return config.CloseCache(a.Cache.Cache)
+	})
+	if len(a.Replicas) > 0 {
+		lc.OnShutdown("database replicas", func(ctx context.Context) error {
+			return config.CloseDatabases(a.Replicas)
+		})
+	}
+	if a.DB == nil {
+		return
+	}
+	lc.OnShutdown("database", func(ctx context.Context) error {
+		return config.CloseDatabase(a.DB)
+	})
+}
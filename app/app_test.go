@@ -0,0 +1,119 @@
+package app
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/OkanUysal/go-cache"
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/lifecycle"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestCache(t *testing.T) *cache.Cache {
+	t.Helper()
+	c, err := cache.New(&cache.Config{Backend: cache.BackendMemory, DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	return c
+}
+
+func checkNames(a *App) []string {
+	report := a.Health.Run(context.Background())
+	var names []string
+	for name := range report.Checks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestNewRequiresStorage(t *testing.T) {
+	log := logger.New(logger.DefaultConfig().WithWriter(io.Discard))
+	for name, opts := range map[string]Options{
+		"no cache":      {Logger: log, Users: repository.NewMemoryUserRepository(), Revocations: repository.NewMemoryRevocationRepository()},
+		"no logger":     {Cache: newTestCache(t), Users: repository.NewMemoryUserRepository(), Revocations: repository.NewMemoryRevocationRepository()},
+		"no database":   {Cache: newTestCache(t), Logger: log},
+		"only users":    {Cache: newTestCache(t), Logger: log, Users: repository.NewMemoryUserRepository()},
+		"bad ws policy": {Cache: newTestCache(t), Logger: log, Users: repository.NewMemoryUserRepository(), Revocations: repository.NewMemoryRevocationRepository(), ConnectionPolicy: "sideways"},
+	} {
+		if a, err := New(opts); err == nil {
+			t.Errorf("%s: New = %+v, want an error", name, a)
+		}
+	}
+}
+
+func TestNewWithoutDatabase(t *testing.T) {
+	a, err := New(Options{
+		Cache:       newTestCache(t),
+		Logger:      logger.New(logger.DefaultConfig().WithWriter(io.Discard)),
+		Users:       repository.NewMemoryUserRepository(),
+		Revocations: repository.NewMemoryRevocationRepository(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if a.Migrator != nil {
+		t.Error("an App without a database has a migrator")
+	}
+	if a.Auth == nil || a.Rooms == nil || a.Handlers == nil || a.WebSocket == nil {
+		t.Fatalf("New left services unset: %+v", a)
+	}
+	if want := []string{"cache", "websocket_hub"}; !slices.Equal(checkNames(a), want) {
+		t.Errorf("health checks = %v, want %v", checkNames(a), want)
+	}
+	if err := a.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+func TestNewWithDatabase(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "app.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	a, err := New(Options{
+		DB:     db,
+		Cache:  newTestCache(t),
+		Logger: logger.New(logger.DefaultConfig().WithWriter(io.Discard)),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if a.Migrator == nil {
+		t.Fatal("an App with a database has no migrator")
+	}
+	if err := a.Migrator.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if want := []string{"cache", "database", "migrations", "websocket_hub"}; !slices.Equal(checkNames(a), want) {
+		t.Errorf("health checks = %v, want %v", checkNames(a), want)
+	}
+	if err := a.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// Shutdown closes the hub before the database it may still be using
+	lc := lifecycle.NewManager(time.Second, a.Logger)
+	a.RegisterShutdown(lc)
+	if err := lc.Shutdown(); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("db.DB: %v", err)
+	}
+	if err := sqlDB.Ping(); err == nil {
+		t.Error("the database is still open after shutdown")
+	}
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/auth"
+	docs "github.com/OkanUysal/go-starter-example-project/docs"
+	"github.com/OkanUysal/go-starter-example-project/handlers"
+	"github.com/OkanUysal/go-starter-example-project/health"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+	"github.com/OkanUysal/go-swagger"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// untracedPaths are polled by probes and scrapers and would flood the traces
+var untracedPaths = map[string]bool{
+	"/metrics":       true,
+	"/health":        true,
+	"/healthz/live":  true,
+	"/healthz/ready": true,
+}
+
+// Router builds the Gin engine with all routes and middleware
+func (a *App) Router() *gin.Engine {
+	r := gin.Default()
+
+	if a.TracerProvider != nil {
+		// Continue the caller's trace (W3C traceparent) or start a new one per request
+		r.Use(otelgin.Middleware(a.ServiceName,
+			otelgin.WithTracerProvider(a.TracerProvider),
+			otelgin.WithPropagators(telemetry.Propagator()),
+			otelgin.WithFilter(func(r *http.Request) bool {
+				return !untracedPaths[r.URL.Path]
+			}),
+		))
+	}
+
+	// Accept or assign an X-Request-ID; placed early so every response carries it
+	r.Use(telemetry.RequestID())
+
+	// Serve static files
+	r.Static("/static", "./static")
+
+	if a.Metrics != nil {
+		// Metrics endpoints (/metrics and /health), registered per engine
+		// rather than through Setup, which only ever runs once per process
+		r.GET("/metrics", a.Metrics.MetricsEndpoint())
+		r.GET("/health", a.Metrics.HealthEndpoint())
+	}
+
+	// Liveness and readiness probes (registered before the metrics middleware so probes aren't counted)
+	r.GET("/healthz/live", health.LiveHandler())
+	r.GET("/healthz/ready", a.Health.ReadyHandler())
+
+	if a.Metrics != nil {
+		// Metrics middleware (automatic HTTP metrics collection)
+		r.Use(a.Metrics.GinMiddleware())
+	}
+
+	// Swagger documentation with auto host detection
+	swagSpec, err := swagger.LoadSwagDocs(docs.SwaggerInfo.ReadDoc())
+	if err != nil {
+		a.Logger.Error("Failed to load swagger docs", logger.Err(err))
+	} else {
+		swagger.SetupWithSwag(r, swagSpec, swagger.DefaultConfig())
+		a.Logger.Info("Swagger UI enabled", logger.String("path", "/swagger/index.html"))
+	}
+
+	requireAuth := a.Auth.Middleware()
+
+	// API routes group
+	api := r.Group("/api")
+	{
+		api.GET("/hello", handlers.HelloHandler)
+
+		// Auth routes
+		authGroup := api.Group("/auth")
+		{
+			authGroup.POST("/guest-login", a.Handlers.GuestLogin)
+			authGroup.POST("/refresh", a.Handlers.RefreshToken)
+
+			// Protected routes
+			authGroup.GET("/me", requireAuth, a.Handlers.GetMe)
+		}
+
+		// Admin routes - requires authentication and admin role
+		adminGroup := api.Group("/admin")
+		adminGroup.Use(requireAuth)
+		adminGroup.Use(auth.AdminMiddleware())
+		{
+			adminGroup.GET("/dashboard", a.Handlers.AdminDashboard)
+			adminGroup.GET("/users", a.Handlers.ListUsers)
+		}
+
+		// WebSocket protocol description, generated from the message types
+		api.GET("/asyncapi.json", a.WebSocket.GetAsyncAPI)
+
+		// Presence of users connected over WebSocket
+		api.GET("/presence", requireAuth, a.WebSocket.GetPresence)
+
+		// WebSocket routes
+		wsGroup := api.Group("/ws")
+		wsGroup.Use(requireAuth) // All WebSocket routes require authentication
+		{
+			// WebSocket connection endpoint
+			wsGroup.GET("", a.WebSocket.WebSocketConnect)
+
+			// Room management endpoints
+			wsGroup.GET("/rooms", a.WebSocket.GetRooms)
+			wsGroup.GET("/rooms/:room_id", a.WebSocket.GetRoomInfo)
+			wsGroup.GET("/rooms/:room_id/messages", a.WebSocket.GetRoomMessages)
+			wsGroup.GET("/dm/:user_id", a.WebSocket.GetConversation)
+			wsGroup.GET("/blocks", a.WebSocket.GetBlocks)
+			wsGroup.POST("/blocks/:user_id", a.WebSocket.BlockUser)
+			wsGroup.DELETE("/blocks/:user_id", a.WebSocket.UnblockUser)
+
+			// Admin-only WebSocket endpoints
+			wsAdminGroup := wsGroup.Group("")
+			wsAdminGroup.Use(auth.AdminMiddleware())
+			{
+				wsAdminGroup.POST("/rooms", a.WebSocket.CreateRoom)
+				wsAdminGroup.DELETE("/rooms/:room_id", a.WebSocket.CloseRoom)
+				wsAdminGroup.POST("/invite", a.WebSocket.InviteToRoom)
+			}
+		}
+	}
+
+	return r
+}
@@ -2,32 +2,42 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/OkanUysal/go-logger"
-	"github.com/OkanUysal/go-starter-example-project/config"
 	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// BlacklistToken adds a token to the blacklist
-func BlacklistToken(jti, userID string, expiresAt time.Time) error {
-	db := config.GetDB()
+// ErrRevocationUnavailable is returned when the blacklist can't be checked.
+// Tokens are then refused rather than trusted, since they may have been revoked.
+var ErrRevocationUnavailable = errors.New("token revocation status is unavailable")
 
-	blacklist := models.TokenBlacklist{
+// BlacklistToken adds a token to the blacklist
+func (s *Service) BlacklistToken(ctx context.Context, jti, userID string, expiresAt time.Time) error {
+	err := s.revocations.Revoke(ctx, models.TokenBlacklist{
 		JTI:       jti,
 		UserID:    userID,
 		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
 	}
 
-	return db.Create(&blacklist).Error
+	// Cache the revocation only once it is stored, so a concurrent check
+	// can't cache "not revoked" after an invalidation but before the write
+	s.cache.SetJSON(ctx, fmt.Sprintf("blacklist:jti:%s", jti), true)
+	return nil
 }
 
 // BlacklistByFamilyID blacklists all tokens in a family
-func BlacklistByFamilyID(familyID, userID string, expiresAt time.Time) error {
-	db := config.GetDB()
-	cache := config.GetCache()
-	ctx := context.Background()
+func (s *Service) BlacklistByFamilyID(ctx context.Context, familyID, userID string, expiresAt time.Time) error {
+	cache := s.cache
 
 	blacklist := models.TokenBlacklist{
 		JTI:       familyID, // Using family ID as JTI for family-based blacklist
@@ -36,88 +46,124 @@ func BlacklistByFamilyID(familyID, userID string, expiresAt time.Time) error {
 		ExpiresAt: expiresAt,
 	}
 
-	// Invalidate cache
-	cache.Delete(ctx, fmt.Sprintf("blacklist:family:%s", familyID))
+	if err := s.revocations.Revoke(ctx, blacklist); err != nil {
+		return err
+	}
 
-	return db.Create(&blacklist).Error
+	// Cache the revocation once it is stored
+	cache.SetJSON(ctx, fmt.Sprintf("blacklist:family:%s", familyID), true)
+	return nil
 }
 
 // BlacklistTokenPair adds both access and refresh tokens to the blacklist
-func BlacklistTokenPair(accessJTI, refreshJTI, userID string, accessExpiresAt, refreshExpiresAt time.Time) error {
-	db := config.GetDB()
-
-	blacklists := []models.TokenBlacklist{
-		{
+func (s *Service) BlacklistTokenPair(ctx context.Context, accessJTI, refreshJTI, userID string, accessExpiresAt, refreshExpiresAt time.Time) error {
+	err := s.revocations.Revoke(ctx,
+		models.TokenBlacklist{
 			JTI:       accessJTI,
 			UserID:    userID,
 			ExpiresAt: accessExpiresAt,
 		},
-		{
+		models.TokenBlacklist{
 			JTI:       refreshJTI,
 			UserID:    userID,
 			ExpiresAt: refreshExpiresAt,
 		},
+	)
+	if err != nil {
+		return err
 	}
 
-	return db.Create(&blacklists).Error
+	// Cache the revocations once they are stored
+	s.cache.SetJSON(ctx, fmt.Sprintf("blacklist:jti:%s", accessJTI), true)
+	s.cache.SetJSON(ctx, fmt.Sprintf("blacklist:jti:%s", refreshJTI), true)
+	return nil
+}
+
+// IsTokenRevoked checks if a token or its family is blacklisted; it returns
+// ErrRevocationUnavailable if that can't be told
+func (s *Service) IsTokenRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	revoked, err := s.IsTokenBlacklisted(ctx, claims.ID)
+	if err != nil || revoked {
+		return revoked, err
+	}
+	return s.IsTokenFamilyBlacklisted(ctx, claims.FamilyID)
 }
 
-// IsTokenBlacklisted checks if a token is blacklisted (by JTI or family ID)
-func IsTokenBlacklisted(jti string) bool {
-	cache := config.GetCache()
-	ctx := context.Background()
+// IsTokenBlacklisted checks if a token is blacklisted by JTI; it returns
+// ErrRevocationUnavailable if the blacklist can't be read
+func (s *Service) IsTokenBlacklisted(ctx context.Context, jti string) (bool, error) {
+	ctx, span := s.tracer.Start(ctx, "auth.IsTokenBlacklisted",
+		trace.WithAttributes(attribute.String("auth.jti", jti)))
+	defer span.End()
+
+	cache := s.cache
+	log := telemetry.Logger(ctx, s.logger)
 	cacheKey := fmt.Sprintf("blacklist:jti:%s", jti)
 
 	// Check cache first
 	var cached bool
 	if err := cache.GetJSON(ctx, cacheKey, &cached); err == nil {
-		config.Logger.Info("Cache hit: token blacklist check", logger.String("jti", jti), logger.Bool("is_blacklisted", cached))
-		return cached
+		log.Info("Cache hit: token blacklist check", logger.String("jti", jti), logger.Bool("is_blacklisted", cached))
+		span.SetAttributes(attribute.Bool("auth.revoked", cached))
+		return cached, nil
 	}
 
-	config.Logger.Info("Cache miss: token blacklist check", logger.String("jti", jti))
-
-	// Check database
-	db := config.GetDB()
-	var count int64
-	db.Model(&models.TokenBlacklist{}).Where("jti = ?", jti).Count(&count)
+	log.Info("Cache miss: token blacklist check", logger.String("jti", jti))
 
-	isBlacklisted := count > 0
+	// Check database; on failure don't cache so the next request retries
+	isBlacklisted, err := s.revocations.IsRevoked(ctx, jti)
+	if err != nil {
+		log.Error("Failed to check token blacklist", logger.String("jti", jti), logger.Err(err))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "blacklist lookup failed")
+		return false, fmt.Errorf("%w: %w", ErrRevocationUnavailable, err)
+	}
 
 	// Cache the result (uses default TTL: 5 minutes)
 	cache.SetJSON(ctx, cacheKey, isBlacklisted)
 
-	return isBlacklisted
+	span.SetAttributes(attribute.Bool("auth.revoked", isBlacklisted))
+	return isBlacklisted, nil
 }
 
-// IsTokenFamilyBlacklisted checks if a token's family is blacklisted
-func IsTokenFamilyBlacklisted(familyID string) bool {
-	cache := config.GetCache()
-	ctx := context.Background()
+// IsTokenFamilyBlacklisted checks if a token's family is blacklisted; it
+// returns ErrRevocationUnavailable if the blacklist can't be read
+func (s *Service) IsTokenFamilyBlacklisted(ctx context.Context, familyID string) (bool, error) {
+	ctx, span := s.tracer.Start(ctx, "auth.IsTokenFamilyBlacklisted",
+		trace.WithAttributes(attribute.String("auth.family_id", familyID)))
+	defer span.End()
+
+	cache := s.cache
+	log := telemetry.Logger(ctx, s.logger)
 	cacheKey := fmt.Sprintf("blacklist:family:%s", familyID)
 
 	// Check cache first
 	var cached bool
 	if err := cache.GetJSON(ctx, cacheKey, &cached); err == nil {
-		config.Logger.Info("Cache hit: token family blacklist check", logger.String("family_id", familyID), logger.Bool("is_blacklisted", cached))
-		return cached
+		log.Info("Cache hit: token family blacklist check", logger.String("family_id", familyID), logger.Bool("is_blacklisted", cached))
+		span.SetAttributes(attribute.Bool("auth.revoked", cached))
+		return cached, nil
 	}
 
-	config.Logger.Info("Cache miss: token family blacklist check", logger.String("family_id", familyID))
+	log.Info("Cache miss: token family blacklist check", logger.String("family_id", familyID))
 
-	// Check database
-	db := config.GetDB()
-	var count int64
-	db.Model(&models.TokenBlacklist{}).Where("family_id = ?", familyID).Count(&count)
-
-	isBlacklisted := count > 0
+	// Check database; on failure don't cache so the next request retries
+	isBlacklisted, err := s.revocations.IsFamilyRevoked(ctx, familyID)
+	if err != nil {
+		log.Error("Failed to check token family blacklist", logger.String("family_id", familyID), logger.Err(err))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "blacklist lookup failed")
+		return false, fmt.Errorf("%w: %w", ErrRevocationUnavailable, err)
+	}
 
 	// Cache the result (uses default TTL: 5 minutes)
 	cache.SetJSON(ctx, cacheKey, isBlacklisted)
 
-	return isBlacklisted
-} // CleanupExpiredTokens removes expired tokens from blacklist
-func CleanupExpiredTokens() error {
-	db := config.GetDB()
-	return db.Where("expires_at < ?", time.Now()).Delete(&models.TokenBlacklist{}).Error
+	span.SetAttributes(attribute.Bool("auth.revoked", isBlacklisted))
+	return isBlacklisted, nil
+}
+
+// CleanupExpiredTokens removes expired tokens from blacklist and returns how many were removed
+func (s *Service) CleanupExpiredTokens(ctx context.Context) (int64, error) {
+	return s.revocations.DeleteExpired(ctx, time.Now())
 }
@@ -8,7 +8,7 @@ import (
 )
 
 // Middleware validates JWT tokens and sets user info in context
-func Middleware() gin.HandlerFunc {
+func (s *Service) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Try to get token from Authorization header first
 		authHeader := c.GetHeader("Authorization")
@@ -39,8 +39,15 @@ func Middleware() gin.HandlerFunc {
 			return
 		}
 
-		// Check if token is blacklisted (by JTI or family ID)
-		if IsTokenBlacklisted(claims.ID) || IsTokenFamilyBlacklisted(claims.FamilyID) {
+		// Check if token is blacklisted (by JTI or family ID); if that
+		// can't be checked, refuse the token instead of trusting it
+		revoked, err := s.IsTokenRevoked(c.Request.Context(), claims)
+		if err != nil {
+			response.Error(c, 503, "Token revocation status is unavailable, try again later", nil)
+			c.Abort()
+			return
+		}
+		if revoked {
 			response.Unauthorized(c, "Token has been revoked")
 			c.Abort()
 			return
@@ -2,21 +2,40 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 
 	"github.com/OkanUysal/go-logger"
 	"github.com/OkanUysal/go-starter-example-project/config"
 	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// instrumentationName identifies the auth spans' tracer
+const instrumentationName = "github.com/OkanUysal/go-starter-example-project/auth"
+
 // Service handles authentication operations
-type Service struct{}
+type Service struct {
+	users       repository.UserRepository
+	revocations repository.RevocationRepository
+	cache       *config.Cache
+	logger      *logger.Logger
+	tracer      trace.Tracer
+}
 
-// NewService creates a new auth service
-func NewService() *Service {
-	return &Service{}
+// NewService creates a new auth service; tracerProvider may be nil to disable tracing
+func NewService(users repository.UserRepository, revocations repository.RevocationRepository, c *config.Cache, log *logger.Logger, tracerProvider trace.TracerProvider) *Service {
+	return &Service{
+		users:       users,
+		revocations: revocations,
+		cache:       c,
+		logger:      log,
+		tracer:      telemetry.Tracer(tracerProvider, instrumentationName),
+	}
 }
 
 // GuestLoginResponse represents the response for guest login
@@ -37,15 +56,13 @@ type GuestLoginRequest struct {
 }
 
 // GuestLogin creates a new guest user or logs in existing guest and returns tokens
-func (s *Service) GuestLogin(guestID *string) (*GuestLoginResponse, error) {
-	db := config.GetDB()
-	var user models.User
-
+func (s *Service) GuestLogin(ctx context.Context, guestID *string) (*GuestLoginResponse, error) {
 	// If guest_id is provided, try to find existing user
 	if guestID != nil && *guestID != "" {
-		if err := db.Where("guest_id = ?", *guestID).First(&user).Error; err == nil {
+		existing, err := s.users.GetByGuestID(ctx, *guestID)
+		if err == nil {
 			// User found, generate new token pair
-			tokenPair, err := GenerateTokenPair(user.ID, string(user.Role))
+			tokenPair, err := GenerateTokenPair(existing.ID, string(existing.Role))
 			if err != nil {
 				return nil, fmt.Errorf("failed to generate tokens: %w", err)
 			}
@@ -53,9 +70,12 @@ func (s *Service) GuestLogin(guestID *string) (*GuestLoginResponse, error) {
 			return &GuestLoginResponse{
 				AccessToken:  tokenPair.AccessToken,
 				RefreshToken: tokenPair.RefreshToken,
-				User:         user,
+				User:         *existing,
 			}, nil
 		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("failed to look up guest: %w", err)
+		}
 		// If not found, continue to create new user
 	}
 
@@ -72,7 +92,7 @@ func (s *Service) GuestLogin(guestID *string) (*GuestLoginResponse, error) {
 	displayName := fmt.Sprintf("Guest%d", randomNum)
 
 	// Create new user
-	user = models.User{
+	user := models.User{
 		ID:          userID,
 		GuestID:     &newGuestID,
 		DisplayName: displayName,
@@ -80,7 +100,7 @@ func (s *Service) GuestLogin(guestID *string) (*GuestLoginResponse, error) {
 		IsGuest:     true,
 	}
 
-	if err := db.Create(&user).Error; err != nil {
+	if err := s.users.Create(ctx, &user); err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -98,22 +118,26 @@ func (s *Service) GuestLogin(guestID *string) (*GuestLoginResponse, error) {
 }
 
 // RefreshToken validates refresh token only and issues new tokens, blacklisting the old token family
-func (s *Service) RefreshToken(token string) (*GuestLoginResponse, error) {
+func (s *Service) RefreshToken(ctx context.Context, token string) (*GuestLoginResponse, error) {
 	// Validate refresh token specifically
 	claims, err := ValidateRefreshToken(token)
 	if err != nil {
 		return nil, fmt.Errorf("invalid refresh token: %w", err)
 	}
 
-	// Check if token or its family is blacklisted
-	if IsTokenBlacklisted(claims.ID) || IsTokenFamilyBlacklisted(claims.FamilyID) {
+	// Check if token or its family is blacklisted; a revoked family means
+	// the refresh token is being reused after rotation
+	revoked, err := s.IsTokenRevoked(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
 		return nil, fmt.Errorf("token has been revoked")
 	}
 
 	// Get user from database
-	db := config.GetDB()
-	var user models.User
-	if err := db.Where("id = ?", claims.UserID).First(&user).Error; err != nil {
+	user, err := s.users.GetByID(ctx, claims.UserID)
+	if err != nil {
 		return nil, fmt.Errorf("user not found: %w", err)
 	}
 
@@ -125,7 +149,7 @@ func (s *Service) RefreshToken(token string) (*GuestLoginResponse, error) {
 
 	// Blacklist the entire old token family (both access and refresh tokens)
 	if claims.ExpiresAt != nil {
-		if err := BlacklistByFamilyID(claims.FamilyID, user.ID, claims.ExpiresAt.Time); err != nil {
+		if err := s.BlacklistByFamilyID(ctx, claims.FamilyID, user.ID, claims.ExpiresAt.Time); err != nil {
 			return nil, fmt.Errorf("failed to blacklist token family: %w", err)
 		}
 	}
@@ -133,33 +157,93 @@ func (s *Service) RefreshToken(token string) (*GuestLoginResponse, error) {
 	return &GuestLoginResponse{
 		AccessToken:  tokenPair.AccessToken,
 		RefreshToken: tokenPair.RefreshToken,
-		User:         user,
+		User:         *user,
 	}, nil
 }
 
 // GetUserByID returns a user by ID with caching
-func (s *Service) GetUserByID(userID string) (*models.User, error) {
-	cache := config.GetCache()
-	ctx := context.Background()
+func (s *Service) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	cache := s.cache
+	log := telemetry.Logger(ctx, s.logger)
 	cacheKey := fmt.Sprintf("user:%s", userID)
 
 	// Try to get from cache first
 	var user models.User
 	if err := cache.GetJSON(ctx, cacheKey, &user); err == nil {
-		config.Logger.Info("Cache hit: user data", logger.String("user_id", userID))
+		log.Info("Cache hit: user data", logger.String("user_id", userID))
 		return &user, nil
 	}
 
-	config.Logger.Info("Cache miss: user data", logger.String("user_id", userID))
+	log.Info("Cache miss: user data", logger.String("user_id", userID))
 
 	// Get from database if not in cache
-	db := config.GetDB()
-	if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+	found, err := s.users.GetByID(ctx, userID)
+	if err != nil {
 		return nil, fmt.Errorf("user not found: %w", err)
 	}
 
 	// Cache the user (uses default TTL: 5 minutes)
-	cache.SetJSON(ctx, cacheKey, user)
+	cache.SetJSON(ctx, cacheKey, found)
 
-	return &user, nil
+	return found, nil
+}
+
+// CreateAdmin creates a new admin user and returns a token pair for it.
+// This is how the first admin is bootstrapped, since there is no password login.
+func (s *Service) CreateAdmin(ctx context.Context, displayName string) (*GuestLoginResponse, error) {
+	user := models.User{
+		ID:          uuid.New().String(),
+		DisplayName: displayName,
+		Role:        models.RoleAdmin,
+		IsGuest:     false,
+	}
+
+	if err := s.users.Create(ctx, &user); err != nil {
+		return nil, fmt.Errorf("failed to create admin: %w", err)
+	}
+
+	telemetry.Logger(ctx, s.logger).Info("Admin user created",
+		logger.String("user_id", user.ID),
+		logger.String("display_name", user.DisplayName))
+
+	return s.MintTokens(ctx, user.ID)
+}
+
+// PromoteToAdmin grants the admin role to an existing user
+func (s *Service) PromoteToAdmin(ctx context.Context, userID string) (*models.User, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	user.Role = models.RoleAdmin
+	if err := s.users.UpdateRole(ctx, user.ID, user.Role); err != nil {
+		return nil, fmt.Errorf("failed to promote user: %w", err)
+	}
+
+	// Invalidate cached user data so the new role is visible immediately
+	s.cache.Delete(ctx, fmt.Sprintf("user:%s", userID))
+
+	telemetry.Logger(ctx, s.logger).Info("User promoted to admin", logger.String("user_id", userID))
+
+	return user, nil
+}
+
+// MintTokens issues a fresh token pair for an existing user
+func (s *Service) MintTokens(ctx context.Context, userID string) (*GuestLoginResponse, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	tokenPair, err := GenerateTokenPair(user.ID, string(user.Role))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	return &GuestLoginResponse{
+		AccessToken:  tokenPair.AccessToken,
+		RefreshToken: tokenPair.RefreshToken,
+		User:         *user,
+	}, nil
 }
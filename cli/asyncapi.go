@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"flag"
+	"os"
+
+	"github.com/OkanUysal/go-starter-example-project/websocket"
+)
+
+const asyncAPIUsage = "asyncapi [-o FILE]"
+
+// runAsyncAPI writes the WebSocket protocol description, to stdout or a file
+func runAsyncAPI(args []string) error {
+	flags := flag.NewFlagSet("asyncapi", flag.ContinueOnError)
+	output := flags.String("o", "", "file to write (default: stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return usageError(asyncAPIUsage)
+	}
+
+	doc, err := websocket.AsyncAPI()
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(doc)
+		return err
+	}
+	return os.WriteFile(*output, doc, 0o644)
+}
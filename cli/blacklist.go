@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+)
+
+const blacklistUsage = "blacklist cleanup"
+
+// runBlacklist maintains the token blacklist
+func runBlacklist(args []string) error {
+	if len(args) != 1 || args[0] != "cleanup" {
+		return usageError(blacklistUsage)
+	}
+
+	a, err := connect()
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	removed, err := a.Auth.CleanupExpiredTokens(context.Background())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed %d expired blacklist entries\n", removed)
+	return nil
+}
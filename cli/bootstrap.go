@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/app"
+	"github.com/OkanUysal/go-starter-example-project/config"
+)
+
+// loadConfig loads environment variables and feature flags and creates the logger.
+// Every subcommand calls this first so they all see the same configuration.
+func loadConfig() *logger.Logger {
+	// Load environment variables
+	config.LoadEnv()
+
+	// Load configuration and feature flags
+	config.LoadConfig()
+
+	return config.NewLogger()
+}
+
+// connect loads configuration, connects to the database and cache and wires the App
+func connect() (*app.App, error) {
+	log := loadConfig()
+
+	opts, err := connectOptions(log)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := app.New(opts)
+	if err != nil {
+		config.CloseCache(opts.Cache)
+		config.CloseDatabases(opts.Replicas)
+		config.CloseDatabase(opts.DB)
+		return nil, err
+	}
+	return a, nil
+}
+
+// connectOptions connects to the database, its replicas and the cache and returns App options for them
+func connectOptions(log *logger.Logger) (app.Options, error) {
+	// Connect to database
+	db, err := config.ConnectDatabase(log)
+	if err != nil {
+		return app.Options{}, err
+	}
+	log.Info("Database connected successfully")
+
+	// Connect to read replicas, if any are configured
+	replicas, err := config.ConnectReplicas(log)
+	if err != nil {
+		config.CloseDatabase(db)
+		return app.Options{}, err
+	}
+
+	// Initialize cache
+	c, err := config.InitCache(log)
+	if err != nil {
+		config.CloseDatabases(replicas)
+		config.CloseDatabase(db)
+		return app.Options{}, err
+	}
+	log.Info("Cache initialized successfully")
+
+	opts := app.OptionsFromConfig(db, c, log)
+	opts.Replicas = replicas
+	return opts, nil
+}
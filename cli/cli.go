@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// command is a CLI subcommand
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) error
+}
+
+// commands lists all subcommands in the order they are shown in the usage text
+var commands = []command{
+	{name: "serve", usage: "serve", summary: "Start the HTTP and WebSocket server (default)", run: runServe},
+	{name: "migrate", usage: "migrate up|down [steps]|to <version>|status", summary: "Apply, roll back or inspect database migrations", run: runMigrate},
+	{name: "user", usage: "user create-admin [-name NAME] | promote <user_id>", summary: "Create or promote admin users", run: runUser},
+	{name: "token", usage: "token mint <user_id>", summary: "Mint an access/refresh token pair for debugging", run: runToken},
+	{name: "blacklist", usage: "blacklist cleanup", summary: "Remove expired entries from the token blacklist", run: runBlacklist},
+	{name: "config", usage: "config check", summary: "Validate configuration and connectivity", run: runConfig},
+	{name: "asyncapi", usage: "asyncapi [-o FILE]", summary: "Write the WebSocket protocol description (AsyncAPI)", run: runAsyncAPI},
+}
+
+// Run executes the subcommand named by args[0] and returns the process exit code.
+// With no arguments the server is started, so `go run main.go` keeps working.
+func Run(args []string) int {
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printUsage(os.Stdout)
+		return 0
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			if err := cmd.run(args[1:]); err != nil {
+				if err != flag.ErrHelp {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				}
+				return 1
+			}
+			return 0
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+	printUsage(os.Stderr)
+	return 2
+}
+
+// printUsage writes the list of subcommands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: go-starter-example-project <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-52s %s\n", cmd.usage, cmd.summary)
+	}
+}
+
+// usageError returns an error describing the expected arguments of a subcommand
+func usageError(usage string) error {
+	return fmt.Errorf("usage: %s", usage)
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestRunRejectsBadArguments(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{"help"}, 0},
+		{[]string{"no-such-command"}, 2},
+		{[]string{"migrate"}, 1},
+		{[]string{"migrate", "sideways"}, 1},
+		{[]string{"user"}, 1},
+		{[]string{"token", "burn", "u1"}, 1},
+		{[]string{"blacklist"}, 1},
+	} {
+		if got := Run(tc.args); got != tc.want {
+			t.Errorf("Run(%q) = %d, want %d", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestMigrateAndAdminCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cli.db")
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("SQLITE_PATH", path)
+	t.Setenv("CACHE_TYPE", "memory")
+	t.Setenv("JWT_SECRET", "cli-test-secret")
+
+	for _, tc := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{"migrate", "up"}, 0},
+		{[]string{"migrate", "down", "2"}, 0},
+		{[]string{"migrate", "to", "1"}, 0},
+		{[]string{"migrate", "to", "999"}, 1},
+		{[]string{"migrate", "up"}, 0},
+		{[]string{"migrate", "status"}, 0},
+		{[]string{"user", "create-admin", "-name", "Root"}, 0},
+		{[]string{"user", "promote", "no-such-user"}, 1},
+		{[]string{"token", "mint", "no-such-user"}, 1},
+		{[]string{"blacklist", "cleanup"}, 0},
+	} {
+		if got := Run(tc.args); got != tc.want {
+			t.Errorf("Run(%q) = %d, want %d", tc.args, got, tc.want)
+		}
+	}
+
+	// Every command closed its connection, and their changes are in the file
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	var admins []models.User
+	if err := db.Where("role = ?", models.RoleAdmin).Find(&admins).Error; err != nil {
+		t.Fatalf("failed to read users: %v", err)
+	}
+	if len(admins) != 1 || admins[0].DisplayName != "Root" {
+		t.Errorf("admins = %+v, want one named Root", admins)
+	}
+
+	if got := Run([]string{"token", "mint", admins[0].ID}); got != 0 {
+		t.Errorf("token mint for the admin = %d, want 0", got)
+	}
+}
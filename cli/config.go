@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/app"
+	"github.com/OkanUysal/go-starter-example-project/config"
+	"github.com/OkanUysal/go-starter-example-project/migrations"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+	"github.com/OkanUysal/go-starter-example-project/websocket"
+	"gorm.io/gorm"
+)
+
+const configUsage = "config check"
+
+// checkResult is the outcome of a single configuration check
+type checkResult struct {
+	name   string
+	err    error
+	warned bool
+}
+
+// runConfig validates configuration and connectivity
+func runConfig(args []string) error {
+	if len(args) != 1 || args[0] != "check" {
+		return usageError(configUsage)
+	}
+
+	log := loadConfig()
+
+	results := []checkResult{
+		checkDatabaseDriver(),
+		checkSecret(),
+		checkPositiveInt("ACCESS_TOKEN_DURATION"),
+		checkPositiveInt("REFRESH_TOKEN_DURATION"),
+		checkPositiveInt("CACHE_TTL"),
+		checkPositiveInt("DB_MAX_OPEN_CONNS"),
+		checkPositiveInt("DB_MAX_IDLE_CONNS"),
+		checkPositiveInt("DB_CONN_MAX_LIFETIME"),
+		checkPositiveInt("DB_CONN_MAX_IDLE_TIME"),
+		checkPositiveInt("DB_SLOW_QUERY_MS"),
+		checkPositiveInt("DB_QUERY_TIMEOUT_MS"),
+		checkPositiveInt("CACHE_TIMEOUT_MS"),
+		checkPositiveInt("WS_HISTORY_SIZE"),
+		checkPositiveInt("WS_HISTORY_ON_JOIN"),
+		checkPositiveInt("WS_TYPING_TIMEOUT_MS"),
+		checkPositiveInt("WS_REPLAY_BUFFER_SIZE"),
+		checkPositiveInt("WS_MESSAGE_RATE"),
+		checkPositiveInt("WS_MESSAGE_BURST"),
+		checkPositiveInt("WS_COMPRESSION_MIN_SIZE"),
+		checkPositiveInt("WS_MAX_CONNECTIONS_PER_USER"),
+		checkSQLLogLevel(),
+		checkCacheType(),
+		checkTracingExporter(),
+		checkHistoryStore(),
+		checkConnectionPolicy(),
+	}
+
+	// Connectivity checks
+	db, dbErr := config.ConnectDatabase(log)
+	results = append(results, checkResult{name: "database connection", err: dbErr})
+	c, cacheErr := config.InitCache(log)
+	results = append(results, checkResult{name: "cache backend", err: cacheErr})
+	if dbErr == nil {
+		results = append(results, checkMigrations(db, log))
+		defer config.CloseDatabase(db)
+	}
+	if os.Getenv("DATABASE_REPLICA_URLS") != "" {
+		replicas, err := config.ConnectReplicas(log)
+		results = append(results, checkResult{name: "read replica connections", err: err})
+		defer config.CloseDatabases(replicas)
+	}
+	if cacheErr == nil {
+		defer config.CloseCache(c)
+	}
+	if redisClient, err := newBackplaneClient(); err != nil {
+		results = append(results, checkResult{name: "WS_BACKPLANE", err: err})
+	} else if redisClient != nil {
+		err := redisClient.Ping(context.Background()).Err()
+		results = append(results, checkResult{name: "websocket backplane connection", err: err})
+		defer redisClient.Close()
+	}
+
+	failed := 0
+	for _, result := range results {
+		switch {
+		case result.err != nil && result.warned:
+			fmt.Printf("WARN  %s: %v\n", result.name, result.err)
+		case result.err != nil:
+			fmt.Printf("FAIL  %s: %v\n", result.name, result.err)
+			failed++
+		default:
+			fmt.Printf("OK    %s\n", result.name)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d configuration check(s) failed", failed)
+	}
+	return nil
+}
+
+// checkDatabaseDriver verifies DB_DRIVER and the settings the selected driver needs
+func checkDatabaseDriver() checkResult {
+	name := config.DatabaseDriverName()
+	result := checkResult{name: "DB_DRIVER (" + name + ")"}
+
+	driver, err := config.GetDatabaseDriver(name)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	if _, err := driver.Open(); err != nil {
+		result.err = err
+	}
+	return result
+}
+
+// checkSecret verifies that the JWT secret is set and not a placeholder
+func checkSecret() checkResult {
+	result := checkResult{name: "JWT_SECRET"}
+	switch os.Getenv("JWT_SECRET") {
+	case "":
+		result.err = fmt.Errorf("not set, tokens are signed with the built-in default secret")
+	case "default-secret-key", "your-secret-key-here", "your-secret-key-change-in-production":
+		result.err = fmt.Errorf("still set to the example placeholder")
+	}
+	return result
+}
+
+// checkPositiveInt verifies that an optional numeric variable is a positive integer
+func checkPositiveInt(key string) checkResult {
+	result := checkResult{name: key}
+	value := os.Getenv(key)
+	if value == "" {
+		return result
+	}
+	if parsed, err := strconv.Atoi(value); err != nil || parsed <= 0 {
+		result.err = fmt.Errorf("%q is not a positive integer", value)
+	}
+	return result
+}
+
+// checkSQLLogLevel verifies the SQL log level
+func checkSQLLogLevel() checkResult {
+	result := checkResult{name: "DB_LOG_LEVEL"}
+	if _, err := config.ParseSQLLogLevel(config.DBLogLevel); err != nil {
+		result.err = fmt.Errorf("%w, warn will be used", err)
+		result.warned = true
+	}
+	return result
+}
+
+// checkCacheType verifies the cache backend selection
+func checkCacheType() checkResult {
+	result := checkResult{name: "CACHE_TYPE"}
+	switch cacheType := config.GetEnv("CACHE_TYPE", "memory"); cacheType {
+	case "memory":
+	case "redis":
+		if os.Getenv("REDIS_URL") == "" {
+			result.err = fmt.Errorf("redis selected but REDIS_URL is not set, memory cache will be used")
+			result.warned = true
+		}
+	default:
+		result.err = fmt.Errorf("unknown cache type %q, memory cache will be used", cacheType)
+		result.warned = true
+	}
+	return result
+}
+
+// checkTracingExporter verifies the trace exporter selection
+func checkTracingExporter() checkResult {
+	result := checkResult{name: "TRACING_EXPORTER"}
+	switch exporter := config.TracingExporter; exporter {
+	case telemetry.ExporterNone, telemetry.ExporterStdout:
+	case telemetry.ExporterOTLP:
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+			result.err = fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT is not set, spans will be sent to http://localhost:4318")
+			result.warned = true
+		}
+	default:
+		result.err = fmt.Errorf("unknown trace exporter %q (use %s, %s or %s)", exporter,
+			telemetry.ExporterNone, telemetry.ExporterOTLP, telemetry.ExporterStdout)
+	}
+	return result
+}
+
+// checkHistoryStore verifies the chat history store selection
+func checkHistoryStore() checkResult {
+	result := checkResult{name: "WS_HISTORY_STORE"}
+	if _, err := newChatHistory(app.Options{}); err != nil {
+		result.err = err
+	}
+	return result
+}
+
+// checkConnectionPolicy verifies the per-user WebSocket connection policy
+func checkConnectionPolicy() checkResult {
+	result := checkResult{name: "WS_CONNECTION_POLICY"}
+	if _, err := websocket.ParseConnectionPolicy(config.WebSocketConnectionPolicy); err != nil {
+		result.err = err
+	}
+	return result
+}
+
+// checkMigrations reports pending database migrations
+func checkMigrations(db *gorm.DB, log *logger.Logger) checkResult {
+	result := checkResult{name: "database migrations"}
+
+	migrator, err := migrations.NewMigrator(db, log)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if status.ChecksumMismatch {
+			result.err = fmt.Errorf("migration %03d_%s was modified after it was applied", status.Version, status.Name)
+			return result
+		}
+		if !status.Applied {
+			pending++
+		}
+	}
+
+	if pending > 0 {
+		result.err = fmt.Errorf("%d pending migration(s), run `migrate up`", pending)
+		result.warned = true
+	}
+	return result
+}
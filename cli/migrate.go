@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/OkanUysal/go-starter-example-project/migrations"
+)
+
+const migrateUsage = "migrate up|down [steps]|to <version>|status"
+
+// runMigrate applies, rolls back or reports database migrations
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return usageError(migrateUsage)
+	}
+
+	a, err := connect()
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	migrator := a.Migrator
+
+	switch args[0] {
+	case "up":
+		if err := migrator.Up(); err != nil {
+			return err
+		}
+		fmt.Println("Migrations are up to date")
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+		}
+		if err := migrator.Down(steps); err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", steps)
+
+	case "to":
+		if len(args) < 2 {
+			return usageError(migrateUsage)
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := migrator.To(version); err != nil {
+			return err
+		}
+		fmt.Printf("Migrated to version %d\n", version)
+
+	case "status":
+		return printMigrationStatus(migrator)
+
+	default:
+		return usageError(migrateUsage)
+	}
+
+	return nil
+}
+
+// printMigrationStatus writes a table of embedded migrations and their state
+func printMigrationStatus(migrator *migrations.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, status := range statuses {
+		state := "pending"
+		appliedAt := "-"
+		if status.Applied {
+			state = "applied"
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if status.ChecksumMismatch {
+			state = "checksum mismatch"
+		}
+		fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-metrics"
+	"github.com/OkanUysal/go-starter-example-project/app"
+	"github.com/OkanUysal/go-starter-example-project/config"
+	"github.com/OkanUysal/go-starter-example-project/lifecycle"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+	"github.com/OkanUysal/go-starter-example-project/websocket"
+	"github.com/redis/go-redis/v9"
+)
+
+// runServe starts the HTTP and WebSocket server
+func runServe(args []string) error {
+	if len(args) > 0 {
+		return usageError("serve")
+	}
+
+	log := loadConfig()
+
+	opts, err := connectOptions(log)
+	if err != nil {
+		log.Error("Failed to initialize dependencies", logger.Err(err))
+		return err
+	}
+
+	// Initialize tracing (disabled unless TRACING_EXPORTER is set)
+	tracerProvider, err := telemetry.NewTracerProvider(context.Background(), config.TracingExporter, config.ServiceName)
+	if err != nil {
+		log.Error("Failed to initialize tracing", logger.Err(err))
+		return err
+	}
+	if tracerProvider != nil {
+		opts.TracerProvider = tracerProvider
+		log.Info("Tracing enabled", logger.String("exporter", config.TracingExporter))
+	}
+
+	// Share WebSocket rooms with the other replicas (single node unless WS_BACKPLANE=redis)
+	redisClient, err := newBackplaneClient()
+	if err != nil {
+		log.Error("Failed to initialize websocket backplane", logger.Err(err))
+		return err
+	}
+	if redisClient != nil {
+		opts.Backplane = websocket.NewRedisBackplane(redisClient, log)
+		log.Info("WebSocket backplane enabled", logger.String("backplane", config.WebSocketBackplane))
+	}
+
+	// Keep chat history in memory or in the database (WS_HISTORY_STORE)
+	if opts.ChatHistory, err = newChatHistory(opts); err != nil {
+		log.Error("Failed to initialize chat history", logger.Err(err))
+		return err
+	}
+
+	// Initialize metrics
+	opts.Metrics = metrics.NewMetrics(&metrics.Config{
+		ServiceName: config.ServiceName,
+	})
+
+	a, err := app.New(opts)
+	if err != nil {
+		log.Error("Failed to initialize application", logger.Err(err))
+		return err
+	}
+
+	// Apply pending migrations if enabled
+	if config.AutoMigrateEnabled {
+		if err := a.Migrator.Up(); err != nil {
+			log.Error("Failed to apply migrations", logger.Err(err))
+			return err
+		}
+		log.Info("Database migrations are up to date")
+	}
+
+	// Start WebSocket room manager
+	if err := a.Start(); err != nil {
+		log.Error("Failed to start websocket room manager", logger.Err(err))
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:    ":8080",
+		Handler: a.Router(),
+	}
+
+	// Start server
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Info("Starting server on :8080")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	// Stop accepting connections and drain in-flight requests first, then
+	// notify the WebSocket clients, which srv.Shutdown doesn't track, and
+	// release the hub, cache and database in that order,
+	// and finally flush the spans they recorded
+	lc := lifecycle.NewManager(config.ShutdownTimeout, log)
+	lc.OnShutdown("http server", srv.Shutdown)
+	a.RegisterShutdown(lc)
+	if redisClient != nil {
+		lc.OnShutdown("websocket backplane", func(ctx context.Context) error {
+			return redisClient.Close()
+		})
+	}
+	if tracerProvider != nil {
+		lc.OnShutdown("tracer provider", tracerProvider.Shutdown)
+	}
+
+	return lc.Wait(serverErr)
+}
+
+// newBackplaneClient connects to Redis for the WebSocket backplane, or returns
+// nil when rooms aren't shared (WS_BACKPLANE=memory)
+func newBackplaneClient() (*redis.Client, error) {
+	switch config.WebSocketBackplane {
+	case "memory":
+		return nil, nil
+	case "redis":
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			return nil, errors.New("WS_BACKPLANE=redis requires REDIS_URL")
+		}
+		redisOpts, err := redis.ParseURL(redisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+		}
+		return redis.NewClient(redisOpts), nil
+	default:
+		return nil, fmt.Errorf("unknown websocket backplane %q (use memory or redis)", config.WebSocketBackplane)
+	}
+}
+
+// newChatHistory returns the chat history store selected by WS_HISTORY_STORE
+func newChatHistory(opts app.Options) (repository.MessageRepository, error) {
+	switch config.WebSocketHistoryStore {
+	case "memory":
+		return repository.NewMemoryMessageRepository(config.WebSocketHistorySize), nil
+	case "database":
+		return repository.NewSQLMessageRepository(opts.DB), nil
+	default:
+		return nil, fmt.Errorf("unknown chat history store %q (use memory or database)", config.WebSocketHistoryStore)
+	}
+}
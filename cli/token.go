@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+)
+
+const tokenUsage = "token mint <user_id>"
+
+// runToken mints tokens for an existing user
+func runToken(args []string) error {
+	if len(args) != 2 || args[0] != "mint" {
+		return usageError(tokenUsage)
+	}
+
+	a, err := connect()
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	result, err := a.Auth.MintTokens(context.Background(), args[1])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Tokens for user %s (%s, role %s)\n", result.User.ID, result.User.DisplayName, result.User.Role)
+	printTokens(result)
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/OkanUysal/go-starter-example-project/auth"
+)
+
+const userUsage = "user create-admin [-name NAME] | promote <user_id>"
+
+// runUser creates or promotes admin users
+func runUser(args []string) error {
+	if len(args) == 0 {
+		return usageError(userUsage)
+	}
+
+	switch args[0] {
+	case "create-admin":
+		flags := flag.NewFlagSet("user create-admin", flag.ContinueOnError)
+		name := flags.String("name", "Admin", "display name of the new admin")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		a, err := connect()
+		if err != nil {
+			return err
+		}
+		defer a.Close()
+
+		result, err := a.Auth.CreateAdmin(context.Background(), *name)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Created admin user %s (%s)\n", result.User.ID, result.User.DisplayName)
+		printTokens(result)
+
+	case "promote":
+		if len(args) != 2 {
+			return usageError(userUsage)
+		}
+
+		a, err := connect()
+		if err != nil {
+			return err
+		}
+		defer a.Close()
+
+		user, err := a.Auth.PromoteToAdmin(context.Background(), args[1])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("User %s (%s) is now %s\n", user.ID, user.DisplayName, user.Role)
+
+	default:
+		return usageError(userUsage)
+	}
+
+	return nil
+}
+
+// printTokens writes a token pair so it can be pasted into a client
+func printTokens(result *auth.GuestLoginResponse) {
+	fmt.Printf("Access token:  %s\n", result.AccessToken)
+	fmt.Printf("Refresh token: %s\n", result.RefreshToken)
+}
@@ -1,26 +1,108 @@
 package config
 
 import (
+	"context"
 	"os"
 	"strconv"
 	"time"
 
 	"github.com/OkanUysal/go-cache"
 	"github.com/OkanUysal/go-logger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
-var Cache *cache.Cache
+// cacheInstrumentationName identifies the cache spans' tracer
+const cacheInstrumentationName = "github.com/OkanUysal/go-starter-example-project/config/cache"
 
-// InitCache initializes the cache based on environment configuration
-func InitCache() error {
+// Cache is the application cache. It wraps the go-cache client, bounds each
+// get, set and delete by a timeout and records a span for each when tracing is enabled.
+type Cache struct {
+	*cache.Cache
+	timeout time.Duration
+	tracer  trace.Tracer
+}
+
+// NewCache wraps c for use by the services. A zero timeout leaves calls bounded
+// only by their context; spans are only recorded when tracerProvider is non-nil.
+func NewCache(c *cache.Cache, timeout time.Duration, tracerProvider trace.TracerProvider) *Cache {
+	if tracerProvider == nil {
+		tracerProvider = noop.NewTracerProvider()
+	}
+	return &Cache{
+		Cache:   c,
+		timeout: timeout,
+		tracer:  tracerProvider.Tracer(cacheInstrumentationName),
+	}
+}
+
+// GetJSON reads and decodes a cached value. Callers treat any error as a miss,
+// so it is recorded as cache.hit=false rather than as a span error.
+func (c *Cache) GetJSON(ctx context.Context, key string, dest interface{}) error {
+	ctx, span, cancel := c.start(ctx, "cache.get", key)
+	defer cancel()
+	defer span.End()
+
+	err := c.Cache.GetJSON(ctx, key, dest)
+	span.SetAttributes(attribute.Bool("cache.hit", err == nil))
+	return err
+}
+
+// SetJSON encodes and stores a value, using the default TTL unless one is given
+func (c *Cache) SetJSON(ctx context.Context, key string, value interface{}, ttl ...time.Duration) error {
+	ctx, span, cancel := c.start(ctx, "cache.set", key)
+	defer cancel()
+	defer span.End()
+
+	err := c.Cache.SetJSON(ctx, key, value, ttl...)
+	recordCacheError(span, err)
+	return err
+}
+
+// Delete removes a key
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	ctx, span, cancel := c.start(ctx, "cache.delete", key)
+	defer cancel()
+	defer span.End()
+
+	err := c.Cache.Delete(ctx, key)
+	recordCacheError(span, err)
+	return err
+}
+
+// start opens a client span for one cache operation and applies the timeout
+func (c *Cache) start(ctx context.Context, name, key string) (context.Context, trace.Span, context.CancelFunc) {
+	cancel := context.CancelFunc(func() {})
+	if c.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+	}
+	ctx, span := c.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("cache.key", key)))
+	return ctx, span, cancel
+}
+
+// recordCacheError marks the span as failed if err is non-nil
+func recordCacheError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// InitCache creates the cache based on environment configuration
+func InitCache(log *logger.Logger) (*cache.Cache, error) {
 	cacheType := GetEnv("CACHE_TYPE", "memory")
 	ttlSeconds := GetEnv("CACHE_TTL", "300")
 
-	Logger.Info("Cache configuration", logger.String("type", cacheType), logger.String("ttl", ttlSeconds))
+	log.Info("Cache configuration", logger.String("type", cacheType), logger.String("ttl", ttlSeconds))
 
 	ttl, err := strconv.Atoi(ttlSeconds)
 	if err != nil || ttl <= 0 {
-		Logger.Warn("Invalid TTL value, using default", logger.String("value", ttlSeconds), logger.Int("default", 300))
+		log.Warn("Invalid TTL value, using default", logger.String("value", ttlSeconds), logger.Int("default", 300))
 		ttl = 300 // default 5 minutes
 	}
 
@@ -34,7 +116,7 @@ func InitCache() error {
 	if cacheType == "redis" {
 		redisURL := os.Getenv("REDIS_URL")
 		if redisURL == "" {
-			Logger.Warn("REDIS_URL not set, falling back to memory cache")
+			log.Warn("REDIS_URL not set, falling back to memory cache")
 			config.Backend = cache.BackendMemory
 		} else {
 			config.Backend = cache.BackendRedis
@@ -42,19 +124,27 @@ func InitCache() error {
 		}
 	}
 
-	Cache, err = cache.New(config)
+	c, err := cache.New(config)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	Logger.Info("Cache initialized successfully",
+	log.Info("Cache initialized successfully",
 		logger.String("backend", string(config.Backend)),
 		logger.Int("ttl_seconds", ttl))
 
-	return nil
+	return c, nil
 }
 
-// GetCache returns the cache instance
-func GetCache() *cache.Cache {
-	return Cache
+// CloseCache releases the cache backend (e.g. the Redis connection pool)
+func CloseCache(c *cache.Cache) error {
+	if c == nil {
+		return nil
+	}
+
+	// Only some backends hold resources that need closing
+	if closer, ok := any(c).(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 // Feature flags
@@ -10,12 +11,154 @@ var (
 	// RoomAuthEnabled enables room authorization feature
 	// When enabled, users need explicit permission from admin to join game rooms
 	RoomAuthEnabled bool
+
+	// AutoMigrateEnabled applies pending database migrations on startup
+	// Replicas coordinate through a Postgres advisory lock
+	AutoMigrateEnabled bool
+)
+
+// Server settings
+var (
+	// ShutdownTimeout bounds how long graceful shutdown waits for in-flight work
+	ShutdownTimeout time.Duration
+
+	// HealthCheckTimeout bounds each readiness check
+	HealthCheckTimeout time.Duration
+
+	// HealthCacheTTL is how long readiness results are reused between probes
+	HealthCacheTTL time.Duration
+)
+
+// Observability settings
+var (
+	// ServiceName identifies this service in metrics and traces
+	ServiceName string
+
+	// TracingExporter selects where spans are sent: none, otlp or stdout
+	TracingExporter string
+)
+
+// Database settings
+var (
+	// DBMaxOpenConns caps open connections per pool (primary and each replica)
+	DBMaxOpenConns int
+
+	// DBMaxIdleConns is how many idle connections each pool keeps
+	DBMaxIdleConns int
+
+	// DBConnMaxLifetime recycles connections after this long
+	DBConnMaxLifetime time.Duration
+
+	// DBConnMaxIdleTime closes connections idle for this long
+	DBConnMaxIdleTime time.Duration
+
+	// DBLogLevel is the SQL log level: silent, error, warn or info (every query)
+	DBLogLevel string
+
+	// DBSlowQueryThreshold logs queries slower than this at warn level
+	DBSlowQueryThreshold time.Duration
+
+	// DBQueryTimeout bounds each repository call, on top of the request's own deadline
+	DBQueryTimeout time.Duration
+)
+
+// Cache settings
+var (
+	// CacheTimeout bounds each cache get, set and delete
+	CacheTimeout time.Duration
+)
+
+// WebSocket settings
+var (
+	// WebSocketBackplane shares rooms between replicas: memory (single node) or redis (uses REDIS_URL)
+	WebSocketBackplane string
+
+	// WebSocketHistoryStore keeps chat history: memory (a ring buffer per room) or database
+	WebSocketHistoryStore string
+
+	// WebSocketHistorySize is how many messages per room the memory history keeps
+	WebSocketHistorySize int
+
+	// WebSocketHistoryOnJoin is how many recent messages a user receives on joining a room
+	WebSocketHistoryOnJoin int
+
+	// WebSocketTypingTimeout is how long a typing indicator lasts unless renewed
+	WebSocketTypingTimeout time.Duration
+
+	// WebSocketReplayBufferSize is how many recent messages per room are kept for clients resuming after a reconnect
+	WebSocketReplayBufferSize int
+
+	// WebSocketMessageRate is how many messages per second each user may send on average, in bursts of up to WebSocketMessageBurst
+	WebSocketMessageRate  int
+	WebSocketMessageBurst int
+
+	// WebSocketCompression compresses messages of at least WebSocketCompressionMinSize bytes for clients that offer it
+	WebSocketCompression        bool
+	WebSocketCompressionMinSize int
+
+	// WebSocketConnectionPolicy decides what happens when a user holding WebSocketMaxConnections connections opens another one
+	WebSocketConnectionPolicy string
+	WebSocketMaxConnections   int
 )
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() {
 	// Load room authorization feature flag (default: false)
 	RoomAuthEnabled = getEnvBool("ROOM_AUTH_ENABLED", false)
+
+	// Load auto-migrate feature flag (default: false)
+	AutoMigrateEnabled = getEnvBool("AUTO_MIGRATE", false)
+
+	// Load graceful shutdown deadline (default: 30 seconds)
+	ShutdownTimeout = time.Duration(getEnvInt("SHUTDOWN_TIMEOUT", 30)) * time.Second
+
+	// Load readiness check settings (default: 2 second timeout, 5 second cache)
+	HealthCheckTimeout = time.Duration(getEnvInt("HEALTH_CHECK_TIMEOUT", 2)) * time.Second
+	HealthCacheTTL = time.Duration(getEnvInt("HEALTH_CACHE_TTL", 5)) * time.Second
+
+	// Load connection pool settings (default: 25 open, 10 idle, 30 minute lifetime, 5 minute idle time)
+	DBMaxOpenConns = getEnvInt("DB_MAX_OPEN_CONNS", 25)
+	DBMaxIdleConns = getEnvInt("DB_MAX_IDLE_CONNS", 10)
+	DBConnMaxLifetime = time.Duration(getEnvInt("DB_CONN_MAX_LIFETIME", 1800)) * time.Second
+	DBConnMaxIdleTime = time.Duration(getEnvInt("DB_CONN_MAX_IDLE_TIME", 300)) * time.Second
+
+	// Load SQL logging settings (default: warn, which logs errors and slow queries over 200ms)
+	DBLogLevel = GetEnv("DB_LOG_LEVEL", "warn")
+	DBSlowQueryThreshold = time.Duration(getEnvInt("DB_SLOW_QUERY_MS", 200)) * time.Millisecond
+
+	// Load per-operation timeouts (default: 5 seconds per query, 500ms per cache call)
+	DBQueryTimeout = time.Duration(getEnvInt("DB_QUERY_TIMEOUT_MS", 5000)) * time.Millisecond
+	CacheTimeout = time.Duration(getEnvInt("CACHE_TIMEOUT_MS", 500)) * time.Millisecond
+
+	// Load observability settings (default: tracing disabled)
+	ServiceName = GetEnv("SERVICE_NAME", "go-starter-example-project")
+	TracingExporter = GetEnv("TRACING_EXPORTER", "none")
+
+	// Load WebSocket backplane (default: memory, a single node)
+	WebSocketBackplane = GetEnv("WS_BACKPLANE", "memory")
+
+	// Load chat history settings (default: the latest 100 messages per room in memory, 50 sent on join)
+	WebSocketHistoryStore = GetEnv("WS_HISTORY_STORE", "memory")
+	WebSocketHistorySize = getEnvInt("WS_HISTORY_SIZE", 100)
+	WebSocketHistoryOnJoin = getEnvInt("WS_HISTORY_ON_JOIN", 50)
+
+	// Load typing indicator expiry (default: 5 seconds)
+	WebSocketTypingTimeout = time.Duration(getEnvInt("WS_TYPING_TIMEOUT_MS", 5000)) * time.Millisecond
+
+	// Load the resume replay buffer size (default: 256 messages per room)
+	WebSocketReplayBufferSize = getEnvInt("WS_REPLAY_BUFFER_SIZE", 256)
+
+	// Load the per-user message rate limit (default: 20 per second, bursts of 40)
+	WebSocketMessageRate = getEnvInt("WS_MESSAGE_RATE", 20)
+	WebSocketMessageBurst = getEnvInt("WS_MESSAGE_BURST", 40)
+
+	// Load per-message compression (default: off; messages of 256 bytes or more when on)
+	WebSocketCompression = getEnvBool("WS_COMPRESSION_ENABLED", false)
+	WebSocketCompressionMinSize = getEnvInt("WS_COMPRESSION_MIN_SIZE", 256)
+
+	// Load the per-user connection policy (default: any number of devices; one connection under kick_oldest and reject_new)
+	WebSocketConnectionPolicy = GetEnv("WS_CONNECTION_POLICY", "allow_many")
+	WebSocketMaxConnections = getEnvInt("WS_MAX_CONNECTIONS_PER_USER", 1)
 }
 
 // getEnvBool gets boolean value from environment variable
@@ -27,3 +170,13 @@ func getEnvBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+// getEnvInt gets positive integer value from environment variable
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return defaultValue
+}
@@ -1,22 +1,21 @@
 package config
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/OkanUysal/go-logger"
+	"github.com/glebarez/sqlite"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	gormlogger "gorm.io/gorm/logger"
 )
 
-var (
-	DB     *gorm.DB
-	Logger *logger.Logger
-)
-
 // LoadEnv loads environment variables from .env file
 func LoadEnv() {
 	if err := godotenv.Load(); err != nil {
@@ -25,30 +24,218 @@ func LoadEnv() {
 	}
 }
 
-// ConnectDatabase establishes a connection to the PostgreSQL database
-func ConnectDatabase() error {
-	// Get DATABASE_URL_LOCAL from environment
-	dsn := os.Getenv("DATABASE_URL_LOCAL")
-	if dsn == "" {
-		return fmt.Errorf("DATABASE_URL_LOCAL environment variable is not set")
+// NewLogger creates the application logger
+func NewLogger() *logger.Logger {
+	return logger.New(&logger.Config{
+		Level:      logger.LevelInfo,
+		Format:     logger.FormatJSON,
+		TimeFormat: "2006-01-02 15:04:05",
+	})
+}
+
+// Supported database drivers, selected with DB_DRIVER
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// DatabaseDriver knows how to open one kind of database from environment variables
+type DatabaseDriver struct {
+	// Open builds the GORM dialector, failing if required settings are missing
+	Open func() (gorm.Dialector, error)
+
+	// ConfigurePool tunes the connection pool after connecting (optional)
+	ConfigurePool func(sqlDB *sql.DB)
+}
+
+// databaseDrivers holds every supported driver by DB_DRIVER name
+var databaseDrivers = map[string]DatabaseDriver{
+	DriverPostgres: {
+		Open: openPostgres,
+	},
+	DriverSQLite: {
+		Open: openSQLite,
+		// A single connection serializes writes and keeps ":memory:" databases shared
+		ConfigurePool: func(sqlDB *sql.DB) {
+			sqlDB.SetMaxOpenConns(1)
+		},
+	},
+}
+
+// DatabaseDriverName returns the configured database driver (default: postgres)
+func DatabaseDriverName() string {
+	return GetEnv("DB_DRIVER", DriverPostgres)
+}
+
+// GetDatabaseDriver returns the driver registered under name
+func GetDatabaseDriver(name string) (DatabaseDriver, error) {
+	driver, ok := databaseDrivers[name]
+	if !ok {
+		return DatabaseDriver{}, fmt.Errorf("unknown database driver %q (supported: %s, %s)", name, DriverPostgres, DriverSQLite)
+	}
+	return driver, nil
+}
+
+// ConnectDatabase establishes a connection to the primary database selected by DB_DRIVER
+func ConnectDatabase(log *logger.Logger) (*gorm.DB, error) {
+	name := DatabaseDriverName()
+	driver, err := GetDatabaseDriver(name)
+	if err != nil {
+		return nil, err
+	}
+
+	dialector, err := driver.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := openPool(dialector, driver)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	log.Info("Database connection established successfully",
+		logger.String("driver", name),
+		logger.Int("max_open_conns", DBMaxOpenConns),
+		logger.Int("max_idle_conns", DBMaxIdleConns),
+		logger.String("log_level", DBLogLevel))
+	return db, nil
+}
+
+// ConnectReplicas opens the read replicas listed in DATABASE_REPLICA_URLS (comma-separated).
+// It returns no replicas when the variable is unset.
+func ConnectReplicas(log *logger.Logger) ([]*gorm.DB, error) {
+	var dsns []string
+	for _, dsn := range strings.Split(os.Getenv("DATABASE_REPLICA_URLS"), ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			dsns = append(dsns, dsn)
+		}
+	}
+	if len(dsns) == 0 {
+		return nil, nil
+	}
+
+	if name := DatabaseDriverName(); name != DriverPostgres {
+		return nil, fmt.Errorf("read replicas are only supported with the %s driver, not %s", DriverPostgres, name)
+	}
+
+	replicas := make([]*gorm.DB, 0, len(dsns))
+	for i, dsn := range dsns {
+		db, err := openPool(postgres.Open(dsn), databaseDrivers[DriverPostgres])
+		if err != nil {
+			CloseDatabases(replicas)
+			return nil, fmt.Errorf("failed to connect to read replica %d: %w", i+1, err)
+		}
+		replicas = append(replicas, db)
 	}
 
-	// Connect to database
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: gormlogger.Default.LogMode(gormlogger.Info),
+	log.Info("Read replicas connected", logger.Int("replica_count", len(replicas)))
+	return replicas, nil
+}
+
+// openPool opens a GORM connection and applies the pool settings,
+// followed by any driver-specific overrides
+func openPool(dialector gorm.Dialector, driver DatabaseDriver) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger: newSQLLogger(),
+		// Map driver errors (e.g. unique violations) to gorm.ErrDuplicatedKey
+		TranslateError: true,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection pool: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(DBMaxOpenConns)
+	if DBMaxIdleConns > 0 {
+		// Zero would disable idle connections entirely rather than keep the default
+		sqlDB.SetMaxIdleConns(DBMaxIdleConns)
+	}
+	sqlDB.SetConnMaxLifetime(DBConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(DBConnMaxIdleTime)
+
+	if driver.ConfigurePool != nil {
+		driver.ConfigurePool(sqlDB)
+	}
+
+	return db, nil
+}
+
+// sqlLogLevels maps DB_LOG_LEVEL values to GORM log levels
+var sqlLogLevels = map[string]gormlogger.LogLevel{
+	"silent": gormlogger.Silent,
+	"error":  gormlogger.Error,
+	"warn":   gormlogger.Warn,
+	"info":   gormlogger.Info,
+}
+
+// ParseSQLLogLevel converts a DB_LOG_LEVEL value to a GORM log level
+func ParseSQLLogLevel(level string) (gormlogger.LogLevel, error) {
+	parsed, ok := sqlLogLevels[strings.ToLower(level)]
+	if !ok {
+		return gormlogger.Warn, fmt.Errorf("unknown SQL log level %q (use silent, error, warn or info)", level)
+	}
+	return parsed, nil
+}
+
+// newSQLLogger creates the GORM logger from DB_LOG_LEVEL and DB_SLOW_QUERY_MS.
+// Unknown levels fall back to warn; "record not found" is expected and not logged.
+func newSQLLogger() gormlogger.Interface {
+	level, _ := ParseSQLLogLevel(DBLogLevel)
+
+	return gormlogger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), gormlogger.Config{
+		SlowThreshold:             DBSlowQueryThreshold,
+		LogLevel:                  level,
+		IgnoreRecordNotFoundError: true,
+		Colorful:                  true,
+	})
+}
+
+// openPostgres connects to the Postgres server at DATABASE_URL_LOCAL
+func openPostgres() (gorm.Dialector, error) {
+	dsn := os.Getenv("DATABASE_URL_LOCAL")
+	if dsn == "" {
+		return nil, fmt.Errorf("DATABASE_URL_LOCAL environment variable is not set")
 	}
+	return postgres.Open(dsn), nil
+}
+
+// openSQLite opens the SQLite file at SQLITE_PATH (default: example.db), or ":memory:"
+func openSQLite() (gorm.Dialector, error) {
+	dsn := GetEnv("SQLITE_PATH", "example.db")
 
-	Logger.Info("Database connection established successfully")
-	return nil
+	// Wait on locks instead of failing, unless the DSN sets its own options
+	if !strings.Contains(dsn, "?") {
+		dsn += "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+	}
+	return sqlite.Open(dsn), nil
+}
+
+// CloseDatabase closes the underlying connection pool
+func CloseDatabase(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
 
-// GetDB returns the database instance
-func GetDB() *gorm.DB {
-	return DB
+// CloseDatabases closes several connection pools, e.g. the read replicas
+func CloseDatabases(dbs []*gorm.DB) error {
+	var errs []error
+	for _, db := range dbs {
+		if err := CloseDatabase(db); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // GetEnv returns environment variable value or default
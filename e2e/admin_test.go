@@ -0,0 +1,60 @@
+package e2e
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAdminGating(t *testing.T) {
+	h := newHarness(t)
+	guest := h.guestLogin()
+
+	endpoints := []struct {
+		method string
+		path   string
+		body   any
+	}{
+		{http.MethodGet, "/api/admin/dashboard", nil},
+		{http.MethodGet, "/api/admin/users", nil},
+		{http.MethodPost, "/api/ws/rooms", map[string]any{"name": "Gated"}},
+		{http.MethodPost, "/api/ws/invite", map[string]any{"room_id": "lobby", "user_ids": []string{guest.User.ID}}},
+	}
+
+	for _, ep := range endpoints {
+		if status, _ := h.do(ep.method, ep.path, "", ep.body); status != http.StatusUnauthorized {
+			t.Errorf("%s %s without token status = %d, want 401", ep.method, ep.path, status)
+		}
+		if status, _ := h.do(ep.method, ep.path, guest.AccessToken, ep.body); status != http.StatusForbidden {
+			t.Errorf("%s %s as guest status = %d, want 403", ep.method, ep.path, status)
+		}
+	}
+}
+
+func TestAdminDashboardAndUsers(t *testing.T) {
+	h := newHarness(t)
+	h.guestLogin()
+	h.guestLogin()
+	admin := h.admin()
+
+	var dashboard struct {
+		Statistics struct {
+			TotalUsers int64 `json:"total_users"`
+			AdminCount int64 `json:"admin_count"`
+			GuestCount int64 `json:"guest_count"`
+		} `json:"statistics"`
+	}
+	h.mustDo(http.MethodGet, "/api/admin/dashboard", admin.AccessToken, nil, http.StatusOK, &dashboard)
+
+	stats := dashboard.Statistics
+	if stats.TotalUsers != 3 || stats.AdminCount != 1 || stats.GuestCount != 2 {
+		t.Errorf("dashboard statistics = %+v, want 3 total, 1 admin, 2 guests", stats)
+	}
+
+	var users struct {
+		Count int `json:"count"`
+	}
+	h.mustDo(http.MethodGet, "/api/admin/users", admin.AccessToken, nil, http.StatusOK, &users)
+	if users.Count != 3 {
+		t.Errorf("user count = %d, want 3", users.Count)
+	}
+}
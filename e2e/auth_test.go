@@ -0,0 +1,198 @@
+package e2e
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/OkanUysal/go-starter-example-project/app"
+	"github.com/OkanUysal/go-starter-example-project/auth"
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+)
+
+func TestGuestLogin(t *testing.T) {
+	h := newHarness(t)
+
+	session := h.guestLogin()
+	if session.AccessToken == "" || session.RefreshToken == "" {
+		t.Fatal("guest login returned empty tokens")
+	}
+	if !session.User.IsGuest || session.User.Role != models.RoleUser || session.User.GuestID == nil {
+		t.Fatalf("unexpected guest user: %+v", session.User)
+	}
+
+	var me models.User
+	h.mustDo(http.MethodGet, "/api/auth/me", session.AccessToken, nil, http.StatusOK, &me)
+	if me.ID != session.User.ID {
+		t.Errorf("/me returned user %s, want %s", me.ID, session.User.ID)
+	}
+
+	// Logging in again with the guest ID returns the same user
+	var again auth.GuestLoginResponse
+	h.mustDo(http.MethodPost, "/api/auth/guest-login", "",
+		auth.GuestLoginRequest{GuestID: session.User.GuestID}, http.StatusOK, &again)
+	if again.User.ID != session.User.ID {
+		t.Errorf("returning guest got user %s, want %s", again.User.ID, session.User.ID)
+	}
+}
+
+func TestProtectedEndpointsRequireToken(t *testing.T) {
+	h := newHarness(t)
+
+	for _, token := range []string{"", "not-a-jwt"} {
+		if status, _ := h.do(http.MethodGet, "/api/auth/me", token, nil); status != http.StatusUnauthorized {
+			t.Errorf("/me with token %q status = %d, want 401", token, status)
+		}
+	}
+
+	// An access token is not accepted as a refresh token
+	session := h.guestLogin()
+	status, _ := h.do(http.MethodPost, "/api/auth/refresh", "", auth.RefreshTokenRequest{RefreshToken: session.AccessToken})
+	if status != http.StatusUnauthorized {
+		t.Errorf("refresh with access token status = %d, want 401", status)
+	}
+}
+
+func TestRefreshRotation(t *testing.T) {
+	h := newHarness(t)
+	session := h.guestLogin()
+
+	// Use the access token once so its "not revoked" result is cached
+	h.mustDo(http.MethodGet, "/api/auth/me", session.AccessToken, nil, http.StatusOK, nil)
+
+	var refreshed auth.GuestLoginResponse
+	h.mustDo(http.MethodPost, "/api/auth/refresh", "",
+		auth.RefreshTokenRequest{RefreshToken: session.RefreshToken}, http.StatusOK, &refreshed)
+	if refreshed.AccessToken == session.AccessToken || refreshed.RefreshToken == session.RefreshToken {
+		t.Fatal("refresh did not issue new tokens")
+	}
+	if refreshed.User.ID != session.User.ID {
+		t.Errorf("refresh returned user %s, want %s", refreshed.User.ID, session.User.ID)
+	}
+
+	// The new pair works
+	h.mustDo(http.MethodGet, "/api/auth/me", refreshed.AccessToken, nil, http.StatusOK, nil)
+
+	// The old family is revoked: neither the old refresh nor the old access token work
+	status, _ := h.do(http.MethodPost, "/api/auth/refresh", "", auth.RefreshTokenRequest{RefreshToken: session.RefreshToken})
+	if status != http.StatusUnauthorized {
+		t.Errorf("reusing old refresh token status = %d, want 401", status)
+	}
+	if status, _ := h.do(http.MethodGet, "/api/auth/me", session.AccessToken, nil); status != http.StatusUnauthorized {
+		t.Errorf("old access token status = %d, want 401", status)
+	}
+}
+
+func TestRevokedTokenRejected(t *testing.T) {
+	h := newHarness(t)
+	session := h.guestLogin()
+
+	h.mustDo(http.MethodGet, "/api/auth/me", session.AccessToken, nil, http.StatusOK, nil)
+
+	access, err := auth.ValidateToken(session.AccessToken)
+	if err != nil {
+		t.Fatalf("failed to parse access token: %v", err)
+	}
+	refresh, err := auth.ValidateRefreshToken(session.RefreshToken)
+	if err != nil {
+		t.Fatalf("failed to parse refresh token: %v", err)
+	}
+
+	err = h.app.Auth.BlacklistTokenPair(context.Background(), access.ID, refresh.ID, session.User.ID,
+		access.ExpiresAt.Time, refresh.ExpiresAt.Time)
+	if err != nil {
+		t.Fatalf("failed to revoke token pair: %v", err)
+	}
+
+	// The "not revoked" result cached by the first request must not outlive the revocation
+	if status, _ := h.do(http.MethodGet, "/api/auth/me", session.AccessToken, nil); status != http.StatusUnauthorized {
+		t.Errorf("revoked access token status = %d, want 401", status)
+	}
+	status, _ := h.do(http.MethodPost, "/api/auth/refresh", "", auth.RefreshTokenRequest{RefreshToken: session.RefreshToken})
+	if status != http.StatusUnauthorized {
+		t.Errorf("revoked refresh token status = %d, want 401", status)
+	}
+}
+
+// flakyRevocations is a revocation store whose writes or reads can be made to fail
+type flakyRevocations struct {
+	repository.RevocationRepository
+	failWrites, failReads atomic.Bool
+}
+
+func (r *flakyRevocations) Revoke(ctx context.Context, tokens ...models.TokenBlacklist) error {
+	if r.failWrites.Load() {
+		return errors.New("connection refused")
+	}
+	return r.RevocationRepository.Revoke(ctx, tokens...)
+}
+
+func (r *flakyRevocations) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	if r.failReads.Load() {
+		return false, errors.New("connection refused")
+	}
+	return r.RevocationRepository.IsRevoked(ctx, jti)
+}
+
+func TestRevocationIsCachedOnlyOnceStored(t *testing.T) {
+	store := &flakyRevocations{RevocationRepository: repository.NewMemoryRevocationRepository()}
+	h := newHarness(t, func(opts *app.Options) { opts.Revocations = store })
+	session := h.guestLogin()
+	access, err := auth.ValidateToken(session.AccessToken)
+	if err != nil {
+		t.Fatalf("failed to parse access token: %v", err)
+	}
+
+	// A revocation that wasn't stored leaves the cached "not revoked" in place
+	h.mustDo(http.MethodGet, "/api/auth/me", session.AccessToken, nil, http.StatusOK, nil)
+	store.failWrites.Store(true)
+	if err := h.app.Auth.BlacklistToken(context.Background(), access.ID, session.User.ID, access.ExpiresAt.Time); err == nil {
+		t.Fatal("BlacklistToken succeeded while the store was down")
+	}
+	h.mustDo(http.MethodGet, "/api/auth/me", session.AccessToken, nil, http.StatusOK, nil)
+
+	// A stored one is cached, so it's enforced even while the store can't be read
+	store.failWrites.Store(false)
+	if err := h.app.Auth.BlacklistToken(context.Background(), access.ID, session.User.ID, access.ExpiresAt.Time); err != nil {
+		t.Fatalf("BlacklistToken: %v", err)
+	}
+	store.failReads.Store(true)
+	if status, _ := h.do(http.MethodGet, "/api/auth/me", session.AccessToken, nil); status != http.StatusUnauthorized {
+		t.Errorf("revoked access token status = %d, want 401", status)
+	}
+}
+
+// unreachableRevocations is a revocation store whose lookups fail, like a database that is down
+type unreachableRevocations struct {
+	repository.RevocationRepository
+}
+
+func (unreachableRevocations) IsRevoked(context.Context, string) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func (unreachableRevocations) IsFamilyRevoked(context.Context, string) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestRevocationCheckFailsClosed(t *testing.T) {
+	h := newHarness(t, func(opts *app.Options) {
+		opts.Revocations = unreachableRevocations{repository.NewMemoryRevocationRepository()}
+	})
+	session := h.guestLogin()
+
+	// Tokens that can't be checked are refused, not trusted, and nothing is rotated
+	if status, _ := h.do(http.MethodGet, "/api/auth/me", session.AccessToken, nil); status != http.StatusServiceUnavailable {
+		t.Errorf("/me while revocations are unavailable status = %d, want 503", status)
+	}
+	status, _ := h.do(http.MethodPost, "/api/auth/refresh", "", auth.RefreshTokenRequest{RefreshToken: session.RefreshToken})
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("refresh while revocations are unavailable status = %d, want 503", status)
+	}
+	if _, status, _ := h.dialWS(session.AccessToken, ""); status != http.StatusServiceUnavailable {
+		t.Errorf("websocket connect while revocations are unavailable status = %d, want 503", status)
+	}
+}
@@ -0,0 +1,389 @@
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OkanUysal/go-starter-example-project/app"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+	"github.com/OkanUysal/go-starter-example-project/websocket"
+)
+
+// newCluster starts n servers sharing a backplane and the user, revocation,
+// room, direct message and block stores, like replicas behind a load balancer
+func newCluster(t *testing.T, n int, configure ...func(*app.Options)) []*harness {
+	t.Helper()
+
+	backplane := websocket.NewMemoryBackplane()
+	users := repository.NewMemoryUserRepository()
+	revocations := repository.NewMemoryRevocationRepository()
+	rooms := repository.NewMemoryRoomRepository()
+	directMessages := repository.NewMemoryDirectMessageRepository()
+	blocks := repository.NewMemoryBlockRepository()
+
+	nodes := make([]*harness, n)
+	for i := range nodes {
+		nodes[i] = newHarness(t, append([]func(*app.Options){func(opts *app.Options) {
+			opts.Backplane = backplane
+			opts.Users = users
+			opts.Revocations = revocations
+			opts.RoomStore = rooms
+			opts.DirectMessages = directMessages
+			opts.Blocks = blocks
+		}}, configure...)...)
+	}
+	return nodes
+}
+
+// room fetches a room's information from one node
+func (h *harness) room(token, roomID string) websocket.RoomInfo {
+	h.t.Helper()
+
+	var info struct {
+		Room websocket.RoomInfo `json:"room"`
+	}
+	h.mustDo(http.MethodGet, "/api/ws/rooms/"+roomID, token, nil, http.StatusOK, &info)
+	return info.Room
+}
+
+func TestClusterSharesRoomsAndCounts(t *testing.T) {
+	nodes := newCluster(t, 2)
+	a, b := nodes[0], nodes[1]
+	admin := a.admin()
+	guest := a.guestLogin()
+
+	// A room created on one node is listed and joinable on the other
+	var created struct {
+		Room websocket.RoomInfo `json:"room"`
+	}
+	a.mustDo(http.MethodPost, "/api/ws/rooms", admin.AccessToken,
+		map[string]any{"name": "Duel", "max_players": 2}, http.StatusOK, &created)
+	roomID := created.Room.ID
+
+	var listed struct {
+		Rooms []websocket.RoomInfo `json:"rooms"`
+	}
+	b.mustDo(http.MethodGet, "/api/ws/rooms", guest.AccessToken, nil, http.StatusOK, &listed)
+	found := false
+	for _, room := range listed.Rooms {
+		found = found || room.ID == roomID
+	}
+	if !found {
+		t.Fatalf("room created on node A is not listed on node B")
+	}
+
+	onA := a.connect(admin, roomID)
+	onB := b.connect(guest, roomID)
+
+	// Both nodes count both players
+	for name, node := range map[string]*harness{"A": a, "B": b} {
+		if room := node.room(guest.AccessToken, roomID); room.PlayerCount != 2 || len(room.Users) != 2 {
+			t.Errorf("node %s player count = %d with %d users, want 2", name, room.PlayerCount, len(room.Users))
+		}
+	}
+
+	// The guest's join on B was broadcast to the admin on A, and chat flows both ways
+	onA.expect("join", func(msg wsMessage) bool { return msg.Data["user_id"] == guest.User.ID })
+	onA.send("chat", map[string]any{"room_id": roomID, "content": "from A"})
+	onB.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == "from A" })
+	onB.send("chat", map[string]any{"room_id": roomID, "content": "from B"})
+	onA.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == "from B" })
+
+	// Disconnecting from B frees the slot on A too
+	onB.conn.Close()
+	a.waitFor("guest to leave", func() bool { return a.room(admin.AccessToken, roomID).PlayerCount == 1 })
+}
+
+func TestClusterEnforcesRoomLimits(t *testing.T) {
+	nodes := newCluster(t, 2)
+	a, b := nodes[0], nodes[1]
+	admin := a.admin()
+	guest := a.guestLogin()
+
+	var created struct {
+		Room websocket.RoomInfo `json:"room"`
+	}
+	a.mustDo(http.MethodPost, "/api/ws/rooms", admin.AccessToken,
+		map[string]any{"name": "Solo", "max_players": 1}, http.StatusOK, &created)
+	a.connect(admin, created.Room.ID)
+
+	// The only slot is taken on A, so B turns the guest away
+	client := b.connect(guest, websocket.LobbyRoomID)
+	client.send("join", map[string]any{"room_id": created.Room.ID})
+	msg := client.expect("error", nil)
+	if got, _ := msg.Data["message"].(string); !strings.Contains(got, "full") {
+		t.Errorf("join error = %q, want room is full", got)
+	}
+}
+
+func TestClusterConcurrentJoinsRespectRoomLimit(t *testing.T) {
+	nodes := newCluster(t, 2)
+	admin := nodes[0].admin()
+	var created struct {
+		Room websocket.RoomInfo `json:"room"`
+	}
+	nodes[0].mustDo(http.MethodPost, "/api/ws/rooms", admin.AccessToken,
+		map[string]any{"name": "Rush", "max_players": 2}, http.StatusOK, &created)
+	roomID := created.Room.ID
+
+	// Players waiting in the lobbies of both nodes all join at once
+	clients := make([]*wsClient, 8)
+	for i := range clients {
+		clients[i] = nodes[i%2].connect(nodes[i%2].guestLogin(), websocket.LobbyRoomID)
+	}
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.conn.WriteJSON(wsMessage{Type: "join", Data: map[string]any{"room_id": roomID}}); err != nil {
+				t.Errorf("failed to send join: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Each is either told it joined or that the room is full
+	joined := 0
+	for _, client := range clients {
+		timeout := time.After(messageTimeout)
+		for answered := false; !answered; {
+			select {
+			case msg := <-client.messages:
+				switch {
+				case msg.Type == "join" && msg.Data["room_id"] == roomID:
+					joined++
+					answered = true
+				case msg.Type == "error":
+					if msg.Data["message"] != "room is full" {
+						t.Errorf("join error = %v, want room is full", msg.Data["message"])
+					}
+					answered = true
+				}
+			case <-timeout:
+				t.Fatal("timed out waiting for a join or an error")
+			}
+		}
+	}
+	if joined != 2 {
+		t.Errorf("%d of %d concurrent joins succeeded, want 2", joined, len(clients))
+	}
+	for i, node := range nodes {
+		if count := node.room(admin.AccessToken, roomID).PlayerCount; count != 2 {
+			t.Errorf("node %d player count = %d, want 2", i, count)
+		}
+	}
+}
+
+func TestClusterDeliversInvitesAndClosures(t *testing.T) {
+	nodes := newCluster(t, 2, withRoomAuth)
+	a, b := nodes[0], nodes[1]
+	admin := a.admin()
+	guest := a.guestLogin()
+
+	var created struct {
+		Room websocket.RoomInfo `json:"room"`
+	}
+	a.mustDo(http.MethodPost, "/api/ws/rooms", admin.AccessToken,
+		map[string]any{"name": "Private"}, http.StatusOK, &created)
+	roomID := created.Room.ID
+
+	// The guest is connected to B; the invitation is made on A
+	client := b.connect(guest, websocket.LobbyRoomID)
+	a.mustDo(http.MethodPost, "/api/ws/invite", admin.AccessToken,
+		map[string]any{"room_id": roomID, "user_ids": []string{guest.User.ID}}, http.StatusOK, nil)
+	client.expect("invite", nil)
+
+	// B knows about the invitation, so the guest may join there
+	client.send("join", map[string]any{"room_id": roomID})
+	client.expect("join", func(msg wsMessage) bool {
+		return msg.Data["room_id"] == roomID && msg.Data["user_id"] == guest.User.ID
+	})
+
+	// Closing the room on A reaches its member on B and closes it there too
+	a.mustDo(http.MethodDelete, "/api/ws/rooms/"+roomID, admin.AccessToken, nil, http.StatusOK, nil)
+	client.expect("room_closed", func(msg wsMessage) bool { return msg.Data["room_id"] == roomID })
+	b.waitFor("room to close", func() bool { return !b.room(guest.AccessToken, roomID).IsActive })
+}
+
+func TestClusterDeliversDirectMessages(t *testing.T) {
+	nodes := newCluster(t, 2)
+	a, b := nodes[0], nodes[1]
+	alice := a.guestLogin()
+	bob := a.guestLogin()
+
+	onA := a.connect(alice, websocket.LobbyRoomID)
+	onB := b.connect(bob, websocket.LobbyRoomID)
+
+	// Sent on A, delivered on B, and the read receipt finds its way back
+	onA.send("dm", map[string]any{"to": bob.User.ID, "content": "across"})
+	msg := onB.expect("dm", func(msg wsMessage) bool { return msg.Data["content"] == "across" })
+	onB.send("dm_read", map[string]any{"from": alice.User.ID, "message_id": msg.Data["message_id"]})
+	onA.expect("dm_read", func(msg wsMessage) bool { return msg.Data["user_id"] == bob.User.ID })
+
+	// B recorded the delivery, so reconnecting doesn't deliver it again
+	onB.conn.Close()
+	a.waitFor("bob to disconnect", func() bool { return a.room(alice.AccessToken, websocket.LobbyRoomID).PlayerCount == 1 })
+	onA.send("dm", map[string]any{"to": bob.User.ID, "content": "while away"})
+	onA.expect("dm_sent", nil)
+
+	again := a.connect(bob, websocket.LobbyRoomID)
+	pending := again.expect("dm", nil)
+	if pending.Data["content"] != "while away" {
+		t.Errorf("first dm after reconnecting = %v, want only the one sent while away", pending.Data["content"])
+	}
+}
+
+func TestClusterTracksPresenceAcrossNodes(t *testing.T) {
+	nodes := newCluster(t, 2)
+	a, b := nodes[0], nodes[1]
+	alice := a.guestLogin()
+	bob := a.guestLogin()
+
+	watcher := a.connect(bob, websocket.LobbyRoomID)
+	onA := a.connect(alice, websocket.LobbyRoomID)
+	onB := b.connect(alice, websocket.LobbyRoomID)
+
+	status := func(node *harness) websocket.PresenceStatus {
+		var result struct {
+			Presence []websocket.Presence `json:"presence"`
+		}
+		node.mustDo(http.MethodGet, "/api/presence?user_ids="+alice.User.ID, bob.AccessToken, nil, http.StatusOK, &result)
+		return result.Presence[0].Status
+	}
+
+	// Alice is online, and in the lobby, while any of her connections is
+	onA.conn.Close()
+	a.waitFor("alice's connection to A to close", func() bool {
+		return a.app.Rooms.GetHub().ConnectionCount(alice.User.ID) == 0
+	})
+	if count := a.room(bob.AccessToken, websocket.LobbyRoomID).PlayerCount; count != 2 {
+		t.Errorf("player count with alice still connected to B = %d, want 2", count)
+	}
+	if got := status(a); got != websocket.PresenceOnline {
+		t.Errorf("presence with one connection left = %s, want online", got)
+	}
+
+	// The lobby is told she left once, when her last connection closed
+	leaves := 0
+	watchUntil := func(what string, done func(wsMessage) bool) {
+		timeout := time.After(messageTimeout)
+		for {
+			select {
+			case msg := <-watcher.messages:
+				if msg.Type == "leave" && msg.Data["user_id"] == alice.User.ID {
+					leaves++
+				}
+				if done(msg) {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %s", what)
+			}
+		}
+	}
+	onB.conn.Close()
+	watchUntil("alice to go offline", func(msg wsMessage) bool {
+		return msg.Type == "presence" && msg.Data["user_id"] == alice.User.ID && msg.Data["status"] == "offline"
+	})
+	if got := status(a); got != websocket.PresenceOffline {
+		t.Errorf("presence after both connections closed = %s, want offline", got)
+	}
+	if count := a.room(bob.AccessToken, websocket.LobbyRoomID).PlayerCount; count != 1 {
+		t.Errorf("player count after alice left = %d, want 1", count)
+	}
+
+	watcher.send("chat", map[string]any{"room_id": websocket.LobbyRoomID, "content": "anyone here?"})
+	watchUntil("the chat message", func(msg wsMessage) bool { return msg.Type == "chat" })
+	if leaves != 1 {
+		t.Errorf("alice left the lobby %d times, want once", leaves)
+	}
+}
+
+func TestClusterResumesOnAnotherNode(t *testing.T) {
+	nodes := newCluster(t, 2)
+	a, b := nodes[0], nodes[1]
+	alice := a.guestLogin()
+	bob := a.guestLogin()
+
+	sender := a.connect(alice, websocket.LobbyRoomID)
+	onA := a.connect(bob, websocket.LobbyRoomID)
+
+	sender.send("chat", map[string]any{"room_id": websocket.LobbyRoomID, "content": "before"})
+	seen := onA.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == "before" })
+
+	// Bob drops off A and comes back on B, which kept what A broadcast meanwhile
+	onA.conn.Close()
+	a.waitFor("bob to disconnect", func() bool { return a.room(alice.AccessToken, websocket.LobbyRoomID).PlayerCount == 1 })
+	sender.send("chat", map[string]any{"room_id": websocket.LobbyRoomID, "content": "missed"})
+	sender.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == "missed" })
+
+	onB := b.connect(bob, websocket.LobbyRoomID)
+	onB.send("resume", map[string]any{"room_id": websocket.LobbyRoomID, "last_seq": seen.Seq})
+	if msg := onB.expect("chat", nil); msg.Data["content"] != "missed" || msg.Seq <= seen.Seq {
+		t.Errorf("first replayed chat = %v with seq %d, want missed after seq %d", msg.Data["content"], msg.Seq, seen.Seq)
+	}
+	onB.expect("resumed", nil)
+}
+
+func TestClusterOrdersConcurrentBroadcasts(t *testing.T) {
+	nodes := newCluster(t, 2)
+	admin := nodes[0].admin()
+	room := nodes[0].createRoom(admin.AccessToken, "Busy", 0)
+
+	// A watcher on each node, and players on both sending at once
+	var watchers, senders []*wsClient
+	for _, node := range nodes {
+		watchers = append(watchers, node.connect(node.guestLogin(), room.ID))
+	}
+	for i := range 6 {
+		node := nodes[i%2]
+		senders = append(senders, node.connect(node.guestLogin(), room.ID))
+	}
+	const perSender = 10
+	var wg sync.WaitGroup
+	for i, sender := range senders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range perSender {
+				chat := wsMessage{Type: "chat", Data: map[string]any{"room_id": room.ID, "content": fmt.Sprintf("%d-%d", i, n)}}
+				if err := sender.conn.WriteJSON(chat); err != nil {
+					t.Errorf("failed to send chat: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every watcher receives the room's messages in sequence order, none skipped
+	for i, watcher := range watchers {
+		var last int64
+		timeout := time.After(messageTimeout)
+		for chats := 0; chats < len(senders)*perSender; {
+			select {
+			case msg, ok := <-watcher.messages:
+				if !ok {
+					t.Fatalf("watcher %d disconnected", i)
+				}
+				if msg.Seq == 0 {
+					continue
+				}
+				if last > 0 && msg.Seq != last+1 {
+					t.Fatalf("watcher %d received %s number %d after number %d", i, msg.Type, msg.Seq, last)
+				}
+				last = msg.Seq
+				if msg.Type == "chat" {
+					chats++
+				}
+			case <-timeout:
+				t.Fatalf("watcher %d timed out after %d chats", i, chats)
+			}
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// Package e2e boots the full router against in-memory backends and
+// exercises the HTTP and WebSocket APIs the way a client would.
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OkanUysal/go-cache"
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/app"
+	"github.com/OkanUysal/go-starter-example-project/auth"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+	"github.com/gin-gonic/gin"
+	gorilla "github.com/gorilla/websocket"
+)
+
+// messageTimeout bounds how long a test waits for a WebSocket message
+const messageTimeout = 2 * time.Second
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	os.Setenv("JWT_SECRET", "e2e-test-secret")
+
+	os.Exit(m.Run())
+}
+
+// harness is a running server backed by in-memory repositories and cache
+type harness struct {
+	t      *testing.T
+	app    *app.App
+	server *httptest.Server
+}
+
+// newHarness starts a fresh server; it is shut down when the test ends
+func newHarness(t *testing.T, configure ...func(*app.Options)) *harness {
+	t.Helper()
+
+	c, err := cache.New(&cache.Config{
+		Backend:         cache.BackendMemory,
+		DefaultTTL:      5 * time.Minute,
+		CleanupInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+
+	opts := app.Options{
+		Cache:       c,
+		Logger:      logger.New(logger.DefaultConfig().WithWriter(io.Discard)),
+		Users:       repository.NewMemoryUserRepository(),
+		Revocations: repository.NewMemoryRevocationRepository(),
+	}
+	for _, fn := range configure {
+		fn(&opts)
+	}
+
+	a, err := app.New(opts)
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+	if err := a.Start(); err != nil {
+		t.Fatalf("failed to start app: %v", err)
+	}
+
+	server := httptest.NewServer(a.Router())
+	t.Cleanup(func() {
+		server.Close()
+		a.Rooms.Shutdown(context.Background())
+	})
+
+	return &harness{t: t, app: a, server: server}
+}
+
+// envelope is the go-response body shape
+type envelope struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Message string          `json:"message"`
+}
+
+// do sends a JSON request and returns the status code and decoded envelope
+func (h *harness) do(method, path, token string, body any) (int, envelope) {
+	h.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatalf("failed to encode request body: %v", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, h.server.URL+path, reader)
+	if err != nil {
+		h.t.Fatalf("failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := h.server.Client().Do(req)
+	if err != nil {
+		h.t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil && err != io.EOF {
+		h.t.Fatalf("failed to decode %s %s response: %v", method, path, err)
+	}
+	return resp.StatusCode, env
+}
+
+// mustDo is do that fails the test on an unexpected status and decodes data into dest
+func (h *harness) mustDo(method, path, token string, body any, wantStatus int, dest any) {
+	h.t.Helper()
+
+	status, env := h.do(method, path, token, body)
+	if status != wantStatus {
+		h.t.Fatalf("%s %s status = %d, want %d", method, path, status, wantStatus)
+	}
+	if dest != nil {
+		if err := json.Unmarshal(env.Data, dest); err != nil {
+			h.t.Fatalf("failed to decode %s %s data: %v", method, path, err)
+		}
+	}
+}
+
+// guestLogin logs in a new guest
+func (h *harness) guestLogin() auth.GuestLoginResponse {
+	h.t.Helper()
+
+	var session auth.GuestLoginResponse
+	h.mustDo(http.MethodPost, "/api/auth/guest-login", "", map[string]any{}, http.StatusOK, &session)
+	return session
+}
+
+// admin creates an admin user the same way the CLI does
+func (h *harness) admin() auth.GuestLoginResponse {
+	h.t.Helper()
+
+	session, err := h.app.Auth.CreateAdmin(context.Background(), "Test Admin")
+	if err != nil {
+		h.t.Fatalf("failed to create admin: %v", err)
+	}
+	return *session
+}
+
+// waitFor polls cond until it holds, failing the test after messageTimeout
+func (h *harness) waitFor(desc string, cond func() bool) {
+	h.t.Helper()
+
+	deadline := time.Now().Add(messageTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("timed out waiting for %s", desc)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// wsMessage is a message as received by a WebSocket client
+type wsMessage struct {
+	Type   string         `json:"type"`
+	RoomID string         `json:"room_id,omitempty"`
+	Seq    int64          `json:"seq,omitempty"`
+	Data   map[string]any `json:"data"`
+}
+
+// wsClient is a WebSocket test client that buffers every received message
+type wsClient struct {
+	t        *testing.T
+	conn     *gorilla.Conn
+	messages chan wsMessage
+
+	// readErr is why the connection closed, set before messages is closed
+	readErr error
+}
+
+// dialWS opens a WebSocket connection into roomID, returning the HTTP status on failure
+func (h *harness) dialWS(token, roomID string) (*wsClient, int, error) {
+	h.t.Helper()
+
+	query := url.Values{}
+	if token != "" {
+		query.Set("token", token)
+	}
+	if roomID != "" {
+		query.Set("room_id", roomID)
+	}
+	wsURL := "ws" + strings.TrimPrefix(h.server.URL, "http") + "/api/ws?" + query.Encode()
+
+	conn, resp, err := gorilla.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		return nil, status, err
+	}
+
+	client := &wsClient{
+		t:        h.t,
+		conn:     conn,
+		messages: make(chan wsMessage, 64),
+	}
+	go client.readLoop()
+	h.t.Cleanup(func() { conn.Close() })
+
+	return client, http.StatusSwitchingProtocols, nil
+}
+
+// connect opens a WebSocket connection and waits until the user has joined roomID
+func (h *harness) connect(session auth.GuestLoginResponse, roomID string) *wsClient {
+	h.t.Helper()
+
+	client, status, err := h.dialWS(session.AccessToken, roomID)
+	if err != nil {
+		h.t.Fatalf("failed to connect to room %s (status %d): %v", roomID, status, err)
+	}
+	client.expect("join", func(msg wsMessage) bool {
+		return msg.Data["user_id"] == session.User.ID
+	})
+	return client
+}
+
+// readLoop forwards received messages until the connection closes
+func (c *wsClient) readLoop() {
+	defer close(c.messages)
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			c.readErr = err
+			return
+		}
+		c.messages <- msg
+	}
+}
+
+// send writes a message to the server
+func (c *wsClient) send(msgType string, data map[string]any) {
+	c.t.Helper()
+	if err := c.conn.WriteJSON(wsMessage{Type: msgType, Data: data}); err != nil {
+		c.t.Fatalf("failed to send %s message: %v", msgType, err)
+	}
+}
+
+// expect waits for a message of the given type matching match (nil matches any),
+// skipping anything else received in between
+func (c *wsClient) expect(msgType string, match func(wsMessage) bool) wsMessage {
+	c.t.Helper()
+
+	timeout := time.After(messageTimeout)
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("connection closed while waiting for %s message", msgType)
+			}
+			if msg.Type == msgType && (match == nil || match(msg)) {
+				return msg
+			}
+		case <-timeout:
+			c.t.Fatalf("timed out waiting for %s message", msgType)
+		}
+	}
+}
+
+// expectClose waits for the server to close the connection with a close
+// frame carrying code, skipping any messages received before it
+func (c *wsClient) expectClose(code int) {
+	c.t.Helper()
+
+	timeout := time.After(messageTimeout)
+	for {
+		select {
+		case _, ok := <-c.messages:
+			if ok {
+				continue
+			}
+			if !gorilla.IsCloseError(c.readErr, code) {
+				c.t.Fatalf("connection closed with %v, want close code %d", c.readErr, code)
+			}
+			return
+		case <-timeout:
+			c.t.Fatalf("timed out waiting for close code %d", code)
+		}
+	}
+}
+
+// withRoomAuth requires invitations to join game rooms
+func withRoomAuth(opts *app.Options) {
+	opts.RoomAuthEnabled = true
+}
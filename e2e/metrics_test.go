@@ -0,0 +1,103 @@
+package e2e
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OkanUysal/go-metrics"
+	"github.com/OkanUysal/go-starter-example-project/app"
+	"github.com/OkanUysal/go-starter-example-project/websocket"
+)
+
+// withMetrics enables the /metrics endpoint
+func withMetrics(opts *app.Options) {
+	opts.Metrics = metrics.NewMetrics(&metrics.Config{ServiceName: "e2e"})
+}
+
+// scrape returns the /metrics exposition text
+func (h *harness) scrape() string {
+	h.t.Helper()
+
+	resp, err := h.server.Client().Get(h.server.URL + "/metrics")
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return string(body)
+}
+
+// waitForMetric waits until /metrics reports series (name plus labels, as
+// exposed) with the given value; some are updated just after a reply is sent
+func (h *harness) waitForMetric(series, value string) {
+	h.t.Helper()
+
+	deadline := time.Now().Add(messageTimeout)
+	for {
+		var got string
+		for _, line := range strings.Split(h.scrape(), "\n") {
+			if rest, ok := strings.CutPrefix(line, series+" "); ok {
+				got = rest
+			}
+		}
+		if got == value {
+			return
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("%s = %q, want %q", series, got, value)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebSocketMetrics(t *testing.T) {
+	h := newHarness(t, withMetrics)
+	admin := h.admin()
+	guest := h.guestLogin()
+
+	var created struct {
+		Room websocket.RoomInfo `json:"room"`
+	}
+	h.mustDo(http.MethodPost, "/api/ws/rooms", admin.AccessToken,
+		map[string]any{"name": "Duel", "max_players": 1}, http.StatusOK, &created)
+
+	first := h.connect(admin, created.Room.ID)
+	second := h.connect(guest, websocket.LobbyRoomID)
+
+	h.waitForMetric("websocket_connected_clients", "2")
+	h.waitForMetric(`websocket_active_rooms{type="game"}`, "1")
+	h.waitForMetric(`websocket_active_rooms{type="lobby"}`, "1")
+	h.waitForMetric(`websocket_room_users{room_id="`+created.Room.ID+`"}`, "1")
+	h.waitForMetric(`websocket_room_joins_total{room_type="game"}`, "1")
+
+	// A full room and an unknown one are rejected with their own reasons
+	second.send("join", map[string]any{"room_id": created.Room.ID})
+	second.expect("error", nil)
+	second.send("join", map[string]any{"room_id": "no-such-room"})
+	second.expect("error", nil)
+	h.waitForMetric(`websocket_room_joins_rejected_total{reason="room_full"}`, "1")
+	h.waitForMetric(`websocket_room_joins_rejected_total{reason="room_not_found"}`, "1")
+	h.waitForMetric(`websocket_messages_received_total{type="join"}`, "2")
+	h.waitForMetric(`websocket_messages_sent_total{type="error"}`, "2")
+
+	// Client-chosen types are not used as label values
+	second.send("made_up", map[string]any{})
+	h.waitForMetric(`websocket_messages_received_total{type="unknown"}`, "1")
+
+	// Disconnecting and closing rooms updates the gauges
+	first.conn.Close()
+	h.waitForMetric("websocket_connected_clients", "1")
+	h.waitForMetric(`websocket_room_users{room_id="`+created.Room.ID+`"}`, "0")
+
+	h.mustDo(http.MethodDelete, "/api/ws/rooms/"+created.Room.ID, admin.AccessToken, nil, http.StatusOK, nil)
+	h.waitForMetric(`websocket_active_rooms{type="game"}`, "0")
+	if body := h.scrape(); strings.Contains(body, `room_id="`+created.Room.ID+`"`) {
+		t.Error("closed room still has a websocket_room_users series")
+	}
+}
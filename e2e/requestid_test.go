@@ -0,0 +1,71 @@
+package e2e
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+	"github.com/OkanUysal/go-starter-example-project/websocket"
+)
+
+func TestRequestIDOnResponses(t *testing.T) {
+	h := newHarness(t)
+
+	// A caller's ID is echoed back, including in error bodies
+	req, err := http.NewRequest(http.MethodGet, h.server.URL+"/api/auth/me", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(telemetry.RequestIDHeader, "e2e-request-1")
+	resp, err := h.server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("GET /api/auth/me without token: status = %d, want 401", resp.StatusCode)
+	}
+	if got := resp.Header.Get(telemetry.RequestIDHeader); got != "e2e-request-1" {
+		t.Errorf("%s header = %q, want e2e-request-1", telemetry.RequestIDHeader, got)
+	}
+	var body struct {
+		Success   bool   `json:"success"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+	if body.Success || body.RequestID != "e2e-request-1" {
+		t.Errorf("error body = %+v, want success=false and the request ID", body)
+	}
+
+	// Without one, an ID is generated
+	resp, err = h.server.Client().Get(h.server.URL + "/api/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get(telemetry.RequestIDHeader) == "" {
+		t.Errorf("no %s header on a request without one", telemetry.RequestIDHeader)
+	}
+}
+
+func TestWebSocketErrorsCarryRequestID(t *testing.T) {
+	h := newHarness(t)
+	client := h.connect(h.guestLogin(), websocket.LobbyRoomID)
+
+	client.send("chat", map[string]any{"request_id": "msg-42"})
+	reply := client.expect("error", nil)
+	if reply.Data["request_id"] != "msg-42" {
+		t.Errorf("error reply request_id = %v, want msg-42", reply.Data["request_id"])
+	}
+
+	// Messages without one get a generated ID
+	client.send("join", map[string]any{})
+	reply = client.expect("error", nil)
+	if id, _ := reply.Data["request_id"].(string); id == "" {
+		t.Error("error reply has no request_id")
+	}
+}
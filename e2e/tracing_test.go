@@ -0,0 +1,158 @@
+package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/OkanUysal/go-starter-example-project/app"
+	"github.com/OkanUysal/go-starter-example-project/websocket"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// withTracing records every span the server creates
+func withTracing(recorder *tracetest.SpanRecorder) func(*app.Options) {
+	return func(opts *app.Options) {
+		opts.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		opts.ServiceName = "e2e"
+	}
+}
+
+// waitForSpans waits until spans matching match have ended, returning all of them.
+// Server spans can end just after the client has read the response.
+func waitForSpans(t *testing.T, recorder *tracetest.SpanRecorder, desc string, match func(sdktrace.ReadOnlySpan) bool) []sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	deadline := time.Now().Add(messageTimeout)
+	for {
+		var found []sdktrace.ReadOnlySpan
+		for _, span := range recorder.Ended() {
+			if match(span) {
+				found = append(found, span)
+			}
+		}
+		if len(found) > 0 {
+			return found
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s span", desc)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// attribute returns a span attribute as a string
+func attribute(span sdktrace.ReadOnlySpan, key string) string {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	h := newHarness(t, withTracing(recorder))
+	session := h.guestLogin()
+
+	const (
+		traceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentSpanID = "00f067aa0ba902b7"
+	)
+	req, err := http.NewRequest(http.MethodGet, h.server.URL+"/api/auth/me", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+session.AccessToken)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentSpanID+"-01")
+	resp, err := h.server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /api/auth/me status = %d, want 200", resp.StatusCode)
+	}
+
+	inTrace := func(name string) func(sdktrace.ReadOnlySpan) bool {
+		return func(span sdktrace.ReadOnlySpan) bool {
+			return span.Name() == name && span.SpanContext().TraceID().String() == traceID
+		}
+	}
+
+	// The request span continues the caller's trace
+	server := waitForSpans(t, recorder, "HTTP server", func(span sdktrace.ReadOnlySpan) bool {
+		return span.SpanContext().TraceID().String() == traceID && span.Parent().SpanID().String() == parentSpanID
+	})[0]
+	if server.Name() != "GET /api/auth/me" {
+		t.Errorf("server span name = %q, want %q", server.Name(), "GET /api/auth/me")
+	}
+
+	// The blacklist checks and their cache lookups are children of it
+	for _, name := range []string{"auth.IsTokenBlacklisted", "auth.IsTokenFamilyBlacklisted"} {
+		check := waitForSpans(t, recorder, name, inTrace(name))[0]
+		if check.Parent().SpanID() != server.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of the request span", name)
+		}
+		if got := attribute(check, "auth.revoked"); got != "false" {
+			t.Errorf("%s auth.revoked = %q, want false", name, got)
+		}
+	}
+	if gets := waitForSpans(t, recorder, "cache.get", inTrace("cache.get")); len(gets) < 2 {
+		t.Errorf("got %d cache.get spans, want at least 2", len(gets))
+	}
+}
+
+func TestProbesAreNotTraced(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	h := newHarness(t, withTracing(recorder))
+
+	resp, err := h.server.Client().Get(h.server.URL + "/healthz/live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// A traced request afterwards proves the probe would have ended by now
+	h.guestLogin()
+	waitForSpans(t, recorder, "guest login", func(span sdktrace.ReadOnlySpan) bool {
+		return span.Name() == "POST /api/auth/guest-login"
+	})
+	for _, span := range recorder.Ended() {
+		if span.Name() == "GET /healthz/live" {
+			t.Fatal("liveness probe was traced")
+		}
+	}
+}
+
+func TestWebSocketMessageSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	h := newHarness(t, withTracing(recorder))
+	guest := h.guestLogin()
+	client := h.connect(guest, websocket.LobbyRoomID)
+
+	client.send("chat", map[string]any{"room_id": websocket.LobbyRoomID, "content": "hi"})
+	client.expect("chat", nil)
+	chat := waitForSpans(t, recorder, "chat message", func(span sdktrace.ReadOnlySpan) bool {
+		return span.Name() == "websocket.message" && attribute(span, "websocket.message_type") == "chat"
+	})[0]
+	if chat.Status().Code == codes.Error {
+		t.Errorf("chat span status = %v, want ok", chat.Status())
+	}
+	if got := attribute(chat, "enduser.id"); got != guest.User.ID {
+		t.Errorf("chat span enduser.id = %q, want %q", got, guest.User.ID)
+	}
+
+	// Rejected messages mark their span as failed
+	client.send("join", map[string]any{})
+	client.expect("error", nil)
+	join := waitForSpans(t, recorder, "join message", func(span sdktrace.ReadOnlySpan) bool {
+		return span.Name() == "websocket.message" && attribute(span, "websocket.message_type") == "join"
+	})[0]
+	if join.Status().Code != codes.Error {
+		t.Errorf("invalid join span status = %v, want error", join.Status())
+	}
+}
@@ -0,0 +1,1101 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OkanUysal/go-starter-example-project/app"
+	"github.com/OkanUysal/go-starter-example-project/auth"
+	"github.com/OkanUysal/go-starter-example-project/lifecycle"
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+	"github.com/OkanUysal/go-starter-example-project/websocket"
+	gorilla "github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+// createRoom creates a game room as admin and returns it
+func (h *harness) createRoom(adminToken, name string, maxPlayers int) websocket.RoomInfo {
+	h.t.Helper()
+
+	var created struct {
+		Room websocket.RoomInfo `json:"room"`
+	}
+	h.mustDo(http.MethodPost, "/api/ws/rooms", adminToken,
+		websocket.CreateRoomRequest{Name: name, MaxPlayers: maxPlayers}, http.StatusOK, &created)
+	return created.Room
+}
+
+func TestWebSocketRequiresAuth(t *testing.T) {
+	h := newHarness(t)
+
+	if _, status, err := h.dialWS("", websocket.LobbyRoomID); err == nil || status != http.StatusUnauthorized {
+		t.Errorf("dial without token: status = %d, err = %v, want 401", status, err)
+	}
+
+	session := h.guestLogin()
+	if _, status, err := h.dialWS(session.AccessToken, "no-such-room"); err == nil || status != http.StatusNotFound {
+		t.Errorf("dial into unknown room: status = %d, err = %v, want 404", status, err)
+	}
+}
+
+func TestRoomCreation(t *testing.T) {
+	h := newHarness(t)
+	admin := h.admin()
+	guest := h.guestLogin()
+	lobby := h.connect(guest, websocket.LobbyRoomID)
+
+	room := h.createRoom(admin.AccessToken, "Arena", 4)
+	if room.ID == "" || room.Type != websocket.RoomTypeGame || room.Name != "Arena" || room.MaxPlayers != 4 {
+		t.Fatalf("unexpected room: %+v", room)
+	}
+
+	// Lobby members are told about the new room
+	lobby.expect("room_created", func(msg wsMessage) bool {
+		created, _ := msg.Data["room"].(map[string]any)
+		return created["id"] == room.ID
+	})
+
+	// The room is listed and can be looked up by ID
+	var list struct {
+		Rooms []websocket.RoomInfo `json:"rooms"`
+	}
+	h.mustDo(http.MethodGet, "/api/ws/rooms", guest.AccessToken, nil, http.StatusOK, &list)
+	found := false
+	for _, r := range list.Rooms {
+		found = found || r.ID == room.ID
+	}
+	if !found {
+		t.Errorf("room %s missing from room list", room.ID)
+	}
+	h.mustDo(http.MethodGet, "/api/ws/rooms/"+room.ID, guest.AccessToken, nil, http.StatusOK, nil)
+
+	// Once closed, the room is gone from the list
+	h.mustDo(http.MethodDelete, "/api/ws/rooms/"+room.ID, admin.AccessToken, nil, http.StatusOK, nil)
+	h.mustDo(http.MethodGet, "/api/ws/rooms", guest.AccessToken, nil, http.StatusOK, &list)
+	for _, r := range list.Rooms {
+		if r.ID == room.ID {
+			t.Errorf("closed room %s still listed", room.ID)
+		}
+	}
+}
+
+func TestRoomJoinLimit(t *testing.T) {
+	h := newHarness(t)
+	admin := h.admin()
+	room := h.createRoom(admin.AccessToken, "Duel", 1)
+
+	first := h.guestLogin()
+	h.connect(first, room.ID)
+
+	// The room is full, so a second player joining from the lobby is refused
+	second := h.guestLogin()
+	client := h.connect(second, websocket.LobbyRoomID)
+	client.send("join", map[string]any{"room_id": room.ID})
+	client.expect("error", func(msg wsMessage) bool {
+		return msg.Data["message"] == "room is full"
+	})
+
+	// Connecting straight into it is refused before the upgrade
+	if _, status, err := h.dialWS(second.AccessToken, room.ID); err == nil || status != http.StatusConflict {
+		t.Errorf("dial into full room: status = %d, err = %v, want 409", status, err)
+	}
+
+	var info struct {
+		Room websocket.RoomInfo `json:"room"`
+	}
+	h.mustDo(http.MethodGet, "/api/ws/rooms/"+room.ID, admin.AccessToken, nil, http.StatusOK, &info)
+	if info.Room.PlayerCount != 1 {
+		t.Errorf("player count = %d, want 1", info.Room.PlayerCount)
+	}
+}
+
+func TestRoomJoinRequiresInvitation(t *testing.T) {
+	h := newHarness(t, withRoomAuth)
+	admin := h.admin()
+	room := h.createRoom(admin.AccessToken, "Private", 0)
+
+	guest := h.guestLogin()
+	if _, status, err := h.dialWS(guest.AccessToken, room.ID); err == nil || status != http.StatusForbidden {
+		t.Errorf("dial into private room: status = %d, err = %v, want 403", status, err)
+	}
+	client := h.connect(guest, websocket.LobbyRoomID)
+	client.send("join", map[string]any{"room_id": room.ID})
+	client.expect("error", func(msg wsMessage) bool {
+		return msg.Data["message"] == "you are not authorized to join this room"
+	})
+
+	// After an invitation the same join succeeds
+	h.mustDo(http.MethodPost, "/api/ws/invite", admin.AccessToken,
+		websocket.InviteRequest{RoomID: room.ID, UserIDs: []string{guest.User.ID}}, http.StatusOK, nil)
+	client.send("join", map[string]any{"room_id": room.ID})
+	client.expect("join", func(msg wsMessage) bool {
+		return msg.Data["room_id"] == room.ID && msg.Data["user_id"] == guest.User.ID
+	})
+}
+
+func TestConnectRefusedByRoom(t *testing.T) {
+	h := newHarness(t)
+	admin := h.admin()
+
+	// A closed room can't be connected to
+	closed := h.createRoom(admin.AccessToken, "Closed", 0)
+	h.mustDo(http.MethodDelete, "/api/ws/rooms/"+closed.ID, admin.AccessToken, nil, http.StatusOK, nil)
+	guest := h.guestLogin()
+	if _, status, err := h.dialWS(guest.AccessToken, closed.ID); err == nil || status != http.StatusConflict {
+		t.Errorf("dial into closed room: status = %d, err = %v, want 409", status, err)
+	}
+
+	// Players racing for the last place: whoever passed the check before the
+	// upgrade but lost the join is told why and disconnected
+	room := h.createRoom(admin.AccessToken, "Duel", 1)
+	var joined, refused, turnedAway int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range 6 {
+		session := h.guestLogin()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, status, err := h.dialWS(session.AccessToken, room.ID)
+			if err != nil {
+				if status != http.StatusConflict {
+					t.Errorf("dial into full room: status = %d, err = %v, want 409", status, err)
+				}
+				mu.Lock()
+				turnedAway++
+				mu.Unlock()
+				return
+			}
+			for msg := range client.messages {
+				switch {
+				case msg.Type == "join" && msg.Data["user_id"] == session.User.ID:
+					mu.Lock()
+					joined++
+					mu.Unlock()
+					return
+				case msg.Type == "connected":
+					// Only a connection that joined its room is set up
+					t.Errorf("refused connection was told it connected: %v", msg.Data)
+				case msg.Type == "error":
+					if msg.Data["code"] != string(websocket.ErrorCodeRoomFull) {
+						t.Errorf("join error = %v, want code %s", msg.Data, websocket.ErrorCodeRoomFull)
+					}
+					for range client.messages {
+					}
+					if !gorilla.IsCloseError(client.readErr, gorilla.ClosePolicyViolation) {
+						t.Errorf("refused connection closed with %v, want policy violation", client.readErr)
+					}
+					mu.Lock()
+					refused++
+					mu.Unlock()
+					return
+				}
+			}
+			t.Errorf("connection closed before joining: %v", client.readErr)
+		}()
+	}
+	wg.Wait()
+	if joined != 1 || joined+refused+turnedAway != 6 {
+		t.Errorf("joined = %d, refused = %d, turned away = %d, want one player joined", joined, refused, turnedAway)
+	}
+	if count := h.room(admin.AccessToken, room.ID).PlayerCount; count != 1 {
+		t.Errorf("player count = %d, want 1", count)
+	}
+}
+
+// panicOnceFriends is a friend source that panics the first time it is asked, like a buggy integration
+type panicOnceFriends struct {
+	panicked atomic.Bool
+}
+
+func (f *panicOnceFriends) Friends(context.Context, string) ([]string, error) {
+	if f.panicked.CompareAndSwap(false, true) {
+		panic("friends service returned nil")
+	}
+	return nil, nil
+}
+
+func TestConnectRefusedWhenSetupPanics(t *testing.T) {
+	h := newHarness(t, func(opts *app.Options) {
+		opts.Friends = &panicOnceFriends{}
+	})
+	guest := h.guestLogin()
+
+	// A connection whose setup panicked is refused, not left half set up
+	client, status, err := h.dialWS(guest.AccessToken, websocket.LobbyRoomID)
+	if err != nil {
+		t.Fatalf("failed to connect (status %d): %v", status, err)
+	}
+	client.expectClose(gorilla.ClosePolicyViolation)
+
+	// and the server carries on
+	h.connect(guest, websocket.LobbyRoomID).expect("connected", nil)
+}
+
+func TestRoomsSurviveRestart(t *testing.T) {
+	// The stores stand in for the database, which outlives the server
+	users := repository.NewMemoryUserRepository()
+	revocations := repository.NewMemoryRevocationRepository()
+	rooms := repository.NewMemoryRoomRepository()
+	withStores := func(opts *app.Options) {
+		opts.Users = users
+		opts.Revocations = revocations
+		opts.RoomStore = rooms
+	}
+
+	before := newHarness(t, withRoomAuth, withStores)
+	admin := before.admin()
+	guest := before.guestLogin()
+	room := before.createRoom(admin.AccessToken, "Persistent", 3)
+	closed := before.createRoom(admin.AccessToken, "Closed", 0)
+	before.mustDo(http.MethodPost, "/api/ws/invite", admin.AccessToken,
+		websocket.InviteRequest{RoomID: room.ID, UserIDs: []string{guest.User.ID}}, http.StatusOK, nil)
+	before.mustDo(http.MethodDelete, "/api/ws/rooms/"+closed.ID, admin.AccessToken, nil, http.StatusOK, nil)
+
+	before.server.Close()
+	before.app.Rooms.Shutdown(context.Background())
+
+	// After the restart the room is back with its settings and invitations
+	after := newHarness(t, withRoomAuth, withStores)
+	got := after.room(guest.AccessToken, room.ID)
+	if !got.IsActive || got.Name != "Persistent" || got.MaxPlayers != 3 || got.CreatedBy != admin.User.ID {
+		t.Errorf("reopened room = %+v", got)
+	}
+	after.connect(guest, room.ID)
+
+	// The closed room stays closed
+	if status, _ := after.do(http.MethodGet, "/api/ws/rooms/"+closed.ID, guest.AccessToken, nil); status != http.StatusNotFound {
+		t.Errorf("closed room lookup status = %d, want 404", status)
+	}
+}
+
+func TestChatBroadcast(t *testing.T) {
+	h := newHarness(t)
+	alice := h.guestLogin()
+	bob := h.guestLogin()
+
+	aliceClient := h.connect(alice, websocket.LobbyRoomID)
+	bobClient := h.connect(bob, websocket.LobbyRoomID)
+
+	aliceClient.send("chat", map[string]any{
+		"room_id": websocket.LobbyRoomID,
+		"content": "hello everyone",
+	})
+
+	// Everyone in the room, including the sender, receives the message
+	for _, client := range []*wsClient{aliceClient, bobClient} {
+		msg := client.expect("chat", nil)
+		if msg.Data["content"] != "hello everyone" || msg.Data["user_id"] != alice.User.ID {
+			t.Errorf("unexpected chat message: %+v", msg.Data)
+		}
+		if msg.Data["username"] != alice.User.DisplayName {
+			t.Errorf("chat username = %v, want %s", msg.Data["username"], alice.User.DisplayName)
+		}
+	}
+}
+
+func TestChatHistory(t *testing.T) {
+	h := newHarness(t, withRoomAuth)
+	admin := h.admin()
+	guest := h.guestLogin()
+	outsider := h.guestLogin()
+	room := h.createRoom(admin.AccessToken, "Chatty", 0)
+
+	sender := h.connect(admin, room.ID)
+	for _, content := range []string{"one", "two", "three"} {
+		sender.send("chat", map[string]any{"room_id": room.ID, "content": content})
+		sender.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == content })
+	}
+
+	// A user joining later receives what was said before, oldest first
+	h.mustDo(http.MethodPost, "/api/ws/invite", admin.AccessToken,
+		websocket.InviteRequest{RoomID: room.ID, UserIDs: []string{guest.User.ID}}, http.StatusOK, nil)
+	client, _, err := h.dialWS(guest.AccessToken, room.ID)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	msg := client.expect("history", func(msg wsMessage) bool { return msg.Data["room_id"] == room.ID })
+	history, _ := msg.Data["messages"].([]any)
+	if len(history) != 3 {
+		t.Fatalf("history has %d messages, want 3", len(history))
+	}
+	if first, _ := history[0].(map[string]any); first["content"] != "one" || first["user_id"] != admin.User.ID {
+		t.Errorf("first history message = %v", first)
+	}
+
+	// Scrolling back a page at a time
+	type page struct {
+		Messages []struct {
+			ID      int64  `json:"id"`
+			Content string `json:"content"`
+		} `json:"messages"`
+		NextBefore int64 `json:"next_before"`
+	}
+	var latest page
+	h.mustDo(http.MethodGet, "/api/ws/rooms/"+room.ID+"/messages?limit=2", guest.AccessToken, nil, http.StatusOK, &latest)
+	if len(latest.Messages) != 2 || latest.Messages[0].Content != "two" || latest.Messages[1].Content != "three" {
+		t.Fatalf("latest page = %+v, want two and three", latest.Messages)
+	}
+	if latest.NextBefore != latest.Messages[0].ID {
+		t.Errorf("next_before = %d, want %d", latest.NextBefore, latest.Messages[0].ID)
+	}
+
+	var older page
+	h.mustDo(http.MethodGet, fmt.Sprintf("/api/ws/rooms/%s/messages?limit=2&before=%d", room.ID, latest.NextBefore),
+		guest.AccessToken, nil, http.StatusOK, &older)
+	if len(older.Messages) != 1 || older.Messages[0].Content != "one" || older.NextBefore != 0 {
+		t.Errorf("older page = %+v, want only one and no further page", older)
+	}
+
+	// Without an invitation the history is off limits
+	h.mustDo(http.MethodGet, "/api/ws/rooms/"+room.ID+"/messages", outsider.AccessToken, nil, http.StatusForbidden, nil)
+	h.mustDo(http.MethodGet, "/api/ws/rooms/no-such-room/messages", outsider.AccessToken, nil, http.StatusNotFound, nil)
+	h.mustDo(http.MethodGet, "/api/ws/rooms/"+room.ID+"/messages?before=abc", guest.AccessToken, nil, http.StatusBadRequest, nil)
+}
+
+func TestChatRequiresMembership(t *testing.T) {
+	h := newHarness(t, withRoomAuth)
+	admin := h.admin()
+	room := h.createRoom(admin.AccessToken, "Private", 0)
+	member := h.connect(admin, room.ID)
+
+	// A user who wasn't invited can't post into the room from the lobby
+	outsider := h.connect(h.guestLogin(), websocket.LobbyRoomID)
+	outsider.send("chat", map[string]any{"room_id": room.ID, "content": "let me in"})
+	msg := outsider.expect("error", nil)
+	if msg.Data["code"] != "forbidden" || msg.Data["message"] != "you are not in this room" {
+		t.Errorf("chat error = %v, want forbidden: you are not in this room", msg.Data)
+	}
+
+	// Nothing reached the members or the history
+	member.send("chat", map[string]any{"room_id": room.ID, "content": "members only"})
+	if got := member.expect("chat", nil); got.Data["content"] != "members only" {
+		t.Errorf("member received %q before their own message", got.Data["content"])
+	}
+	var history struct {
+		Messages []models.ChatMessage `json:"messages"`
+	}
+	h.mustDo(http.MethodGet, "/api/ws/rooms/"+room.ID+"/messages", admin.AccessToken, nil, http.StatusOK, &history)
+	if len(history.Messages) != 1 || history.Messages[0].Content != "members only" {
+		t.Errorf("history = %+v, want only the member's message", history.Messages)
+	}
+}
+
+func TestDirectMessages(t *testing.T) {
+	h := newHarness(t)
+	alice := h.guestLogin()
+	bob := h.guestLogin()
+	carol := h.guestLogin()
+
+	aliceClient := h.connect(alice, websocket.LobbyRoomID)
+	bobClient := h.connect(bob, websocket.LobbyRoomID)
+
+	// A direct message reaches only its recipient, and its sender gets the ID
+	aliceClient.send("dm", map[string]any{"to": bob.User.ID, "content": "psst"})
+	sent := aliceClient.expect("dm_sent", nil)
+	msg := bobClient.expect("dm", nil)
+	if msg.Data["content"] != "psst" || msg.Data["user_id"] != alice.User.ID || msg.Data["message_id"] != sent.Data["message_id"] {
+		t.Errorf("dm = %+v, want psst from alice with ID %v", msg.Data, sent.Data["message_id"])
+	}
+
+	// Reading it tells the sender
+	bobClient.send("dm_read", map[string]any{"from": alice.User.ID, "message_id": msg.Data["message_id"]})
+	receipt := aliceClient.expect("dm_read", nil)
+	if receipt.Data["user_id"] != bob.User.ID || receipt.Data["message_id"] != msg.Data["message_id"] {
+		t.Errorf("read receipt = %+v", receipt.Data)
+	}
+
+	// A user who is offline receives their messages on connecting
+	aliceClient.send("dm", map[string]any{"to": carol.User.ID, "content": "see you later"})
+	aliceClient.expect("dm_sent", nil)
+	carolClient := h.connect(carol, websocket.LobbyRoomID)
+	carolClient.expect("dm", func(msg wsMessage) bool { return msg.Data["content"] == "see you later" })
+
+	// Blocked senders are turned away until unblocked
+	h.mustDo(http.MethodPost, "/api/ws/blocks/"+alice.User.ID, bob.AccessToken, nil, http.StatusOK, nil)
+	var blocks struct {
+		Blocks []struct {
+			BlockedID string `json:"blocked_id"`
+		} `json:"blocks"`
+	}
+	h.mustDo(http.MethodGet, "/api/ws/blocks", bob.AccessToken, nil, http.StatusOK, &blocks)
+	if len(blocks.Blocks) != 1 || blocks.Blocks[0].BlockedID != alice.User.ID {
+		t.Errorf("blocks = %+v, want alice", blocks.Blocks)
+	}
+	aliceClient.send("dm", map[string]any{"to": bob.User.ID, "content": "hello?"})
+	rejected := aliceClient.expect("error", nil)
+	if got, _ := rejected.Data["message"].(string); !strings.Contains(got, "not accepting") {
+		t.Errorf("blocked dm error = %q, want not accepting your messages", got)
+	}
+
+	h.mustDo(http.MethodDelete, "/api/ws/blocks/"+alice.User.ID, bob.AccessToken, nil, http.StatusOK, nil)
+	aliceClient.send("dm", map[string]any{"to": bob.User.ID, "content": "hello again"})
+	bobClient.expect("dm", func(msg wsMessage) bool { return msg.Data["content"] == "hello again" })
+
+	// The conversation reads the same from both sides, without the blocked message
+	for _, session := range []auth.GuestLoginResponse{alice, bob} {
+		other := bob.User.ID
+		if session.User.ID == bob.User.ID {
+			other = alice.User.ID
+		}
+		var conversation struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		h.mustDo(http.MethodGet, "/api/ws/dm/"+other, session.AccessToken, nil, http.StatusOK, &conversation)
+		if len(conversation.Messages) != 2 || conversation.Messages[0].Content != "psst" || conversation.Messages[1].Content != "hello again" {
+			t.Errorf("conversation for %s = %+v, want psst and hello again", session.User.ID, conversation.Messages)
+		}
+	}
+
+	h.mustDo(http.MethodPost, "/api/ws/blocks/"+bob.User.ID, bob.AccessToken, nil, http.StatusBadRequest, nil)
+	h.mustDo(http.MethodPost, "/api/ws/blocks/no-such-user", bob.AccessToken, nil, http.StatusNotFound, nil)
+}
+
+func TestPresenceAndTyping(t *testing.T) {
+	h := newHarness(t, func(opts *app.Options) {
+		opts.TypingTimeout = 200 * time.Millisecond
+	})
+	alice := h.guestLogin()
+	bob := h.guestLogin()
+	carol := h.guestLogin()
+
+	aliceClient := h.connect(alice, websocket.LobbyRoomID)
+	bobClient := h.connect(bob, websocket.LobbyRoomID)
+
+	presenceOf := func(userIDs ...string) []websocket.Presence {
+		t.Helper()
+		var result struct {
+			Presence []websocket.Presence `json:"presence"`
+		}
+		h.mustDo(http.MethodGet, "/api/presence?user_ids="+strings.Join(userIDs, ","), bob.AccessToken, nil, http.StatusOK, &result)
+		return result.Presence
+	}
+	presence := presenceOf(alice.User.ID, bob.User.ID, carol.User.ID)
+	if len(presence) != 3 || presence[0].Status != websocket.PresenceOnline ||
+		presence[1].Status != websocket.PresenceOnline || presence[2].Status != websocket.PresenceOffline {
+		t.Fatalf("presence = %+v, want alice and bob online, carol offline", presence)
+	}
+
+	// Going away is announced to the rooms alice is in
+	aliceClient.send("presence", map[string]any{"status": "away"})
+	bobClient.expect("presence", func(msg wsMessage) bool {
+		return msg.Data["user_id"] == alice.User.ID && msg.Data["status"] == "away"
+	})
+	if got := presenceOf(alice.User.ID)[0].Status; got != websocket.PresenceAway {
+		t.Errorf("alice's presence = %s, want away", got)
+	}
+	aliceClient.send("presence", map[string]any{"status": "busy"})
+	aliceClient.expect("error", nil)
+
+	// A typing indicator nobody stops expires
+	aliceClient.send("typing_start", map[string]any{"room_id": websocket.LobbyRoomID})
+	bobClient.expect("typing_start", func(msg wsMessage) bool {
+		return msg.Data["user_id"] == alice.User.ID && msg.Data["room_id"] == websocket.LobbyRoomID
+	})
+	bobClient.expect("typing_stop", func(msg wsMessage) bool { return msg.Data["user_id"] == alice.User.ID })
+
+	// Typing to a user ends when the message is sent
+	aliceClient.send("typing_start", map[string]any{"to": bob.User.ID})
+	bobClient.expect("typing_start", func(msg wsMessage) bool { return msg.Data["to"] == bob.User.ID })
+	aliceClient.send("dm", map[string]any{"to": bob.User.ID, "content": "done typing"})
+	bobClient.expect("typing_stop", func(msg wsMessage) bool { return msg.Data["to"] == bob.User.ID })
+	bobClient.expect("dm", nil)
+
+	// Only room members may type in a room
+	aliceClient.send("typing_start", map[string]any{"room_id": "some-other-room"})
+	if msg := aliceClient.expect("error", nil); msg.Data["message"] != "you are not in this room" {
+		t.Errorf("typing error = %v, want you are not in this room", msg.Data["message"])
+	}
+
+	// Disconnecting takes alice offline
+	aliceClient.conn.Close()
+	bobClient.expect("presence", func(msg wsMessage) bool {
+		return msg.Data["user_id"] == alice.User.ID && msg.Data["status"] == "offline"
+	})
+	if got := presenceOf(alice.User.ID)[0]; got.Status != websocket.PresenceOffline || got.LastSeen == nil {
+		t.Errorf("alice's presence after disconnecting = %+v, want offline with a last seen time", got)
+	}
+
+	h.mustDo(http.MethodGet, "/api/presence", bob.AccessToken, nil, http.StatusBadRequest, nil)
+}
+
+func TestResumeAfterReconnect(t *testing.T) {
+	h := newHarness(t, func(opts *app.Options) {
+		opts.ReplayBufferSize = 6
+	})
+	admin := h.admin()
+	guest := h.guestLogin()
+	room := h.createRoom(admin.AccessToken, "Resumable", 0)
+
+	sender := h.connect(admin, room.ID)
+	receiver := h.connect(guest, room.ID)
+
+	// Room messages are numbered one after another
+	chat := func(content string) wsMessage {
+		t.Helper()
+		sender.send("chat", map[string]any{"room_id": room.ID, "content": content})
+		return receiver.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == content })
+	}
+	first := chat("one")
+	second := chat("two")
+	if first.RoomID != room.ID || first.Seq == 0 || second.Seq != first.Seq+1 {
+		t.Fatalf("chat sequence = %s/%d then %d, want consecutive numbers in room %s",
+			first.RoomID, first.Seq, second.Seq, room.ID)
+	}
+	receiver.send("ack", map[string]any{"room_id": room.ID, "seq": second.Seq})
+
+	// Reconnecting and resuming from the ack replays what was missed, in order
+	receiver.conn.Close()
+	h.waitFor("the guest to disconnect", func() bool { return h.room(admin.AccessToken, room.ID).PlayerCount == 1 })
+	for _, content := range []string{"three", "four"} {
+		sender.send("chat", map[string]any{"room_id": room.ID, "content": content})
+		sender.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == content })
+	}
+
+	receiver = h.connect(guest, room.ID)
+	receiver.send("resume", map[string]any{"room_id": room.ID})
+	var previous int64
+	for _, content := range []string{"three", "four"} {
+		msg := receiver.expect("chat", nil)
+		if msg.Data["content"] != content || msg.Seq <= second.Seq || (previous != 0 && msg.Seq != previous+1) {
+			t.Errorf("replayed %v with seq %d after seq %d", msg.Data["content"], msg.Seq, previous)
+		}
+		previous = msg.Seq
+	}
+	resumed := receiver.expect("resumed", nil)
+	if resumed.Data["replayed"] != float64(6) {
+		// The guest leaving and going offline, three, four, and the guest's own join and coming online
+		t.Errorf("replayed = %v, want 6", resumed.Data["replayed"])
+	}
+
+	// Further back than the buffer reaches, the room's state is sent instead
+	receiver.send("resume", map[string]any{"room_id": room.ID, "last_seq": 0})
+	snapshot := receiver.expect("snapshot", nil)
+	if snapshot.Data["seq"] != resumed.Data["seq"] {
+		t.Errorf("snapshot seq = %v, want %v", snapshot.Data["seq"], resumed.Data["seq"])
+	}
+	if messages, _ := snapshot.Data["messages"].([]any); len(messages) != 4 {
+		t.Errorf("snapshot has %d messages, want 4", len(messages))
+	}
+	if info, _ := snapshot.Data["room"].(map[string]any); info["player_count"] != float64(2) {
+		t.Errorf("snapshot room = %v, want 2 players", info)
+	}
+
+	// Only members may resume a room
+	receiver.send("resume", map[string]any{"room_id": "some-other-room"})
+	if msg := receiver.expect("error", nil); msg.Data["message"] != "you are not in this room" {
+		t.Errorf("resume error = %v, want you are not in this room", msg.Data["message"])
+	}
+}
+
+func TestWebSocketProtocol(t *testing.T) {
+	h := newHarness(t)
+	guest := h.guestLogin()
+	wsURL := "ws" + strings.TrimPrefix(h.server.URL, "http") + "/api/ws?token=" + guest.AccessToken
+
+	// The version is negotiated by subprotocol or query parameter
+	conn, resp, err := gorilla.DefaultDialer.Dial(wsURL, http.Header{"Sec-WebSocket-Protocol": {"starter.v1"}})
+	if err != nil {
+		t.Fatalf("failed to connect with a subprotocol: %v", err)
+	}
+	conn.Close()
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "starter.v1" {
+		t.Errorf("accepted subprotocol = %q, want starter.v1", got)
+	}
+	if _, resp, err := gorilla.DefaultDialer.Dial(wsURL+"&protocol=99", nil); err == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("connecting with an unsupported version: %v, want status %d", err, http.StatusBadRequest)
+	}
+
+	client := h.connect(guest, websocket.LobbyRoomID)
+	expectError := func(code websocket.ErrorCode, msgType string) wsMessage {
+		t.Helper()
+		msg := client.expect("error", nil)
+		if typ, _ := msg.Data["type"].(string); msg.Data["code"] != string(code) || typ != msgType {
+			t.Errorf("error = %v, want code %s for %s", msg.Data, code, msgType)
+		}
+		return msg
+	}
+
+	// Malformed messages are answered with what was wrong, and the connection stays open
+	client.send("chat", map[string]any{"room_id": websocket.LobbyRoomID, "request_id": "req-1"})
+	if msg := expectError(websocket.ErrorCodeInvalidRequest, "chat"); msg.Data["message"] != "content is required" || msg.Data["request_id"] != "req-1" {
+		t.Errorf("invalid chat error = %v", msg.Data)
+	}
+	client.send("dm_read", map[string]any{"from": "someone", "message_id": "latest"})
+	if msg := expectError(websocket.ErrorCodeInvalidRequest, "dm_read"); msg.Data["message"] != "message_id must be an integer" {
+		t.Errorf("mistyped dm_read error = %v", msg.Data)
+	}
+	client.send("teleport", nil)
+	expectError(websocket.ErrorCodeUnknownType, "teleport")
+	if err := client.conn.WriteMessage(gorilla.TextMessage, []byte("not json")); err != nil {
+		t.Fatalf("failed to send a malformed frame: %v", err)
+	}
+	expectError(websocket.ErrorCodeInvalidMessage, "")
+
+	// Failures are classified too
+	client.send("join", map[string]any{"room_id": "no-such-room"})
+	expectError(websocket.ErrorCodeNotFound, "join")
+	client.send("close_room", map[string]any{"room_id": websocket.LobbyRoomID})
+	expectError(websocket.ErrorCodeForbidden, "close_room")
+
+	// The protocol is described for client generators
+	status, _ := h.do(http.MethodGet, "/api/asyncapi.json", "", nil)
+	if status != http.StatusOK {
+		t.Errorf("GET /api/asyncapi.json = %d, want 200", status)
+	}
+}
+
+func TestCustomMessageHandlers(t *testing.T) {
+	h := newHarness(t, func(opts *app.Options) {
+		opts.MessageRate = 1
+		opts.MessageBurst = 4
+	})
+
+	// A game registers its own message types, with their own middleware
+	type rollRequest struct {
+		Sides int `json:"sides" binding:"required,gt=1"`
+	}
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+	recorded := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Join(order, ",")
+	}
+	trace := func(name string) websocket.Middleware {
+		return func(next websocket.HandlerFunc) websocket.HandlerFunc {
+			return func(ctx context.Context, req *websocket.Request) error {
+				record(name)
+				return next(ctx, req)
+			}
+		}
+	}
+	h.app.Rooms.Handle("roll", func(ctx context.Context, req *websocket.Request) error {
+		var roll rollRequest
+		if err := req.Decode(&roll); err != nil {
+			return err
+		}
+		record("roll")
+		h.app.Rooms.SendToClient(req.Client.UserID, &websocket.Message{
+			Type: websocket.MessageTypeGameEvent,
+			Data: map[string]interface{}{"sides": roll.Sides, "result": roll.Sides},
+		})
+		return nil
+	}, trace("first"), trace("second"))
+	h.app.Rooms.Handle("explode", func(ctx context.Context, req *websocket.Request) error {
+		panic("boom")
+	})
+
+	guest := h.guestLogin()
+	client := h.connect(guest, websocket.LobbyRoomID)
+	expectError := func(code websocket.ErrorCode, msgType string) wsMessage {
+		t.Helper()
+		msg := client.expect("error", nil)
+		if typ, _ := msg.Data["type"].(string); msg.Data["code"] != string(code) || typ != msgType {
+			t.Errorf("error = %v, want code %s for %s", msg.Data, code, msgType)
+		}
+		return msg
+	}
+
+	client.send("roll", map[string]any{"sides": 6})
+	if msg := client.expect("game_event", nil); msg.Data["result"] != float64(6) {
+		t.Errorf("roll result = %v, want 6", msg.Data["result"])
+	}
+	if got := recorded(); got != "first,second,roll" {
+		t.Errorf("handler order = %s, want first,second,roll", got)
+	}
+
+	// Custom requests are validated, and a panicking handler only fails its message
+	client.send("roll", map[string]any{"sides": 1})
+	if msg := expectError(websocket.ErrorCodeInvalidRequest, "roll"); msg.Data["message"] != "sides must be greater than 1" {
+		t.Errorf("invalid roll error = %v", msg.Data)
+	}
+	client.send("explode", nil)
+	if msg := expectError(websocket.ErrorCodeInternal, "explode"); msg.Data["message"] != "internal server error" {
+		t.Errorf("panic error = %v, want internal server error", msg.Data)
+	}
+
+	// The burst is spent: the next message is rejected before reaching its handler
+	client.send("roll", map[string]any{"sides": 6})
+	client.expect("game_event", nil)
+	client.send("roll", map[string]any{"sides": 20})
+	expectError(websocket.ErrorCodeRateLimited, "roll")
+	if got := recorded(); got != "first,second,roll,first,second,first,second,roll" {
+		t.Errorf("handler calls = %s, want none for the rejected message", got)
+	}
+
+	// Built-in admin messages check the role
+	admin := h.admin()
+	adminClient := h.connect(admin, websocket.LobbyRoomID)
+	adminClient.send("create_room", map[string]any{"name": "Arena"})
+	adminClient.expect("room_created", func(msg wsMessage) bool { return msg.Data["name"] == "Arena" })
+}
+
+func TestMessagePackAndCompression(t *testing.T) {
+	h := newHarness(t, func(opts *app.Options) {
+		opts.Compression = true
+		opts.CompressionMinSize = 64
+	})
+	listener := h.connect(h.guestLogin(), websocket.LobbyRoomID)
+	guest := h.guestLogin()
+
+	// The format is negotiated like the protocol version; compression is a WebSocket extension
+	handle := &codec.MsgpackHandle{WriteExt: true}
+	handle.RawToString = true
+	handle.MapType = reflect.TypeOf(map[string]any(nil))
+	dialer := gorilla.Dialer{EnableCompression: true}
+	wsURL := "ws" + strings.TrimPrefix(h.server.URL, "http") + "/api/ws?" + url.Values{"token": {guest.AccessToken}}.Encode()
+	conn, resp, err := dialer.Dial(wsURL, http.Header{"Sec-WebSocket-Protocol": {"starter.v1.msgpack"}})
+	if err != nil {
+		t.Fatalf("failed to connect with MessagePack: %v", err)
+	}
+	defer conn.Close()
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "starter.v1.msgpack" {
+		t.Errorf("accepted subprotocol = %q, want starter.v1.msgpack", got)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(got, "permessage-deflate") {
+		t.Errorf("extensions = %q, want permessage-deflate", got)
+	}
+
+	send := func(msg wsMessage) {
+		t.Helper()
+		var data []byte
+		if err := codec.NewEncoderBytes(&data, handle).Encode(msg); err != nil {
+			t.Fatalf("failed to encode %s: %v", msg.Type, err)
+		}
+		if err := conn.WriteMessage(gorilla.BinaryMessage, data); err != nil {
+			t.Fatalf("failed to send %s: %v", msg.Type, err)
+		}
+	}
+	expect := func(msgType string) wsMessage {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(messageTimeout))
+		for {
+			frameType, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("failed waiting for %s: %v", msgType, err)
+			}
+			if frameType != gorilla.BinaryMessage {
+				t.Fatalf("received a %d frame, want binary", frameType)
+			}
+			var msg wsMessage
+			if err := codec.NewDecoderBytes(data, handle).Decode(&msg); err != nil {
+				t.Fatalf("failed to decode a frame: %v", err)
+			}
+			if msg.Type == msgType {
+				return msg
+			}
+		}
+	}
+	expect("join")
+
+	// A MessagePack client and a JSON client receive the same broadcast
+	content := strings.Repeat("a long enough message to be compressed ", 4)
+	send(wsMessage{Type: "chat", Data: map[string]any{"room_id": websocket.LobbyRoomID, "content": content}})
+	if msg := expect("chat"); msg.Data["content"] != content || msg.Seq == 0 {
+		t.Errorf("MessagePack chat = %v", msg)
+	}
+	listener.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == content })
+
+	// Requests are validated the same way, and malformed frames still answered
+	send(wsMessage{Type: "chat", Data: map[string]any{"room_id": websocket.LobbyRoomID, "content": 7}})
+	if msg := expect("error"); msg.Data["message"] != "content must be a string" {
+		t.Errorf("mistyped chat error = %v", msg.Data)
+	}
+	if err := conn.WriteMessage(gorilla.BinaryMessage, []byte{0xc1}); err != nil {
+		t.Fatalf("failed to send a malformed frame: %v", err)
+	}
+	if msg := expect("error"); msg.Data["code"] != string(websocket.ErrorCodeInvalidMessage) {
+		t.Errorf("malformed frame error = %v", msg.Data)
+	}
+}
+
+func TestConnectionLifecycle(t *testing.T) {
+	h := newHarness(t)
+	admin := h.admin()
+	room := h.createRoom(admin.AccessToken, "Lifecycle", 0)
+	watcher := h.connect(admin, room.ID)
+
+	// The requested room is joined before the connection's first message is read
+	guest := h.guestLogin()
+	client, status, err := h.dialWS(guest.AccessToken, room.ID)
+	if err != nil {
+		t.Fatalf("failed to connect (status %d): %v", status, err)
+	}
+	client.send("chat", map[string]any{"room_id": room.ID, "content": "first words"})
+	watcher.expect("join", func(msg wsMessage) bool { return msg.Data["user_id"] == guest.User.ID })
+	watcher.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == "first words" })
+
+	// Disconnecting leaves every room, which the other members are told
+	client.conn.Close()
+	watcher.expect("leave", func(msg wsMessage) bool { return msg.Data["user_id"] == guest.User.ID })
+	if count := h.room(admin.AccessToken, room.ID).PlayerCount; count != 1 {
+		t.Errorf("player count after the guest left = %d, want 1", count)
+	}
+
+	// Users reconnecting quickly, several times over, end up in the room once;
+	// an old connection's cleanup doesn't undo the new one's join
+	guests := make([]string, 5)
+	clients := make([]*wsClient, len(guests))
+	var wg sync.WaitGroup
+	for i := range guests {
+		session := h.guestLogin()
+		guests[i] = session.User.ID
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for attempt := range 4 {
+				client, status, err := h.dialWS(session.AccessToken, room.ID)
+				if err != nil {
+					t.Errorf("failed to connect (status %d): %v", status, err)
+					return
+				}
+				if attempt == 3 {
+					clients[i] = client
+					return
+				}
+				client.conn.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	members := func(want int) func() bool {
+		return func() bool {
+			return h.room(admin.AccessToken, room.ID).PlayerCount == want && h.app.Rooms.GetHub().GetRoomClientCount(room.ID) == want
+		}
+	}
+	h.waitFor("the guests' last connections to be in the room", members(1+len(guests)))
+	watcher.send("chat", map[string]any{"room_id": room.ID, "content": "welcome back"})
+	for _, client := range clients {
+		client.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == "welcome back" })
+	}
+
+	// And disconnecting them all leaves nothing behind
+	for _, client := range clients {
+		client.conn.Close()
+	}
+	h.waitFor("every guest to leave", members(1))
+	var presence struct {
+		Presence []websocket.Presence `json:"presence"`
+	}
+	h.waitFor("every guest to be offline", func() bool {
+		h.mustDo(http.MethodGet, "/api/presence?user_ids="+strings.Join(guests, ","), admin.AccessToken, nil, http.StatusOK, &presence)
+		for _, p := range presence.Presence {
+			if p.Status != websocket.PresenceOffline {
+				return false
+			}
+		}
+		return true
+	})
+
+	// The remaining member still gets the room's messages
+	watcher.send("chat", map[string]any{"room_id": room.ID, "content": "still here"})
+	watcher.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == "still here" })
+}
+
+func TestLeaveRoomRequiresMembership(t *testing.T) {
+	h := newHarness(t)
+	admin := h.admin()
+	room := h.createRoom(admin.AccessToken, "Quiet", 0)
+	member := h.connect(admin, room.ID)
+	outsider := h.guestLogin()
+	h.connect(outsider, websocket.LobbyRoomID)
+
+	// Someone who isn't in the room can't leave it, and the members hear nothing
+	if err := h.app.Rooms.LeaveRoom(context.Background(), room.ID, outsider.User.ID, outsider.User.DisplayName); err == nil {
+		t.Error("leaving a room the user isn't in succeeded")
+	}
+	member.send("chat", map[string]any{"room_id": room.ID, "content": "still just me"})
+	timeout := time.After(messageTimeout)
+	for done := false; !done; {
+		select {
+		case msg := <-member.messages:
+			if msg.Type == "leave" {
+				t.Errorf("member was told %v left", msg.Data["user_id"])
+			}
+			done = msg.Type == "chat"
+		case <-timeout:
+			t.Fatal("timed out waiting for the chat message")
+		}
+	}
+	if count := h.room(admin.AccessToken, room.ID).PlayerCount; count != 1 {
+		t.Errorf("player count = %d, want 1", count)
+	}
+}
+
+func TestShutdownNotifiesClients(t *testing.T) {
+	h := newHarness(t)
+	admin := h.admin()
+	room := h.createRoom(admin.AccessToken, "Shutdown", 0)
+	player := h.connect(admin, room.ID)
+	lobby := h.connect(h.guestLogin(), websocket.LobbyRoomID)
+
+	lc := lifecycle.NewManager(5*time.Second, h.app.Logger)
+	h.app.RegisterShutdown(lc)
+	if err := lc.Shutdown(); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	// By the time shutdown returns, every client has been sent the notice
+	// and a close frame, whichever room it is in
+	for _, client := range []*wsClient{player, lobby} {
+		client.expect("server_shutdown", nil)
+		client.expectClose(gorilla.CloseGoingAway)
+	}
+}
+
+// connectDevice connects like connect, also returning the connection's ID
+func (h *harness) connectDevice(session auth.GuestLoginResponse, roomID string) (*wsClient, any) {
+	h.t.Helper()
+
+	client, status, err := h.dialWS(session.AccessToken, roomID)
+	if err != nil {
+		h.t.Fatalf("failed to connect to room %s (status %d): %v", roomID, status, err)
+	}
+	client.expect("join", func(msg wsMessage) bool { return msg.Data["user_id"] == session.User.ID })
+	connected := client.expect("connected", nil)
+	return client, connected.Data["connection_id"]
+}
+
+func TestMultipleDevices(t *testing.T) {
+	h := newHarness(t)
+	admin := h.admin()
+	room := h.createRoom(admin.AccessToken, "Two screens", 2)
+	watcher := h.connect(admin, room.ID)
+
+	// A user connects from a phone and a browser: each connection has its own
+	// ID, and the room counts the user once
+	guest := h.guestLogin()
+	phone, phoneID := h.connectDevice(guest, room.ID)
+	browser, browserID := h.connectDevice(guest, room.ID)
+	if phoneID == "" || phoneID == browserID || phoneID == guest.User.ID {
+		t.Errorf("connection IDs = %v and %v, want two IDs distinct from the user's", phoneID, browserID)
+	}
+	if count := h.room(admin.AccessToken, room.ID).PlayerCount; count != 2 {
+		t.Errorf("player count = %d, want 2", count)
+	}
+
+	// Room messages and direct messages reach both devices
+	chat := func(content string, devices ...*wsClient) wsMessage {
+		t.Helper()
+		watcher.send("chat", map[string]any{"room_id": room.ID, "content": content})
+		for _, device := range devices {
+			device.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == content })
+		}
+		return watcher.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == content })
+	}
+	before := chat("hello both", phone, browser)
+	watcher.send("dm", map[string]any{"to": guest.User.ID, "content": "psst"})
+	phone.expect("dm", func(msg wsMessage) bool { return msg.Data["content"] == "psst" })
+	browser.expect("dm", func(msg wsMessage) bool { return msg.Data["content"] == "psst" })
+
+	// Closing one device leaves the user in the room and online: nothing is
+	// announced between two chats
+	phone.conn.Close()
+	h.waitFor("the phone to disconnect", func() bool { return h.app.Rooms.GetHub().ConnectionCount(guest.User.ID) == 1 })
+	if after := chat("still there?", browser); after.Seq != before.Seq+1 {
+		t.Errorf("chat seq after the phone closed = %d, want %d: the user left or went offline", after.Seq, before.Seq+1)
+	}
+	browser.send("chat", map[string]any{"room_id": room.ID, "content": "yes"})
+	watcher.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == "yes" })
+
+	// Each connection has its own status: the user is away once every one is
+	tablet, _ := h.connectDevice(guest, room.ID)
+	tablet.send("presence", map[string]any{"status": "away"})
+	status := func() websocket.PresenceStatus {
+		var presence struct {
+			Presence []websocket.Presence `json:"presence"`
+		}
+		h.mustDo(http.MethodGet, "/api/presence?user_ids="+guest.User.ID, admin.AccessToken, nil, http.StatusOK, &presence)
+		return presence.Presence[0].Status
+	}
+	chat("are you away?", browser, tablet)
+	if got := status(); got != websocket.PresenceOnline {
+		t.Errorf("status with an away tablet and an online browser = %s, want online", got)
+	}
+	browser.conn.Close()
+	watcher.expect("presence", func(msg wsMessage) bool {
+		return msg.Data["user_id"] == guest.User.ID && msg.Data["status"] == string(websocket.PresenceAway)
+	})
+
+	// Closing the last one leaves the room
+	tablet.conn.Close()
+	watcher.expect("leave", func(msg wsMessage) bool { return msg.Data["user_id"] == guest.User.ID })
+	watcher.expect("presence", func(msg wsMessage) bool {
+		return msg.Data["user_id"] == guest.User.ID && msg.Data["status"] == string(websocket.PresenceOffline)
+	})
+}
+
+func TestConnectionPolicies(t *testing.T) {
+	// kick_oldest: the new device takes over, and the old one is told why before it is closed
+	h := newHarness(t, func(opts *app.Options) {
+		opts.ConnectionPolicy = websocket.ConnectionPolicyKickOldest
+	})
+	admin := h.admin()
+	watcher := h.connect(admin, websocket.LobbyRoomID)
+	guest := h.guestLogin()
+	phone := h.connect(guest, websocket.LobbyRoomID)
+	watcher.send("chat", map[string]any{"room_id": websocket.LobbyRoomID, "content": "before"})
+	before := watcher.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == "before" })
+
+	browser, browserID := h.connectDevice(guest, websocket.LobbyRoomID)
+	kicked := phone.expect("kicked", nil)
+	if kicked.Data["connection_id"] != browserID {
+		t.Errorf("kicked by %v, want the browser's connection %v", kicked.Data["connection_id"], browserID)
+	}
+	timeout := time.After(messageTimeout)
+	for closed := false; !closed; {
+		select {
+		case _, open := <-phone.messages:
+			closed = !open
+		case <-timeout:
+			t.Fatal("the kicked connection wasn't closed")
+		}
+	}
+	if count := h.app.Rooms.GetHub().ConnectionCount(guest.User.ID); count != 1 {
+		t.Errorf("connections after the kick = %d, want 1", count)
+	}
+
+	// The user never left the lobby
+	watcher.send("chat", map[string]any{"room_id": websocket.LobbyRoomID, "content": "after"})
+	browser.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == "after" })
+	if after := watcher.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == "after" }); after.Seq != before.Seq+1 {
+		t.Errorf("chat seq after the kick = %d, want %d: the user left and joined again", after.Seq, before.Seq+1)
+	}
+
+	// reject_new: over the limit, the new device is refused and the others stay connected
+	h = newHarness(t, func(opts *app.Options) {
+		opts.ConnectionPolicy = websocket.ConnectionPolicyRejectNew
+		opts.MaxConnections = 2
+	})
+	guest = h.guestLogin()
+	first := h.connect(guest, websocket.LobbyRoomID)
+	h.connect(guest, websocket.LobbyRoomID)
+	if _, status, err := h.dialWS(guest.AccessToken, websocket.LobbyRoomID); err == nil || status != http.StatusConflict {
+		t.Errorf("third connection: status %d, %v, want 409", status, err)
+	}
+	first.send("chat", map[string]any{"room_id": websocket.LobbyRoomID, "content": "still connected"})
+	first.expect("chat", func(msg wsMessage) bool { return msg.Data["content"] == "still connected" })
+}
@@ -1,13 +1,12 @@
 package handlers
 
 import (
-	"context"
-
 	"github.com/OkanUysal/go-logger"
 	"github.com/OkanUysal/go-response"
 	"github.com/OkanUysal/go-starter-example-project/auth"
-	"github.com/OkanUysal/go-starter-example-project/config"
 	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
 	"github.com/gin-gonic/gin"
 )
 
@@ -22,12 +21,13 @@ import (
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 403 {object} map[string]string "Admin access required"
 // @Router /admin/dashboard [get]
-func AdminDashboard(c *gin.Context) {
+func (h *Handler) AdminDashboard(c *gin.Context) {
 	userID, _ := auth.GetUserID(c)
 	role, _ := auth.GetRole(c)
 
-	cache := config.GetCache()
-	ctx := context.Background()
+	cache := h.cache
+	ctx := c.Request.Context()
+	log := telemetry.Logger(ctx, h.logger)
 	cacheKey := "admin:dashboard:stats"
 
 	// Define stats structure
@@ -42,16 +42,28 @@ func AdminDashboard(c *gin.Context) {
 	// Try to get from cache first
 	if err := cache.GetJSON(ctx, cacheKey, &stats); err != nil {
 		// Cache miss - get from database
-		config.Logger.Info("Cache miss: admin dashboard stats")
-		db := config.GetDB()
-		db.Model(&models.User{}).Count(&stats.TotalUsers)
-		db.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&stats.AdminCount)
-		db.Model(&models.User{}).Where("is_guest = ?", true).Count(&stats.GuestCount)
+		log.Info("Cache miss: admin dashboard stats")
+		admin := models.RoleAdmin
+		guest := true
+
+		var err error
+		if stats.TotalUsers, err = h.users.Count(ctx, repository.UserFilter{}); err != nil {
+			response.InternalError(c, err)
+			return
+		}
+		if stats.AdminCount, err = h.users.Count(ctx, repository.UserFilter{Role: &admin}); err != nil {
+			response.InternalError(c, err)
+			return
+		}
+		if stats.GuestCount, err = h.users.Count(ctx, repository.UserFilter{IsGuest: &guest}); err != nil {
+			response.InternalError(c, err)
+			return
+		}
 
 		// Cache for default TTL (5 minutes)
 		cache.SetJSON(ctx, cacheKey, stats)
 	} else {
-		config.Logger.Info("Cache hit: admin dashboard stats",
+		log.Info("Cache hit: admin dashboard stats",
 			logger.Int64("total_users", stats.TotalUsers),
 			logger.Int64("admin_count", stats.AdminCount),
 			logger.Int64("guest_count", stats.GuestCount))
@@ -77,11 +89,9 @@ func AdminDashboard(c *gin.Context) {
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 403 {object} map[string]string "Admin access required"
 // @Router /admin/users [get]
-func ListUsers(c *gin.Context) {
-	db := config.GetDB()
-
-	var users []models.User
-	if err := db.Find(&users).Error; err != nil {
+func (h *Handler) ListUsers(c *gin.Context) {
+	users, err := h.users.List(c.Request.Context())
+	if err != nil {
 		response.InternalError(c, err)
 		return
 	}
@@ -1,19 +1,13 @@
 package handlers
 
 import (
+	"errors"
+
 	"github.com/OkanUysal/go-response"
 	"github.com/OkanUysal/go-starter-example-project/auth"
 	"github.com/gin-gonic/gin"
 )
 
-var authService *auth.Service
-
-// InitAuthService initializes the auth service
-func InitAuthService() error {
-	authService = auth.NewService()
-	return nil
-}
-
 // GuestLogin handles guest login
 // @Summary Guest login
 // @Description Creates a new guest user or logs in existing guest with guest_id
@@ -24,12 +18,12 @@ func InitAuthService() error {
 // @Success 200 {object} auth.GuestLoginResponse
 // @Failure 500 {object} map[string]string
 // @Router /auth/guest-login [post]
-func GuestLogin(c *gin.Context) {
+func (h *Handler) GuestLogin(c *gin.Context) {
 	var req auth.GuestLoginRequest
 	// Bind JSON but don't require it
 	_ = c.ShouldBindJSON(&req)
 
-	result, err := authService.GuestLogin(req.GuestID)
+	result, err := h.auth.GuestLogin(c.Request.Context(), req.GuestID)
 	if err != nil {
 		response.InternalError(c, err)
 		return
@@ -47,15 +41,20 @@ func GuestLogin(c *gin.Context) {
 // @Success 200 {object} auth.GuestLoginResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 503 {object} map[string]string
 // @Router /auth/refresh [post]
-func RefreshToken(c *gin.Context) {
+func (h *Handler) RefreshToken(c *gin.Context) {
 	var req auth.RefreshTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.BadRequest(c, "INVALID_REQUEST", "Invalid request body")
 		return
 	}
 
-	result, err := authService.RefreshToken(req.RefreshToken)
+	result, err := h.auth.RefreshToken(c.Request.Context(), req.RefreshToken)
+	if errors.Is(err, auth.ErrRevocationUnavailable) {
+		response.Error(c, 503, "Token revocation status is unavailable, try again later", nil)
+		return
+	}
 	if err != nil {
 		response.Unauthorized(c, err.Error())
 		return
@@ -73,14 +72,14 @@ func RefreshToken(c *gin.Context) {
 // @Success 200 {object} models.User
 // @Failure 401 {object} map[string]string
 // @Router /auth/me [get]
-func GetMe(c *gin.Context) {
+func (h *Handler) GetMe(c *gin.Context) {
 	userID, exists := auth.GetUserID(c)
 	if !exists {
 		response.Unauthorized(c, "User not authenticated")
 		return
 	}
 
-	user, err := authService.GetUserByID(userID)
+	user, err := h.auth.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
 		response.NotFound(c, "User")
 		return
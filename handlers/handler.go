@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/auth"
+	"github.com/OkanUysal/go-starter-example-project/config"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+)
+
+// Handler serves the REST endpoints using its injected dependencies
+type Handler struct {
+	auth   *auth.Service
+	users  repository.UserRepository
+	cache  *config.Cache
+	logger *logger.Logger
+}
+
+// New creates a handler set
+func New(authService *auth.Service, users repository.UserRepository, c *config.Cache, log *logger.Logger) *Handler {
+	return &Handler{
+		auth:   authService,
+		users:  users,
+		cache:  c,
+		logger: log,
+	}
+}
@@ -0,0 +1,85 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/OkanUysal/go-cache"
+	"github.com/OkanUysal/go-starter-example-project/migrations"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DatabaseCheck pings the database connection pool
+func DatabaseCheck(db *gorm.DB) CheckFunc {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// CacheCheck writes a value to the cache and reads it back
+func CacheCheck(c *cache.Cache) CheckFunc {
+	return func(ctx context.Context) error {
+		key := fmt.Sprintf("health:ping:%s", uuid.New().String())
+		defer c.Delete(ctx, key)
+
+		want := uuid.New().String()
+		if err := c.SetJSON(ctx, key, want); err != nil {
+			return fmt.Errorf("write failed: %w", err)
+		}
+
+		var got string
+		if err := c.GetJSON(ctx, key, &got); err != nil {
+			return fmt.Errorf("read failed: %w", err)
+		}
+		if got != want {
+			return errors.New("read back a different value than was written")
+		}
+		return nil
+	}
+}
+
+// RunningChecker is implemented by components with a started/stopped state
+type RunningChecker interface {
+	IsRunning() bool
+}
+
+// RunningCheck fails when the component is not running
+func RunningCheck(component RunningChecker) CheckFunc {
+	return func(ctx context.Context) error {
+		if !component.IsRunning() {
+			return errors.New("not running")
+		}
+		return nil
+	}
+}
+
+// MigrationsCheck fails when migrations are pending or were modified after being applied
+func MigrationsCheck(migrator *migrations.Migrator) CheckFunc {
+	return func(ctx context.Context) error {
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+
+		pending := 0
+		for _, status := range statuses {
+			if status.ChecksumMismatch {
+				return fmt.Errorf("migration %03d_%s was modified after it was applied", status.Version, status.Name)
+			}
+			if !status.Applied {
+				pending++
+			}
+		}
+
+		if pending > 0 {
+			return fmt.Errorf("%d pending migration(s)", pending)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,183 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Status values reported by checks and the overall report
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc verifies a single dependency and returns an error if it is unhealthy
+type CheckFunc func(ctx context.Context) error
+
+// check is a registered dependency check
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+}
+
+// CheckResult is the outcome of a single check
+type CheckResult struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Report is the aggregated readiness result
+type Report struct {
+	Status    string                 `json:"status"`
+	Checks    map[string]CheckResult `json:"checks"`
+	CheckedAt time.Time              `json:"checked_at"`
+	Cached    bool                   `json:"cached"`
+}
+
+// Registry holds readiness checks and caches their aggregated result,
+// so frequent probes from several load balancers don't hammer dependencies
+type Registry struct {
+	defaultTimeout time.Duration
+	cacheTTL       time.Duration
+
+	mu     sync.Mutex
+	checks []check
+	last   *Report
+}
+
+// NewRegistry creates a registry. Checks registered without their own timeout
+// use defaultTimeout; results are reused for cacheTTL.
+func NewRegistry(defaultTimeout, cacheTTL time.Duration) *Registry {
+	return &Registry{
+		defaultTimeout: defaultTimeout,
+		cacheTTL:       cacheTTL,
+	}
+}
+
+// Register adds a check using the registry's default timeout
+func (r *Registry) Register(name string, fn CheckFunc) {
+	r.RegisterWithTimeout(name, r.defaultTimeout, fn)
+}
+
+// RegisterWithTimeout adds a check with its own timeout
+func (r *Registry) RegisterWithTimeout(name string, timeout time.Duration, fn CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks = append(r.checks, check{name: name, timeout: timeout, fn: fn})
+	r.last = nil
+}
+
+// Run executes all checks concurrently, or returns the cached report if it is still fresh.
+// Concurrent callers wait for the in-progress run instead of starting their own.
+// The report is shared with them, so the checks only get the values of ctx,
+// not its cancellation: a probe that gave up can't cache a false failure.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx = context.WithoutCancel(ctx)
+
+	if r.last != nil && time.Since(r.last.CheckedAt) < r.cacheTTL {
+		cached := *r.last
+		cached.Cached = true
+		return cached
+	}
+
+	report := Report{
+		Status:    StatusUp,
+		Checks:    make(map[string]CheckResult, len(r.checks)),
+		CheckedAt: time.Now(),
+	}
+
+	results := make([]CheckResult, len(r.checks))
+	var wg sync.WaitGroup
+	for i, c := range r.checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for i, c := range r.checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	r.last = &report
+	return report
+}
+
+// runCheck runs a single check, giving up when its timeout expires even if
+// the check itself ignores the context
+func runCheck(ctx context.Context, c check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		done <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", c.timeout)
+		}
+	}
+
+	result := CheckResult{
+		Status:     StatusUp,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LiveHandler reports that the process is running. It deliberately checks no
+// dependencies, so a database outage doesn't get every replica restarted.
+func LiveHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status": StatusUp,
+		})
+	}
+}
+
+// ReadyHandler runs the registry and responds 200 when every check passes,
+// or 503 with the per-check breakdown otherwise
+func (r *Registry) ReadyHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := r.Run(c.Request.Context())
+
+		status := http.StatusOK
+		if report.Status != StatusUp {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
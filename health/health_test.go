@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/migrations"
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestRegistryTimesOutSlowChecks(t *testing.T) {
+	r := NewRegistry(time.Second, 0)
+	r.Register("database", func(ctx context.Context) error { return nil })
+	r.RegisterWithTimeout("cache", 20*time.Millisecond, func(ctx context.Context) error {
+		// Ignores ctx, like a client without deadline support
+		time.Sleep(time.Second)
+		return nil
+	})
+	r.Register("broken", func(ctx context.Context) error { panic("nil client") })
+
+	start := time.Now()
+	report := r.Run(context.Background())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Run took %s, want it bounded by the check timeouts", elapsed)
+	}
+	if report.Status != StatusDown {
+		t.Errorf("report status = %s, want down", report.Status)
+	}
+	if got := report.Checks["database"]; got.Status != StatusUp {
+		t.Errorf("database check = %+v, want up", got)
+	}
+	if got := report.Checks["cache"]; got.Status != StatusDown || !strings.Contains(got.Error, "timed out") {
+		t.Errorf("cache check = %+v, want timed out", got)
+	}
+	if got := report.Checks["broken"]; got.Status != StatusDown || !strings.Contains(got.Error, "panicked") {
+		t.Errorf("broken check = %+v, want panicked", got)
+	}
+}
+
+func TestRegistryCachesReports(t *testing.T) {
+	r := NewRegistry(time.Second, time.Minute)
+	var runs atomic.Int32
+	r.Register("database", func(ctx context.Context) error {
+		runs.Add(1)
+		return ctx.Err()
+	})
+
+	// A probe that already gave up neither fails the checks nor caches a failure
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if report := r.Run(cancelled); report.Status != StatusUp || report.Cached {
+		t.Errorf("report for a cancelled probe = %+v, want a fresh up", report)
+	}
+
+	report := r.Run(context.Background())
+	if report.Status != StatusUp || !report.Cached {
+		t.Errorf("second report = %+v, want the cached up", report)
+	}
+	if runs.Load() != 1 {
+		t.Errorf("check ran %d times, want once while cached", runs.Load())
+	}
+
+	// Registering a check drops the cached report
+	r.Register("cache", func(ctx context.Context) error { return errors.New("unreachable") })
+	if report := r.Run(context.Background()); report.Cached || report.Status != StatusDown {
+		t.Errorf("report after a new check = %+v, want a fresh down", report)
+	}
+}
+
+func TestMigrationsCheckOnlyReads(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "health.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	migrator, err := migrations.NewMigrator(db, logger.Default())
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+
+	check := MigrationsCheck(migrator)
+	if err := check(context.Background()); err == nil || !strings.Contains(err.Error(), "pending") {
+		t.Errorf("check before migrating = %v, want pending migrations", err)
+	}
+	if db.Migrator().HasTable(&models.SchemaMigration{}) {
+		t.Error("the check created the schema migrations table")
+	}
+
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := check(context.Background()); err != nil {
+		t.Errorf("check after migrating = %v, want nil", err)
+	}
+}
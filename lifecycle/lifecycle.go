@@ -0,0 +1,85 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/OkanUysal/go-logger"
+)
+
+// ShutdownFunc releases a resource, giving up when ctx expires
+type ShutdownFunc func(ctx context.Context) error
+
+// hook is a named shutdown step
+type hook struct {
+	name string
+	fn   ShutdownFunc
+}
+
+// Manager waits for a termination signal and then runs shutdown hooks
+// in the order they were registered, all sharing a single deadline
+type Manager struct {
+	timeout time.Duration
+	hooks   []hook
+	logger  *logger.Logger
+}
+
+// NewManager creates a lifecycle manager with the given shutdown deadline
+func NewManager(timeout time.Duration, log *logger.Logger) *Manager {
+	return &Manager{timeout: timeout, logger: log}
+}
+
+// OnShutdown registers a hook to run during shutdown
+func (m *Manager) OnShutdown(name string, fn ShutdownFunc) {
+	m.hooks = append(m.hooks, hook{name: name, fn: fn})
+}
+
+// Wait blocks until SIGINT/SIGTERM is received or errCh yields an error,
+// then shuts everything down. It returns the error from errCh, if any,
+// joined with any hook failures.
+func (m *Manager) Wait(errCh <-chan error) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var runErr error
+	select {
+	case sig := <-signals:
+		m.logger.Info("Shutdown signal received", logger.String("signal", sig.String()))
+	case runErr = <-errCh:
+		m.logger.Error("Server stopped unexpectedly", logger.Err(runErr))
+	}
+
+	return errors.Join(runErr, m.Shutdown())
+}
+
+// Shutdown runs all hooks in registration order. A failing hook is logged
+// and does not prevent the remaining resources from being released.
+func (m *Manager) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	m.logger.Info("Shutting down", logger.Duration("timeout", m.timeout))
+
+	var errs []error
+	for _, h := range m.hooks {
+		start := time.Now()
+		if err := h.fn(ctx); err != nil {
+			m.logger.Error("Shutdown step failed",
+				logger.String("step", h.name),
+				logger.Err(err))
+			errs = append(errs, err)
+			continue
+		}
+		m.logger.Info("Shutdown step completed",
+			logger.String("step", h.name),
+			logger.Duration("duration", time.Since(start)))
+	}
+
+	m.logger.Info("Shutdown complete")
+	return errors.Join(errs...)
+}
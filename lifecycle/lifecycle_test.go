@@ -0,0 +1,81 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/OkanUysal/go-logger"
+)
+
+func TestShutdownRunsHooksInOrder(t *testing.T) {
+	m := NewManager(time.Second, logger.Default())
+	failed := errors.New("cache unreachable")
+
+	var ran []string
+	step := func(name string, err error) ShutdownFunc {
+		return func(ctx context.Context) error {
+			ran = append(ran, name)
+			return err
+		}
+	}
+	m.OnShutdown("http", step("http", nil))
+	m.OnShutdown("cache", step("cache", failed))
+	m.OnShutdown("database", step("database", nil))
+
+	// A failing step is reported, and the ones after it still run
+	if err := m.Shutdown(); !errors.Is(err, failed) {
+		t.Errorf("Shutdown = %v, want the cache error", err)
+	}
+	if want := []string{"http", "cache", "database"}; !slices.Equal(ran, want) {
+		t.Errorf("steps ran in order %v, want %v", ran, want)
+	}
+}
+
+func TestShutdownSharesOneDeadline(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	m := NewManager(timeout, logger.Default())
+
+	// A step that hangs uses up the deadline, and the next one sees it expired
+	m.OnShutdown("stuck", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	var late error
+	m.OnShutdown("after", func(ctx context.Context) error {
+		late = ctx.Err()
+		return nil
+	})
+
+	start := time.Now()
+	if err := m.Shutdown(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*timeout {
+		t.Errorf("Shutdown took %s with a %s deadline", elapsed, timeout)
+	}
+	if !errors.Is(late, context.DeadlineExceeded) {
+		t.Errorf("next step's context error = %v, want deadline exceeded", late)
+	}
+}
+
+func TestWaitShutsDownWhenServerFails(t *testing.T) {
+	m := NewManager(time.Second, logger.Default())
+	stopped := false
+	m.OnShutdown("database", func(ctx context.Context) error {
+		stopped = true
+		return nil
+	})
+
+	serveErr := errors.New("address already in use")
+	errCh := make(chan error, 1)
+	errCh <- serveErr
+	if err := m.Wait(errCh); !errors.Is(err, serveErr) {
+		t.Errorf("Wait = %v, want the server error", err)
+	}
+	if !stopped {
+		t.Error("shutdown steps didn't run after the server failed")
+	}
+}
@@ -1,2 +0,0 @@
--- Drop example_user table
-DROP TABLE IF EXISTS example_user CASCADE;
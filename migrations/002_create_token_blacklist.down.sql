@@ -1,2 +0,0 @@
--- Drop example_token_blacklist table
-DROP TABLE IF EXISTS example_token_blacklist CASCADE;
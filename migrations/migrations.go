@@ -0,0 +1,144 @@
+package migrations
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"text/template"
+
+	"github.com/OkanUysal/go-starter-example-project/models"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Supported SQL dialects; each has its own directory of migration files.
+// The names match gorm.Dialector.Name().
+const (
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
+)
+
+// fileNamePattern matches migration files like 001_create_users_table.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration represents a single versioned migration with its rendered SQL
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// TemplateData holds the values substituted into migration SQL files
+type TemplateData struct {
+	UserTable           string
+	TokenBlacklistTable string
+	RoomTable           string
+	RoomInvitationTable string
+	ChatMessageTable    string
+	DirectMessageTable  string
+	UserBlockTable      string
+}
+
+// DefaultTemplateData returns template data based on the configured table names
+func DefaultTemplateData() TemplateData {
+	return TemplateData{
+		UserTable:           models.GetUserTableName(),
+		TokenBlacklistTable: models.TokenBlacklist{}.TableName(),
+		RoomTable:           models.Room{}.TableName(),
+		RoomInvitationTable: models.RoomInvitation{}.TableName(),
+		ChatMessageTable:    models.ChatMessage{}.TableName(),
+		DirectMessageTable:  models.DirectMessage{}.TableName(),
+		UserBlockTable:      models.UserBlock{}.TableName(),
+	}
+}
+
+// Load reads the embedded migration files for a dialect and renders them with the given data
+func Load(dialect string, data TemplateData) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q: %w", dialect, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		matches := fileNamePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		sql, err := render(path.Join(dialect, entry.Name()), data)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		} else if migration.Name != matches[2] {
+			return nil, fmt.Errorf("conflicting names for migration version %d: %s and %s", version, migration.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			migration.Up = sql
+			migration.Checksum = checksum(sql)
+		} else {
+			migration.Down = sql
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// render executes a migration file as a text template
+func render(name string, data TemplateData) (string, error) {
+	content, err := files.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read migration %s: %w", name, err)
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse migration %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render migration %s: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// checksum returns the hex-encoded SHA-256 of the rendered SQL
+func checksum(sql string) string {
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,331 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"gorm.io/gorm"
+)
+
+// advisoryLockID is the Postgres advisory lock key held while migrating,
+// so several replicas booting at once don't apply the same migration twice
+const advisoryLockID int64 = 7470011026
+
+var (
+	ErrChecksumMismatch = errors.New("applied migration checksum does not match embedded file")
+	ErrNoDownMigration  = errors.New("migration has no down file")
+	ErrUnknownVersion   = errors.New("unknown migration version")
+)
+
+// MigrationStatus describes the state of a single migration
+type MigrationStatus struct {
+	Version          int64      `json:"version"`
+	Name             string     `json:"name"`
+	Applied          bool       `json:"applied"`
+	AppliedAt        *time.Time `json:"applied_at,omitempty"`
+	ChecksumMismatch bool       `json:"checksum_mismatch"`
+}
+
+// Migrator applies and rolls back the embedded migrations
+type Migrator struct {
+	db         *gorm.DB
+	dialect    string
+	migrations []Migration
+	logger     *logger.Logger
+}
+
+// NewMigrator creates a migrator for the database's dialect using the configured table names
+func NewMigrator(db *gorm.DB, log *logger.Logger) (*Migrator, error) {
+	dialect := db.Dialector.Name()
+
+	migrations, err := Load(dialect, DefaultTemplateData())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+		logger:     log,
+	}, nil
+}
+
+// Dialect returns the SQL dialect the migrations were loaded for
+func (m *Migrator) Dialect() string {
+	return m.dialect
+}
+
+// Migrations returns the loaded migrations in version order
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// LatestVersion returns the highest embedded migration version
+func (m *Migrator) LatestVersion() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up() error {
+	return m.To(m.LatestVersion())
+}
+
+// Down rolls back the given number of applied migrations
+func (m *Migrator) Down(steps int) error {
+	if steps <= 0 {
+		return nil
+	}
+
+	return m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		// A down script only matches the schema its own up script created
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := m.rollback(conn, migration); err != nil {
+				return err
+			}
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// To migrates up or down until the given version is the latest applied one.
+// Version 0 rolls back every migration.
+func (m *Migrator) To(version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		// Roll back anything above the target, newest first
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version <= version {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				if err := m.rollback(conn, migration); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Apply anything pending up to the target, oldest first
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			if _, ok := applied[migration.Version]; !ok {
+				if err := m.apply(conn, migration); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// Status reports which migrations are applied and whether their checksums still match
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	// Status is read-only: a missing table just means nothing was applied yet
+	applied := make(map[int64]models.SchemaMigration)
+	if m.db.Migrator().HasTable(&models.SchemaMigration{}) {
+		var err error
+		applied, err = m.applied(m.db)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{
+			Version: migration.Version,
+			Name:    migration.Name,
+		}
+
+		if record, ok := applied[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.ChecksumMismatch = record.Checksum != migration.Checksum
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the number of embedded migrations that have not been applied yet
+func (m *Migrator) Pending() (int, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if !status.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// withLock runs fn on a single connection while holding the migration lock.
+// Postgres uses an advisory lock; SQLite is a single local file whose writes
+// are already serialized, so it needs none.
+func (m *Migrator) withLock(fn func(conn *gorm.DB) error) error {
+	return m.db.Connection(func(conn *gorm.DB) error {
+		if m.dialect == DialectPostgres {
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", advisoryLockID).Error; err != nil {
+				return fmt.Errorf("failed to acquire migration lock: %w", err)
+			}
+			defer func() {
+				if err := conn.Exec("SELECT pg_advisory_unlock(?)", advisoryLockID).Error; err != nil {
+					m.logger.Error("Failed to release migration lock", logger.Err(err))
+				}
+			}()
+		}
+
+		if err := m.ensureTable(conn); err != nil {
+			return err
+		}
+
+		return fn(conn)
+	})
+}
+
+// ensureTable creates the schema migrations table if it doesn't exist
+func (m *Migrator) ensureTable(conn *gorm.DB) error {
+	table := models.SchemaMigration{}.TableName()
+	err := conn.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    version BIGINT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`, table)).Error
+	if err != nil {
+		return fmt.Errorf("failed to create %s table: %w", table, err)
+	}
+	return nil
+}
+
+// applied returns the recorded migrations keyed by version
+func (m *Migrator) applied(conn *gorm.DB) (map[int64]models.SchemaMigration, error) {
+	var records []models.SchemaMigration
+	if err := conn.Order("version").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	applied := make(map[int64]models.SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// verify makes sure no applied migration was edited after it ran
+func (m *Migrator) verify(applied map[int64]models.SchemaMigration) error {
+	for _, migration := range m.migrations {
+		record, ok := applied[migration.Version]
+		if ok && record.Checksum != migration.Checksum {
+			return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+// apply runs an up migration and records it in a single transaction
+func (m *Migrator) apply(conn *gorm.DB, migration Migration) error {
+	start := time.Now()
+
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(migration.Up).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.SchemaMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Checksum:  migration.Checksum,
+			AppliedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	m.logger.Info("Migration applied",
+		logger.Int64("version", migration.Version),
+		logger.String("name", migration.Name),
+		logger.Duration("duration", time.Since(start)))
+
+	return nil
+}
+
+// rollback runs a down migration and removes its record in a single transaction
+func (m *Migrator) rollback(conn *gorm.DB, migration Migration) error {
+	if migration.Down == "" {
+		return fmt.Errorf("%w: %d_%s", ErrNoDownMigration, migration.Version, migration.Name)
+	}
+
+	start := time.Now()
+
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(migration.Down).Error; err != nil {
+			return err
+		}
+		return tx.Where("version = ?", migration.Version).Delete(&models.SchemaMigration{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to roll back migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	m.logger.Info("Migration rolled back",
+		logger.Int64("version", migration.Version),
+		logger.String("name", migration.Name),
+		logger.Duration("duration", time.Since(start)))
+
+	return nil
+}
+
+// find returns the migration with the given version, or nil
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
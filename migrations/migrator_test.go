@@ -0,0 +1,175 @@
+package migrations
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// newSQLiteMigrator returns a migrator for a fresh SQLite database
+func newSQLiteMigrator(t *testing.T) (*Migrator, *gorm.DB) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	migrator, err := NewMigrator(db, logger.Default())
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	return migrator, db
+}
+
+func TestLoadEveryDialect(t *testing.T) {
+	var versions []int64
+	for _, dialect := range []string{DialectPostgres, DialectSQLite} {
+		migrations, err := Load(dialect, DefaultTemplateData())
+		if err != nil {
+			t.Fatalf("Load(%s): %v", dialect, err)
+		}
+
+		// Every dialect must ship the same versions so a schema means the same thing everywhere
+		var got []int64
+		for _, migration := range migrations {
+			if migration.Down == "" {
+				t.Errorf("%s migration %d_%s has no down file", dialect, migration.Version, migration.Name)
+			}
+			got = append(got, migration.Version)
+		}
+		if versions == nil {
+			versions = got
+		} else if !slices.Equal(got, versions) {
+			t.Errorf("%s has versions %v, want %v", dialect, got, versions)
+		}
+	}
+
+	if _, err := Load("oracle", DefaultTemplateData()); err == nil {
+		t.Error("Load succeeded for an unknown dialect")
+	}
+}
+
+func TestSQLiteUpDown(t *testing.T) {
+	migrator, db := newSQLiteMigrator(t)
+	if migrator.Dialect() != DialectSQLite {
+		t.Fatalf("dialect = %s, want %s", migrator.Dialect(), DialectSQLite)
+	}
+
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if pending, err := migrator.Pending(); err != nil || pending != 0 {
+		t.Fatalf("Pending after Up = %d, %v; want 0", pending, err)
+	}
+	if !db.Migrator().HasColumn(&models.TokenBlacklist{}, "family_id") {
+		t.Error("family_id column missing after Up")
+	}
+	if !db.Migrator().HasTable(&models.Room{}) || !db.Migrator().HasTable(&models.RoomInvitation{}) {
+		t.Error("room tables missing after Up")
+	}
+
+	// Up is idempotent
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("second Up: %v", err)
+	}
+
+	if err := migrator.Down(1); err != nil {
+		t.Fatalf("Down(1): %v", err)
+	}
+	if db.Migrator().HasTable(&models.DirectMessage{}) || db.Migrator().HasTable(&models.UserBlock{}) {
+		t.Error("direct message tables still present after Down(1)")
+	}
+
+	if err := migrator.To(4); err != nil {
+		t.Fatalf("To(4): %v", err)
+	}
+	if db.Migrator().HasTable(&models.ChatMessage{}) {
+		t.Error("chat message table still present after To(4)")
+	}
+
+	if err := migrator.To(3); err != nil {
+		t.Fatalf("To(3): %v", err)
+	}
+	if db.Migrator().HasTable(&models.Room{}) {
+		t.Error("room table still present after To(3)")
+	}
+
+	if err := migrator.To(2); err != nil {
+		t.Fatalf("To(2): %v", err)
+	}
+	if db.Migrator().HasColumn(&models.TokenBlacklist{}, "family_id") {
+		t.Error("family_id column still present after To(2)")
+	}
+
+	if err := migrator.To(0); err != nil {
+		t.Fatalf("To(0): %v", err)
+	}
+	if db.Migrator().HasTable(&models.User{}) {
+		t.Error("user table still present after To(0)")
+	}
+	if pending, err := migrator.Pending(); err != nil || pending != len(migrator.Migrations()) {
+		t.Fatalf("Pending after To(0) = %d, %v; want %d", pending, err, len(migrator.Migrations()))
+	}
+
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("Up after full rollback: %v", err)
+	}
+}
+
+func TestEditedMigrationBlocksMigrating(t *testing.T) {
+	migrator, db := newSQLiteMigrator(t)
+
+	// Status only reads: before anything ran there is no table, and it isn't created
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(statuses) != len(migrator.Migrations()) || statuses[0].Applied {
+		t.Errorf("Status before Up = %+v, want every migration pending", statuses)
+	}
+	if db.Migrator().HasTable(&models.SchemaMigration{}) {
+		t.Error("Status created the schema migrations table")
+	}
+
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	latest := migrator.LatestVersion()
+	if err := db.Model(&models.SchemaMigration{}).Where("version = ?", latest).Update("checksum", "edited").Error; err != nil {
+		t.Fatalf("failed to alter checksum: %v", err)
+	}
+
+	// Neither direction runs against a schema an edited migration created
+	if err := migrator.Down(1); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Down(1) = %v, want ErrChecksumMismatch", err)
+	}
+	if err := migrator.To(0); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("To(0) = %v, want ErrChecksumMismatch", err)
+	}
+	if !db.Migrator().HasTable(&models.DirectMessage{}) {
+		t.Error("latest migration was rolled back despite the mismatch")
+	}
+
+	statuses, err = migrator.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if last := statuses[len(statuses)-1]; !last.Applied || !last.ChecksumMismatch {
+		t.Errorf("status of the edited migration = %+v, want applied with a checksum mismatch", last)
+	}
+}
@@ -0,0 +1,2 @@
+-- Drop {{.UserTable}} table
+DROP TABLE IF EXISTS {{.UserTable}} CASCADE;
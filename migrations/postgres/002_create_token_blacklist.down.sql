@@ -0,0 +1,2 @@
+-- Drop {{.TokenBlacklistTable}} table
+DROP TABLE IF EXISTS {{.TokenBlacklistTable}} CASCADE;
@@ -0,0 +1,5 @@
+-- Add family_id column to {{.TokenBlacklistTable}} table
+ALTER TABLE {{.TokenBlacklistTable}} ADD COLUMN IF NOT EXISTS family_id VARCHAR(255);
+
+-- Create index for family_id lookups
+CREATE INDEX IF NOT EXISTS idx_{{.TokenBlacklistTable}}_family_id ON {{.TokenBlacklistTable}}(family_id);
@@ -0,0 +1,3 @@
+-- Drop {{.RoomInvitationTable}} and {{.RoomTable}} tables
+DROP TABLE IF EXISTS {{.RoomInvitationTable}};
+DROP TABLE IF EXISTS {{.RoomTable}};
@@ -0,0 +1,2 @@
+-- Drop {{.ChatMessageTable}} table
+DROP TABLE IF EXISTS {{.ChatMessageTable}};
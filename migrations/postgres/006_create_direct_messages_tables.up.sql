@@ -0,0 +1,22 @@
+-- Create {{.DirectMessageTable}} table
+CREATE TABLE IF NOT EXISTS {{.DirectMessageTable}} (
+    id BIGSERIAL PRIMARY KEY,
+    sender_id VARCHAR(255) NOT NULL,
+    recipient_id VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP,
+    read_at TIMESTAMP
+);
+
+-- Create indexes for conversations and pending deliveries
+CREATE INDEX IF NOT EXISTS idx_{{.DirectMessageTable}}_sender_id ON {{.DirectMessageTable}}(sender_id);
+CREATE INDEX IF NOT EXISTS idx_{{.DirectMessageTable}}_recipient_id ON {{.DirectMessageTable}}(recipient_id);
+
+-- Create {{.UserBlockTable}} table
+CREATE TABLE IF NOT EXISTS {{.UserBlockTable}} (
+    blocker_id VARCHAR(255) NOT NULL,
+    blocked_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id)
+);
@@ -0,0 +1,18 @@
+-- Create {{.UserTable}} table
+CREATE TABLE IF NOT EXISTS {{.UserTable}} (
+    id VARCHAR(255) PRIMARY KEY,
+    guest_id VARCHAR(255) UNIQUE,
+    google_id VARCHAR(255) UNIQUE,
+    display_name VARCHAR(255) NOT NULL,
+    role VARCHAR(50) NOT NULL DEFAULT 'USER',
+    is_guest BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- SQLite can't add constraints later, so the role check is declared inline
+    CONSTRAINT chk_user_role CHECK (role IN ('USER', 'ADMIN'))
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_{{.UserTable}}_guest_id ON {{.UserTable}}(guest_id);
+CREATE INDEX IF NOT EXISTS idx_{{.UserTable}}_google_id ON {{.UserTable}}(google_id);
+CREATE INDEX IF NOT EXISTS idx_{{.UserTable}}_role ON {{.UserTable}}(role);
@@ -0,0 +1,11 @@
+-- Create {{.TokenBlacklistTable}} table
+CREATE TABLE IF NOT EXISTS {{.TokenBlacklistTable}} (
+    jti VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index for cleanup queries
+CREATE INDEX IF NOT EXISTS idx_{{.TokenBlacklistTable}}_expires_at ON {{.TokenBlacklistTable}}(expires_at);
+CREATE INDEX IF NOT EXISTS idx_{{.TokenBlacklistTable}}_user_id ON {{.TokenBlacklistTable}}(user_id);
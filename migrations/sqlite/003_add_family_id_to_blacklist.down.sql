@@ -0,0 +1,3 @@
+-- Remove family_id column from {{.TokenBlacklistTable}} table
+DROP INDEX IF EXISTS idx_{{.TokenBlacklistTable}}_family_id;
+ALTER TABLE {{.TokenBlacklistTable}} DROP COLUMN family_id;
@@ -0,0 +1,21 @@
+-- Create {{.RoomTable}} table
+CREATE TABLE IF NOT EXISTS {{.RoomTable}} (
+    id VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    max_players INTEGER NOT NULL DEFAULT 0,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMP
+);
+
+-- Create index for loading active rooms on startup
+CREATE INDEX IF NOT EXISTS idx_{{.RoomTable}}_is_active ON {{.RoomTable}}(is_active);
+
+-- Create {{.RoomInvitationTable}} table
+CREATE TABLE IF NOT EXISTS {{.RoomInvitationTable}} (
+    room_id VARCHAR(255) NOT NULL REFERENCES {{.RoomTable}}(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (room_id, user_id)
+);
@@ -0,0 +1,12 @@
+-- Create {{.ChatMessageTable}} table
+CREATE TABLE IF NOT EXISTS {{.ChatMessageTable}} (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    room_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    username VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index for paging back through a room's history
+CREATE INDEX IF NOT EXISTS idx_{{.ChatMessageTable}}_room_id_id ON {{.ChatMessageTable}}(room_id, id);
@@ -0,0 +1,3 @@
+-- Drop {{.UserBlockTable}} and {{.DirectMessageTable}} tables
+DROP TABLE IF EXISTS {{.UserBlockTable}};
+DROP TABLE IF EXISTS {{.DirectMessageTable}};
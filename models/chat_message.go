@@ -0,0 +1,26 @@
+package models
+
+import (
+	"os"
+	"time"
+)
+
+// ChatMessage is a chat message sent to a room. IDs increase with every
+// message, so they double as the cursor for paging back through history.
+type ChatMessage struct {
+	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	RoomID    string    `json:"room_id" gorm:"type:varchar(255);not null;index"`
+	UserID    string    `json:"user_id" gorm:"type:varchar(255);not null"`
+	Username  string    `json:"username" gorm:"type:varchar(255);not null"`
+	Content   string    `json:"content" gorm:"type:text;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name from environment variable
+func (ChatMessage) TableName() string {
+	tableName := os.Getenv("CHAT_MESSAGE_TABLE")
+	if tableName == "" {
+		return "example_chat_message" // default fallback
+	}
+	return tableName
+}
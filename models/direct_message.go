@@ -0,0 +1,43 @@
+package models
+
+import (
+	"os"
+	"time"
+)
+
+// DirectMessage is a message from one user to another. It is stored, so users
+// who were offline receive it when they next connect.
+type DirectMessage struct {
+	ID          int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	SenderID    string     `json:"sender_id" gorm:"type:varchar(255);not null;index"`
+	RecipientID string     `json:"recipient_id" gorm:"type:varchar(255);not null;index"`
+	Content     string     `json:"content" gorm:"type:text;not null"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+}
+
+// TableName returns the table name from environment variable
+func (DirectMessage) TableName() string {
+	tableName := os.Getenv("DIRECT_MESSAGE_TABLE")
+	if tableName == "" {
+		return "example_direct_message" // default fallback
+	}
+	return tableName
+}
+
+// UserBlock records that a user doesn't accept direct messages from another
+type UserBlock struct {
+	BlockerID string    `json:"blocker_id" gorm:"primaryKey;type:varchar(255)"`
+	BlockedID string    `json:"blocked_id" gorm:"primaryKey;type:varchar(255)"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name from environment variable
+func (UserBlock) TableName() string {
+	tableName := os.Getenv("USER_BLOCK_TABLE")
+	if tableName == "" {
+		return "example_user_block" // default fallback
+	}
+	return tableName
+}
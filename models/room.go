@@ -0,0 +1,44 @@
+package models
+
+import (
+	"os"
+	"time"
+)
+
+// Room is a persisted game room. Members aren't stored: they are only known
+// while connected.
+type Room struct {
+	ID          string           `json:"id" gorm:"primaryKey;type:varchar(255)"`
+	Name        string           `json:"name" gorm:"type:varchar(255);not null"`
+	CreatedBy   string           `json:"created_by" gorm:"type:varchar(255);not null"`
+	MaxPlayers  int              `json:"max_players" gorm:"not null;default:0"`
+	IsActive    bool             `json:"is_active" gorm:"not null;default:true;index"`
+	CreatedAt   time.Time        `json:"created_at" gorm:"autoCreateTime"`
+	ClosedAt    *time.Time       `json:"closed_at,omitempty"`
+	Invitations []RoomInvitation `json:"invitations,omitempty" gorm:"foreignKey:RoomID"`
+}
+
+// TableName returns the table name from environment variable
+func (Room) TableName() string {
+	tableName := os.Getenv("ROOM_TABLE")
+	if tableName == "" {
+		return "example_room" // default fallback
+	}
+	return tableName
+}
+
+// RoomInvitation allows a user to join a room when room authorization is enabled
+type RoomInvitation struct {
+	RoomID    string    `json:"room_id" gorm:"primaryKey;type:varchar(255)"`
+	UserID    string    `json:"user_id" gorm:"primaryKey;type:varchar(255)"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name from environment variable
+func (RoomInvitation) TableName() string {
+	tableName := os.Getenv("ROOM_INVITATION_TABLE")
+	if tableName == "" {
+		return "example_room_invitation" // default fallback
+	}
+	return tableName
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"os"
+	"time"
+)
+
+// SchemaMigration records a migration that has been applied to the database
+type SchemaMigration struct {
+	Version   int64     `json:"version" gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `json:"name" gorm:"type:varchar(255);not null"`
+	Checksum  string    `json:"checksum" gorm:"type:varchar(64);not null"`
+	AppliedAt time.Time `json:"applied_at" gorm:"not null"`
+}
+
+// TableName returns the table name from environment variable
+func (SchemaMigration) TableName() string {
+	tableName := os.Getenv("SCHEMA_MIGRATIONS_TABLE")
+	if tableName == "" {
+		return "example_schema_migrations" // default fallback
+	}
+	return tableName
+}
@@ -0,0 +1,577 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/OkanUysal/go-starter-example-project/models"
+)
+
+// MemoryUserRepository is an in-memory UserRepository for tests and local development
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[string]models.User
+}
+
+// NewMemoryUserRepository creates an empty in-memory user repository
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{users: make(map[string]models.User)}
+}
+
+// Create inserts a new user
+func (r *MemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.users[user.ID]; exists {
+		return ErrDuplicate
+	}
+	for _, existing := range r.users {
+		if sameOptional(existing.GuestID, user.GuestID) || sameOptional(existing.GoogleID, user.GoogleID) {
+			return ErrDuplicate
+		}
+	}
+
+	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+
+	r.users[user.ID] = copyUser(*user)
+	return nil
+}
+
+// GetByID returns a user by ID
+func (r *MemoryUserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	user = copyUser(user)
+	return &user, nil
+}
+
+// GetByGuestID returns a user by guest ID
+func (r *MemoryUserRepository) GetByGuestID(ctx context.Context, guestID string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.GuestID != nil && *user.GuestID == guestID {
+			user = copyUser(user)
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// List returns all users ordered by creation time
+func (r *MemoryUserRepository) List(ctx context.Context) ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]models.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, copyUser(user))
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].ID < users[j].ID
+		}
+		return users[i].CreatedAt.Before(users[j].CreatedAt)
+	})
+	return users, nil
+}
+
+// UpdateRole changes a user's role
+func (r *MemoryUserRepository) UpdateRole(ctx context.Context, id string, role models.UserRole) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return ErrNotFound
+	}
+	user.Role = role
+	user.UpdatedAt = time.Now()
+	r.users[id] = user
+	return nil
+}
+
+// Count returns the number of users matching the filter
+func (r *MemoryUserRepository) Count(ctx context.Context, filter UserFilter) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, user := range r.users {
+		if filter.Role != nil && user.Role != *filter.Role {
+			continue
+		}
+		if filter.IsGuest != nil && user.IsGuest != *filter.IsGuest {
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MemoryRevocationRepository is an in-memory RevocationRepository for tests and local development
+type MemoryRevocationRepository struct {
+	mu      sync.RWMutex
+	entries map[string]models.TokenBlacklist
+}
+
+// NewMemoryRevocationRepository creates an empty in-memory revocation repository
+func NewMemoryRevocationRepository() *MemoryRevocationRepository {
+	return &MemoryRevocationRepository{entries: make(map[string]models.TokenBlacklist)}
+}
+
+// Revoke records one or more revocations atomically
+func (r *MemoryRevocationRepository) Revoke(ctx context.Context, entries ...models.TokenBlacklist) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Validate the whole batch first so a duplicate leaves nothing half-written
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if _, exists := r.entries[entry.JTI]; exists || seen[entry.JTI] {
+			return ErrDuplicate
+		}
+		seen[entry.JTI] = true
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = now
+		}
+		r.entries[entry.JTI] = entry
+	}
+	return nil
+}
+
+// IsRevoked reports whether a token ID has been revoked
+func (r *MemoryRevocationRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, exists := r.entries[jti]
+	return exists, nil
+}
+
+// IsFamilyRevoked reports whether a token family has been revoked
+func (r *MemoryRevocationRepository) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, entry := range r.entries {
+		if entry.FamilyID != nil && *entry.FamilyID == familyID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DeleteExpired removes revocations that expired before now
+func (r *MemoryRevocationRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var removed int64
+	for jti, entry := range r.entries {
+		if entry.ExpiresAt.Before(now) {
+			delete(r.entries, jti)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// MemoryRoomRepository is an in-memory RoomRepository for tests and local development
+type MemoryRoomRepository struct {
+	mu    sync.RWMutex
+	rooms map[string]models.Room
+}
+
+// NewMemoryRoomRepository creates an empty in-memory room repository
+func NewMemoryRoomRepository() *MemoryRoomRepository {
+	return &MemoryRoomRepository{rooms: make(map[string]models.Room)}
+}
+
+// Create inserts a room with its invitations
+func (r *MemoryRoomRepository) Create(ctx context.Context, room *models.Room) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.rooms[room.ID]; exists {
+		return ErrDuplicate
+	}
+
+	now := time.Now()
+	if room.CreatedAt.IsZero() {
+		room.CreatedAt = now
+	}
+	for i := range room.Invitations {
+		room.Invitations[i].RoomID = room.ID
+		if room.Invitations[i].CreatedAt.IsZero() {
+			room.Invitations[i].CreatedAt = now
+		}
+	}
+
+	r.rooms[room.ID] = copyRoom(*room)
+	return nil
+}
+
+// Close marks a room inactive
+func (r *MemoryRoomRepository) Close(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, exists := r.rooms[id]
+	if !exists || !room.IsActive {
+		return ErrNotFound
+	}
+	now := time.Now()
+	room.IsActive = false
+	room.ClosedAt = &now
+	r.rooms[id] = room
+	return nil
+}
+
+// Invite allows users to join a room
+func (r *MemoryRoomRepository) Invite(ctx context.Context, roomID string, userIDs ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, exists := r.rooms[roomID]
+	if !exists {
+		return ErrNotFound
+	}
+
+	invited := make(map[string]bool, len(room.Invitations))
+	for _, invitation := range room.Invitations {
+		invited[invitation.UserID] = true
+	}
+	now := time.Now()
+	for _, userID := range userIDs {
+		if invited[userID] {
+			continue
+		}
+		invited[userID] = true
+		room.Invitations = append(room.Invitations, models.RoomInvitation{RoomID: roomID, UserID: userID, CreatedAt: now})
+	}
+	r.rooms[roomID] = room
+	return nil
+}
+
+// ListActive returns the active rooms with their invitations
+func (r *MemoryRoomRepository) ListActive(ctx context.Context) ([]models.Room, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rooms := make([]models.Room, 0, len(r.rooms))
+	for _, room := range r.rooms {
+		if room.IsActive {
+			rooms = append(rooms, copyRoom(room))
+		}
+	}
+
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].CreatedAt.Equal(rooms[j].CreatedAt) {
+			return rooms[i].ID < rooms[j].ID
+		}
+		return rooms[i].CreatedAt.Before(rooms[j].CreatedAt)
+	})
+	return rooms, nil
+}
+
+// MemoryMessageRepository is an in-memory MessageRepository that keeps the
+// latest messages of each room in a fixed-size ring buffer
+type MemoryMessageRepository struct {
+	mu     sync.RWMutex
+	size   int
+	rooms  map[string]*messageRing
+	lastID int64
+}
+
+// messageRing holds a room's latest messages; next is where the next one goes
+type messageRing struct {
+	messages []models.ChatMessage
+	next     int
+}
+
+// NewMemoryMessageRepository creates an empty chat history keeping up to size messages per room
+func NewMemoryMessageRepository(size int) *MemoryMessageRepository {
+	if size <= 0 {
+		size = 1
+	}
+	return &MemoryMessageRepository{size: size, rooms: make(map[string]*messageRing)}
+}
+
+// Append stores a message, overwriting the room's oldest one when its buffer is full
+func (r *MemoryMessageRepository) Append(ctx context.Context, message *models.ChatMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	message.ID = r.lastID
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+
+	ring, exists := r.rooms[message.RoomID]
+	if !exists {
+		ring = &messageRing{}
+		r.rooms[message.RoomID] = ring
+	}
+	if len(ring.messages) < r.size {
+		ring.messages = append(ring.messages, *message)
+		return nil
+	}
+	ring.messages[ring.next] = *message
+	ring.next = (ring.next + 1) % r.size
+	return nil
+}
+
+// ListBefore returns up to limit of a room's messages with an ID below before, oldest first
+func (r *MemoryMessageRepository) ListBefore(ctx context.Context, roomID string, before int64, limit int) ([]models.ChatMessage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ring, exists := r.rooms[roomID]
+	if !exists {
+		return []models.ChatMessage{}, nil
+	}
+
+	// Walk from the newest message back, then put the page in chronological order
+	messages := make([]models.ChatMessage, 0, min(limit, len(ring.messages)))
+	for i := 1; i <= len(ring.messages) && len(messages) < limit; i++ {
+		message := ring.messages[(ring.next-i+len(ring.messages))%len(ring.messages)]
+		if before > 0 && message.ID >= before {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+// MemoryDirectMessageRepository is an in-memory DirectMessageRepository for tests and local development
+type MemoryDirectMessageRepository struct {
+	mu       sync.RWMutex
+	messages []models.DirectMessage // in ID order
+}
+
+// NewMemoryDirectMessageRepository creates an empty in-memory direct message repository
+func NewMemoryDirectMessageRepository() *MemoryDirectMessageRepository {
+	return &MemoryDirectMessageRepository{}
+}
+
+// Create stores a message
+func (r *MemoryDirectMessageRepository) Create(ctx context.Context, message *models.DirectMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	message.ID = int64(len(r.messages) + 1)
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+	r.messages = append(r.messages, copyDirectMessage(*message))
+	return nil
+}
+
+// Undelivered returns up to limit undelivered messages to a user, oldest first
+func (r *MemoryDirectMessageRepository) Undelivered(ctx context.Context, recipientID string, limit int) ([]models.DirectMessage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	messages := []models.DirectMessage{}
+	for _, message := range r.messages {
+		if len(messages) == limit {
+			break
+		}
+		if message.RecipientID == recipientID && message.DeliveredAt == nil {
+			messages = append(messages, copyDirectMessage(message))
+		}
+	}
+	return messages, nil
+}
+
+// MarkDelivered records that messages reached their recipient
+func (r *MemoryDirectMessageRepository) MarkDelivered(ctx context.Context, recipientID string, ids ...int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range ids {
+		if id < 1 || id > int64(len(r.messages)) {
+			continue
+		}
+		message := &r.messages[id-1]
+		if message.RecipientID == recipientID && message.DeliveredAt == nil {
+			message.DeliveredAt = &now
+		}
+	}
+	return nil
+}
+
+// MarkRead marks a sender's unread messages up to upTo as read
+func (r *MemoryDirectMessageRepository) MarkRead(ctx context.Context, recipientID, senderID string, upTo int64) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var read int64
+	for i := range r.messages {
+		message := &r.messages[i]
+		if message.ID > upTo {
+			break
+		}
+		if message.RecipientID != recipientID || message.SenderID != senderID || message.ReadAt != nil {
+			continue
+		}
+		readAt := now
+		message.ReadAt = &readAt
+		if message.DeliveredAt == nil {
+			message.DeliveredAt = &readAt
+		}
+		read++
+	}
+	return read, nil
+}
+
+// Conversation returns up to limit messages exchanged by two users, oldest first
+func (r *MemoryDirectMessageRepository) Conversation(ctx context.Context, userID, otherID string, before int64, limit int) ([]models.DirectMessage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Walk from the newest message back, then put the page in chronological order
+	messages := []models.DirectMessage{}
+	for i := len(r.messages) - 1; i >= 0 && len(messages) < limit; i-- {
+		message := r.messages[i]
+		if before > 0 && message.ID >= before {
+			continue
+		}
+		if (message.SenderID == userID && message.RecipientID == otherID) ||
+			(message.SenderID == otherID && message.RecipientID == userID) {
+			messages = append(messages, copyDirectMessage(message))
+		}
+	}
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+// MemoryBlockRepository is an in-memory BlockRepository for tests and local development
+type MemoryBlockRepository struct {
+	mu     sync.RWMutex
+	blocks map[[2]string]models.UserBlock // keyed by blocker and blocked ID
+}
+
+// NewMemoryBlockRepository creates an empty in-memory block repository
+func NewMemoryBlockRepository() *MemoryBlockRepository {
+	return &MemoryBlockRepository{blocks: make(map[[2]string]models.UserBlock)}
+}
+
+// Block stops blockedID from messaging blockerID
+func (r *MemoryBlockRepository) Block(ctx context.Context, blockerID, blockedID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [2]string{blockerID, blockedID}
+	if _, exists := r.blocks[key]; !exists {
+		r.blocks[key] = models.UserBlock{BlockerID: blockerID, BlockedID: blockedID, CreatedAt: time.Now()}
+	}
+	return nil
+}
+
+// Unblock lifts a block
+func (r *MemoryBlockRepository) Unblock(ctx context.Context, blockerID, blockedID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.blocks, [2]string{blockerID, blockedID})
+	return nil
+}
+
+// IsBlocked reports whether blockerID blocks blockedID
+func (r *MemoryBlockRepository) IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, exists := r.blocks[[2]string{blockerID, blockedID}]
+	return exists, nil
+}
+
+// ListBlocked returns the users blockerID blocks, oldest block first
+func (r *MemoryBlockRepository) ListBlocked(ctx context.Context, blockerID string) ([]models.UserBlock, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	blocks := []models.UserBlock{}
+	for _, block := range r.blocks {
+		if block.BlockerID == blockerID {
+			blocks = append(blocks, block)
+		}
+	}
+
+	sort.Slice(blocks, func(i, j int) bool {
+		if blocks[i].CreatedAt.Equal(blocks[j].CreatedAt) {
+			return blocks[i].BlockedID < blocks[j].BlockedID
+		}
+		return blocks[i].CreatedAt.Before(blocks[j].CreatedAt)
+	})
+	return blocks, nil
+}
+
+// sameOptional reports whether two optional unique values collide
+func sameOptional(a, b *string) bool {
+	return a != nil && b != nil && *a == *b
+}
+
+// copyUser returns a copy that doesn't share pointer fields with the stored user
+func copyUser(user models.User) models.User {
+	if user.GuestID != nil {
+		guestID := *user.GuestID
+		user.GuestID = &guestID
+	}
+	if user.GoogleID != nil {
+		googleID := *user.GoogleID
+		user.GoogleID = &googleID
+	}
+	return user
+}
+
+// copyRoom returns a copy that doesn't share its invitations or close time with the stored room
+func copyRoom(room models.Room) models.Room {
+	room.Invitations = append([]models.RoomInvitation(nil), room.Invitations...)
+	if room.ClosedAt != nil {
+		closedAt := *room.ClosedAt
+		room.ClosedAt = &closedAt
+	}
+	return room
+}
+
+// copyDirectMessage returns a copy that doesn't share its delivery and read times with the stored message
+func copyDirectMessage(message models.DirectMessage) models.DirectMessage {
+	if message.DeliveredAt != nil {
+		deliveredAt := *message.DeliveredAt
+		message.DeliveredAt = &deliveredAt
+	}
+	if message.ReadAt != nil {
+		readAt := *message.ReadAt
+		message.ReadAt = &readAt
+	}
+	return message
+}
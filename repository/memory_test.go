@@ -0,0 +1,77 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+	"github.com/OkanUysal/go-starter-example-project/repository/repositorytest"
+)
+
+func TestMemoryUserRepository(t *testing.T) {
+	repositorytest.UserRepositoryContract(t, func(t *testing.T) repository.UserRepository {
+		return repository.NewMemoryUserRepository()
+	})
+}
+
+func TestMemoryRevocationRepository(t *testing.T) {
+	repositorytest.RevocationRepositoryContract(t, func(t *testing.T) repository.RevocationRepository {
+		return repository.NewMemoryRevocationRepository()
+	})
+}
+
+func TestMemoryRoomRepository(t *testing.T) {
+	repositorytest.RoomRepositoryContract(t, func(t *testing.T) repository.RoomRepository {
+		return repository.NewMemoryRoomRepository()
+	})
+}
+
+func TestMemoryMessageRepository(t *testing.T) {
+	repositorytest.MessageRepositoryContract(t, func(t *testing.T) repository.MessageRepository {
+		return repository.NewMemoryMessageRepository(10)
+	})
+}
+
+func TestMemoryDirectMessageRepository(t *testing.T) {
+	repositorytest.DirectMessageRepositoryContract(t, func(t *testing.T) repository.DirectMessageRepository {
+		return repository.NewMemoryDirectMessageRepository()
+	})
+}
+
+func TestMemoryBlockRepository(t *testing.T) {
+	repositorytest.BlockRepositoryContract(t, func(t *testing.T) repository.BlockRepository {
+		return repository.NewMemoryBlockRepository()
+	})
+}
+
+func TestMemoryMessageRepositoryKeepsLatest(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryMessageRepository(3)
+
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		message := models.ChatMessage{RoomID: "room-1", UserID: "u1", Username: "User", Content: "hi"}
+		if err := repo.Append(ctx, &message); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		ids = append(ids, message.ID)
+	}
+
+	// Only the last three fit, and they still come back oldest first
+	messages, err := repo.ListBefore(ctx, "room-1", 0, 10)
+	if err != nil {
+		t.Fatalf("ListBefore: %v", err)
+	}
+	if len(messages) != 3 || messages[0].ID != ids[2] || messages[2].ID != ids[4] {
+		t.Fatalf("ListBefore = %v, want IDs %v", messages, ids[2:])
+	}
+
+	messages, err = repo.ListBefore(ctx, "room-1", ids[4], 10)
+	if err != nil {
+		t.Fatalf("ListBefore: %v", err)
+	}
+	if len(messages) != 2 || messages[0].ID != ids[2] || messages[1].ID != ids[3] {
+		t.Errorf("ListBefore(%d) = %v, want IDs %v", ids[4], messages, ids[2:4])
+	}
+}
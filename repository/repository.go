@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/OkanUysal/go-starter-example-project/models"
+)
+
+var (
+	ErrNotFound  = errors.New("record not found")
+	ErrDuplicate = errors.New("record already exists")
+)
+
+// UserRepository stores users
+type UserRepository interface {
+	// Create inserts a new user; ErrDuplicate if the ID or guest ID is taken
+	Create(ctx context.Context, user *models.User) error
+
+	// GetByID returns a user by ID; ErrNotFound if it doesn't exist
+	GetByID(ctx context.Context, id string) (*models.User, error)
+
+	// GetByGuestID returns a user by guest ID; ErrNotFound if it doesn't exist
+	GetByGuestID(ctx context.Context, guestID string) (*models.User, error)
+
+	// List returns all users ordered by creation time
+	List(ctx context.Context) ([]models.User, error)
+
+	// UpdateRole changes a user's role; ErrNotFound if the user doesn't exist
+	UpdateRole(ctx context.Context, id string, role models.UserRole) error
+
+	// Count returns the number of users matching the filter
+	Count(ctx context.Context, filter UserFilter) (int64, error)
+}
+
+// UserFilter narrows down Count. Nil fields match everything.
+type UserFilter struct {
+	Role    *models.UserRole
+	IsGuest *bool
+}
+
+// RevocationRepository stores revoked (blacklisted) tokens and token families
+type RevocationRepository interface {
+	// Revoke records one or more revocations atomically
+	Revoke(ctx context.Context, entries ...models.TokenBlacklist) error
+
+	// IsRevoked reports whether a token ID has been revoked
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+
+	// IsFamilyRevoked reports whether a token family has been revoked
+	IsFamilyRevoked(ctx context.Context, familyID string) (bool, error)
+
+	// DeleteExpired removes revocations that expired before now and returns how many were removed
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// RoomRepository stores game rooms and their invitations so they survive restarts
+type RoomRepository interface {
+	// Create inserts a room with its invitations; ErrDuplicate if the ID is taken
+	Create(ctx context.Context, room *models.Room) error
+
+	// Close marks a room inactive; ErrNotFound if there is no active room with that ID
+	Close(ctx context.Context, id string) error
+
+	// Invite allows users to join a room, ignoring those already invited;
+	// ErrNotFound if the room doesn't exist
+	Invite(ctx context.Context, roomID string, userIDs ...string) error
+
+	// ListActive returns the active rooms with their invitations, ordered by creation time
+	ListActive(ctx context.Context) ([]models.Room, error)
+}
+
+// MessageRepository stores the chat history of rooms
+type MessageRepository interface {
+	// Append stores a message, setting its ID and creation time
+	Append(ctx context.Context, message *models.ChatMessage) error
+
+	// ListBefore returns up to limit of a room's messages with an ID below before
+	// (0: the latest messages), oldest first
+	ListBefore(ctx context.Context, roomID string, before int64, limit int) ([]models.ChatMessage, error)
+}
+
+// DirectMessageRepository stores direct messages between users
+type DirectMessageRepository interface {
+	// Create stores a message, setting its ID and creation time
+	Create(ctx context.Context, message *models.DirectMessage) error
+
+	// Undelivered returns up to limit messages to a user that haven't been delivered yet, oldest first
+	Undelivered(ctx context.Context, recipientID string, limit int) ([]models.DirectMessage, error)
+
+	// MarkDelivered records that messages reached their recipient
+	MarkDelivered(ctx context.Context, recipientID string, ids ...int64) error
+
+	// MarkRead marks the unread messages from senderID to recipientID with an ID
+	// up to upTo as read (and delivered), and returns how many there were
+	MarkRead(ctx context.Context, recipientID, senderID string, upTo int64) (int64, error)
+
+	// Conversation returns up to limit messages exchanged by two users with an ID
+	// below before (0: the latest messages), oldest first
+	Conversation(ctx context.Context, userID, otherID string, before int64, limit int) ([]models.DirectMessage, error)
+}
+
+// BlockRepository stores which users refuse direct messages from which
+type BlockRepository interface {
+	// Block stops blockedID from messaging blockerID; blocking twice is not an error
+	Block(ctx context.Context, blockerID, blockedID string) error
+
+	// Unblock lifts a block; unblocking a user who isn't blocked is not an error
+	Unblock(ctx context.Context, blockerID, blockedID string) error
+
+	// IsBlocked reports whether blockerID blocks blockedID
+	IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error)
+
+	// ListBlocked returns the users blockerID blocks, oldest block first
+	ListBlocked(ctx context.Context, blockerID string) ([]models.UserBlock, error)
+}
@@ -0,0 +1,715 @@
+// Package repositorytest holds contract tests that every repository implementation must pass
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+	"github.com/google/uuid"
+)
+
+// UserRepositoryContract runs the shared UserRepository tests.
+// newRepo must return an empty repository for every call.
+func UserRepositoryContract(t *testing.T, newRepo func(t *testing.T) repository.UserRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGetByID", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(models.RoleUser, true)
+
+		if err := repo.Create(ctx, &user); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		got, err := repo.GetByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.ID != user.ID || got.DisplayName != user.DisplayName || got.Role != user.Role || got.IsGuest != user.IsGuest {
+			t.Errorf("GetByID = %+v, want %+v", got, user)
+		}
+		if got.GuestID == nil || *got.GuestID != *user.GuestID {
+			t.Errorf("GetByID guest_id = %v, want %s", got.GuestID, *user.GuestID)
+		}
+		if got.CreatedAt.IsZero() {
+			t.Error("CreatedAt was not set")
+		}
+	})
+
+	t.Run("GetByIDNotFound", func(t *testing.T) {
+		repo := newRepo(t)
+		if _, err := repo.GetByID(ctx, uuid.New().String()); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("GetByID error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("GetByGuestID", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(models.RoleUser, true)
+		mustCreate(t, repo, &user)
+
+		got, err := repo.GetByGuestID(ctx, *user.GuestID)
+		if err != nil {
+			t.Fatalf("GetByGuestID: %v", err)
+		}
+		if got.ID != user.ID {
+			t.Errorf("GetByGuestID returned user %s, want %s", got.ID, user.ID)
+		}
+
+		if _, err := repo.GetByGuestID(ctx, uuid.New().String()); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("GetByGuestID error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("CreateDuplicate", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(models.RoleUser, true)
+		mustCreate(t, repo, &user)
+
+		sameID := newUser(models.RoleUser, true)
+		sameID.ID = user.ID
+		if err := repo.Create(ctx, &sameID); !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("Create with duplicate ID error = %v, want ErrDuplicate", err)
+		}
+
+		sameGuestID := newUser(models.RoleUser, true)
+		sameGuestID.GuestID = user.GuestID
+		if err := repo.Create(ctx, &sameGuestID); !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("Create with duplicate guest ID error = %v, want ErrDuplicate", err)
+		}
+	})
+
+	t.Run("ListInCreationOrder", func(t *testing.T) {
+		repo := newRepo(t)
+		base := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+		var ids []string
+		for i := 0; i < 3; i++ {
+			user := newUser(models.RoleUser, true)
+			user.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+			mustCreate(t, repo, &user)
+			ids = append(ids, user.ID)
+		}
+
+		users, err := repo.List(ctx)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(users) != len(ids) {
+			t.Fatalf("List returned %d users, want %d", len(users), len(ids))
+		}
+		for i, user := range users {
+			if user.ID != ids[i] {
+				t.Errorf("List[%d] = %s, want %s", i, user.ID, ids[i])
+			}
+		}
+	})
+
+	t.Run("UpdateRole", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(models.RoleUser, true)
+		mustCreate(t, repo, &user)
+
+		if err := repo.UpdateRole(ctx, user.ID, models.RoleAdmin); err != nil {
+			t.Fatalf("UpdateRole: %v", err)
+		}
+
+		got, err := repo.GetByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.Role != models.RoleAdmin {
+			t.Errorf("role = %s, want %s", got.Role, models.RoleAdmin)
+		}
+
+		if err := repo.UpdateRole(ctx, uuid.New().String(), models.RoleAdmin); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("UpdateRole error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Count", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, u := range []models.User{
+			newUser(models.RoleUser, true),
+			newUser(models.RoleUser, true),
+			newUser(models.RoleUser, false),
+			newUser(models.RoleAdmin, false),
+		} {
+			mustCreate(t, repo, &u)
+		}
+
+		admin := models.RoleAdmin
+		guest := true
+		cases := []struct {
+			name   string
+			filter repository.UserFilter
+			want   int64
+		}{
+			{"all", repository.UserFilter{}, 4},
+			{"admins", repository.UserFilter{Role: &admin}, 1},
+			{"guests", repository.UserFilter{IsGuest: &guest}, 2},
+			{"guest admins", repository.UserFilter{Role: &admin, IsGuest: &guest}, 0},
+		}
+		for _, tc := range cases {
+			got, err := repo.Count(ctx, tc.filter)
+			if err != nil {
+				t.Fatalf("Count(%s): %v", tc.name, err)
+			}
+			if got != tc.want {
+				t.Errorf("Count(%s) = %d, want %d", tc.name, got, tc.want)
+			}
+		}
+	})
+}
+
+// RevocationRepositoryContract runs the shared RevocationRepository tests.
+// newRepo must return an empty repository for every call.
+func RevocationRepositoryContract(t *testing.T, newRepo func(t *testing.T) repository.RevocationRepository) {
+	ctx := context.Background()
+
+	t.Run("RevokeToken", func(t *testing.T) {
+		repo := newRepo(t)
+		jti := uuid.New().String()
+
+		assertRevoked(t, repo.IsRevoked, jti, false)
+		if err := repo.Revoke(ctx, newRevocation(jti, nil, time.Hour)); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+		assertRevoked(t, repo.IsRevoked, jti, true)
+	})
+
+	t.Run("RevokeFamily", func(t *testing.T) {
+		repo := newRepo(t)
+		familyID := uuid.New().String()
+
+		assertRevoked(t, repo.IsFamilyRevoked, familyID, false)
+		if err := repo.Revoke(ctx, newRevocation(familyID, &familyID, time.Hour)); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+		assertRevoked(t, repo.IsFamilyRevoked, familyID, true)
+		assertRevoked(t, repo.IsFamilyRevoked, uuid.New().String(), false)
+	})
+
+	t.Run("RevokeBatchIsAtomic", func(t *testing.T) {
+		repo := newRepo(t)
+		existing := uuid.New().String()
+		fresh := uuid.New().String()
+
+		if err := repo.Revoke(ctx, newRevocation(existing, nil, time.Hour)); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+
+		err := repo.Revoke(ctx, newRevocation(fresh, nil, time.Hour), newRevocation(existing, nil, time.Hour))
+		if !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("Revoke error = %v, want ErrDuplicate", err)
+		}
+		assertRevoked(t, repo.IsRevoked, fresh, false)
+	})
+
+	t.Run("DeleteExpired", func(t *testing.T) {
+		repo := newRepo(t)
+		expired := uuid.New().String()
+		active := uuid.New().String()
+
+		err := repo.Revoke(ctx,
+			newRevocation(expired, nil, -time.Hour),
+			newRevocation(active, nil, time.Hour))
+		if err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+
+		removed, err := repo.DeleteExpired(ctx, time.Now())
+		if err != nil {
+			t.Fatalf("DeleteExpired: %v", err)
+		}
+		if removed != 1 {
+			t.Errorf("DeleteExpired removed %d, want 1", removed)
+		}
+		assertRevoked(t, repo.IsRevoked, expired, false)
+		assertRevoked(t, repo.IsRevoked, active, true)
+	})
+}
+
+// RoomRepositoryContract runs the shared RoomRepository tests.
+// newRepo must return an empty repository for every call.
+func RoomRepositoryContract(t *testing.T, newRepo func(t *testing.T) repository.RoomRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateAndListActive", func(t *testing.T) {
+		repo := newRepo(t)
+		room := newRoom("creator", "creator")
+
+		if err := repo.Create(ctx, &room); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		rooms, err := repo.ListActive(ctx)
+		if err != nil {
+			t.Fatalf("ListActive: %v", err)
+		}
+		if len(rooms) != 1 {
+			t.Fatalf("ListActive returned %d rooms, want 1", len(rooms))
+		}
+		got := rooms[0]
+		if got.ID != room.ID || got.Name != room.Name || got.CreatedBy != room.CreatedBy || got.MaxPlayers != room.MaxPlayers || !got.IsActive {
+			t.Errorf("ListActive[0] = %+v, want %+v", got, room)
+		}
+		if got.CreatedAt.IsZero() {
+			t.Error("CreatedAt was not set")
+		}
+		assertInvited(t, got, "creator")
+
+		if err := repo.Create(ctx, &room); !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("Create with duplicate ID error = %v, want ErrDuplicate", err)
+		}
+	})
+
+	t.Run("ListActiveInCreationOrder", func(t *testing.T) {
+		repo := newRepo(t)
+		base := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+		var ids []string
+		for i := 0; i < 3; i++ {
+			room := newRoom("creator")
+			room.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+			mustCreateRoom(t, repo, &room)
+			ids = append(ids, room.ID)
+		}
+
+		rooms, err := repo.ListActive(ctx)
+		if err != nil {
+			t.Fatalf("ListActive: %v", err)
+		}
+		if len(rooms) != len(ids) {
+			t.Fatalf("ListActive returned %d rooms, want %d", len(rooms), len(ids))
+		}
+		for i, room := range rooms {
+			if room.ID != ids[i] {
+				t.Errorf("ListActive[%d] = %s, want %s", i, room.ID, ids[i])
+			}
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		repo := newRepo(t)
+		closed := newRoom("creator")
+		open := newRoom("creator")
+		mustCreateRoom(t, repo, &closed)
+		mustCreateRoom(t, repo, &open)
+
+		if err := repo.Close(ctx, closed.ID); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		if err := repo.Close(ctx, closed.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("second Close error = %v, want ErrNotFound", err)
+		}
+		if err := repo.Close(ctx, uuid.New().String()); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Close of unknown room error = %v, want ErrNotFound", err)
+		}
+
+		rooms, err := repo.ListActive(ctx)
+		if err != nil {
+			t.Fatalf("ListActive: %v", err)
+		}
+		if len(rooms) != 1 || rooms[0].ID != open.ID {
+			t.Errorf("ListActive after Close = %v, want only %s", rooms, open.ID)
+		}
+	})
+
+	t.Run("Invite", func(t *testing.T) {
+		repo := newRepo(t)
+		room := newRoom("creator", "u1")
+		mustCreateRoom(t, repo, &room)
+
+		// Inviting someone twice is not an error
+		if err := repo.Invite(ctx, room.ID, "u1", "u2", "u2"); err != nil {
+			t.Fatalf("Invite: %v", err)
+		}
+		if err := repo.Invite(ctx, room.ID, "u3"); err != nil {
+			t.Fatalf("second Invite: %v", err)
+		}
+
+		rooms, err := repo.ListActive(ctx)
+		if err != nil {
+			t.Fatalf("ListActive: %v", err)
+		}
+		if len(rooms) != 1 {
+			t.Fatalf("ListActive returned %d rooms, want 1", len(rooms))
+		}
+		assertInvited(t, rooms[0], "u1", "u2", "u3")
+
+		if err := repo.Invite(ctx, uuid.New().String(), "u1"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Invite to unknown room error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+// MessageRepositoryContract runs the shared MessageRepository tests.
+// newRepo must return an empty repository holding at least 10 messages per room.
+func MessageRepositoryContract(t *testing.T, newRepo func(t *testing.T) repository.MessageRepository) {
+	ctx := context.Background()
+
+	t.Run("AppendAssignsIncreasingIDs", func(t *testing.T) {
+		repo := newRepo(t)
+		first := appendMessages(t, repo, "room-1", 1)[0]
+		second := appendMessages(t, repo, "room-2", 1)[0]
+
+		if first.ID <= 0 || second.ID <= first.ID {
+			t.Errorf("IDs = %d, %d; want positive and increasing", first.ID, second.ID)
+		}
+		if first.CreatedAt.IsZero() {
+			t.Error("CreatedAt was not set")
+		}
+	})
+
+	t.Run("ListBeforePagesBackwards", func(t *testing.T) {
+		repo := newRepo(t)
+		sent := appendMessages(t, repo, "room-1", 5)
+		appendMessages(t, repo, "room-2", 2)
+
+		// The latest page, oldest first
+		latest, err := repo.ListBefore(ctx, "room-1", 0, 3)
+		if err != nil {
+			t.Fatalf("ListBefore: %v", err)
+		}
+		assertMessages(t, latest, sent[2:])
+		if latest[0].Content != sent[2].Content || latest[0].UserID != sent[2].UserID || latest[0].Username != sent[2].Username {
+			t.Errorf("ListBefore[0] = %+v, want %+v", latest[0], sent[2])
+		}
+
+		// The page before it holds what's left
+		older, err := repo.ListBefore(ctx, "room-1", latest[0].ID, 3)
+		if err != nil {
+			t.Fatalf("ListBefore: %v", err)
+		}
+		assertMessages(t, older, sent[:2])
+	})
+
+	t.Run("ListBeforeEmptyRoom", func(t *testing.T) {
+		repo := newRepo(t)
+		messages, err := repo.ListBefore(ctx, "room-1", 0, 10)
+		if err != nil {
+			t.Fatalf("ListBefore: %v", err)
+		}
+		if len(messages) != 0 {
+			t.Errorf("ListBefore of an empty room = %v, want none", messages)
+		}
+	})
+}
+
+// DirectMessageRepositoryContract runs the behavioural tests every DirectMessageRepository must pass
+func DirectMessageRepositoryContract(t *testing.T, newRepo func(t *testing.T) repository.DirectMessageRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateAssignsIncreasingIDs", func(t *testing.T) {
+		repo := newRepo(t)
+		first := sendDirectMessages(t, repo, "u1", "u2", 1)[0]
+		second := sendDirectMessages(t, repo, "u2", "u1", 1)[0]
+
+		if first.ID <= 0 || second.ID <= first.ID {
+			t.Errorf("IDs = %d, %d; want positive and increasing", first.ID, second.ID)
+		}
+		if first.CreatedAt.IsZero() {
+			t.Error("CreatedAt was not set")
+		}
+	})
+
+	t.Run("UndeliveredUntilMarked", func(t *testing.T) {
+		repo := newRepo(t)
+		sent := sendDirectMessages(t, repo, "u1", "u2", 3)
+		reply := sendDirectMessages(t, repo, "u2", "u1", 1)
+
+		pending, err := repo.Undelivered(ctx, "u2", 2)
+		if err != nil {
+			t.Fatalf("Undelivered: %v", err)
+		}
+		assertDirectMessages(t, pending, sent[:2])
+		if pending[0].Content != sent[0].Content || pending[0].SenderID != "u1" {
+			t.Errorf("Undelivered[0] = %+v, want %+v", pending[0], sent[0])
+		}
+
+		// Another recipient's ID is ignored
+		if err := repo.MarkDelivered(ctx, "u2", sent[0].ID, sent[1].ID, reply[0].ID); err != nil {
+			t.Fatalf("MarkDelivered: %v", err)
+		}
+		pending, err = repo.Undelivered(ctx, "u2", 10)
+		if err != nil {
+			t.Fatalf("Undelivered: %v", err)
+		}
+		assertDirectMessages(t, pending, sent[2:])
+		pending, err = repo.Undelivered(ctx, "u1", 10)
+		if err != nil {
+			t.Fatalf("Undelivered: %v", err)
+		}
+		assertDirectMessages(t, pending, reply)
+	})
+
+	t.Run("MarkRead", func(t *testing.T) {
+		repo := newRepo(t)
+		sent := sendDirectMessages(t, repo, "u1", "u2", 3)
+		other := sendDirectMessages(t, repo, "u3", "u2", 1)
+
+		read, err := repo.MarkRead(ctx, "u2", "u1", sent[1].ID)
+		if err != nil {
+			t.Fatalf("MarkRead: %v", err)
+		}
+		if read != 2 {
+			t.Errorf("MarkRead read %d messages, want 2", read)
+		}
+
+		// Reading again changes nothing; reading implies delivery
+		if read, _ := repo.MarkRead(ctx, "u2", "u1", sent[1].ID); read != 0 {
+			t.Errorf("second MarkRead read %d messages, want 0", read)
+		}
+		pending, err := repo.Undelivered(ctx, "u2", 10)
+		if err != nil {
+			t.Fatalf("Undelivered: %v", err)
+		}
+		assertDirectMessages(t, pending, []models.DirectMessage{sent[2], other[0]})
+
+		conversation, err := repo.Conversation(ctx, "u1", "u2", 0, 10)
+		if err != nil {
+			t.Fatalf("Conversation: %v", err)
+		}
+		for i, message := range conversation {
+			if (message.ReadAt != nil) != (i < 2) {
+				t.Errorf("message %d read at %v, want read = %v", message.ID, message.ReadAt, i < 2)
+			}
+		}
+	})
+
+	t.Run("ConversationPagesBackwards", func(t *testing.T) {
+		repo := newRepo(t)
+		var sent []models.DirectMessage
+		for i := 0; i < 5; i++ {
+			from, to := "u1", "u2"
+			if i%2 == 1 {
+				from, to = to, from
+			}
+			sent = append(sent, sendDirectMessages(t, repo, from, to, 1)...)
+		}
+		sendDirectMessages(t, repo, "u1", "u3", 1)
+
+		latest, err := repo.Conversation(ctx, "u2", "u1", 0, 3)
+		if err != nil {
+			t.Fatalf("Conversation: %v", err)
+		}
+		assertDirectMessages(t, latest, sent[2:])
+
+		older, err := repo.Conversation(ctx, "u1", "u2", latest[0].ID, 3)
+		if err != nil {
+			t.Fatalf("Conversation: %v", err)
+		}
+		assertDirectMessages(t, older, sent[:2])
+	})
+}
+
+// BlockRepositoryContract runs the behavioural tests every BlockRepository must pass
+func BlockRepositoryContract(t *testing.T, newRepo func(t *testing.T) repository.BlockRepository) {
+	ctx := context.Background()
+
+	t.Run("BlockAndUnblock", func(t *testing.T) {
+		repo := newRepo(t)
+		for i := 0; i < 2; i++ {
+			if err := repo.Block(ctx, "u1", "u2"); err != nil {
+				t.Fatalf("Block (attempt %d): %v", i+1, err)
+			}
+		}
+		assertBlocked(t, repo, "u1", "u2", true)
+		// Blocking is one way
+		assertBlocked(t, repo, "u2", "u1", false)
+
+		for i := 0; i < 2; i++ {
+			if err := repo.Unblock(ctx, "u1", "u2"); err != nil {
+				t.Fatalf("Unblock (attempt %d): %v", i+1, err)
+			}
+		}
+		assertBlocked(t, repo, "u1", "u2", false)
+	})
+
+	t.Run("ListBlocked", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, blockedID := range []string{"u2", "u3"} {
+			if err := repo.Block(ctx, "u1", blockedID); err != nil {
+				t.Fatalf("Block: %v", err)
+			}
+		}
+		if err := repo.Block(ctx, "u2", "u1"); err != nil {
+			t.Fatalf("Block: %v", err)
+		}
+
+		blocks, err := repo.ListBlocked(ctx, "u1")
+		if err != nil {
+			t.Fatalf("ListBlocked: %v", err)
+		}
+		got := map[string]bool{}
+		for _, block := range blocks {
+			got[block.BlockedID] = block.BlockerID == "u1" && !block.CreatedAt.IsZero()
+		}
+		if len(blocks) != 2 || !got["u2"] || !got["u3"] {
+			t.Errorf("ListBlocked = %v, want u2 and u3", blocks)
+		}
+	})
+}
+
+// newUser returns an unsaved user with unique IDs
+func newUser(role models.UserRole, isGuest bool) models.User {
+	user := models.User{
+		ID:          uuid.New().String(),
+		DisplayName: "User " + uuid.New().String()[:8],
+		Role:        role,
+		IsGuest:     isGuest,
+	}
+	if isGuest {
+		guestID := uuid.New().String()
+		user.GuestID = &guestID
+	}
+	return user
+}
+
+// newRevocation returns a revocation expiring ttl from now
+func newRevocation(jti string, familyID *string, ttl time.Duration) models.TokenBlacklist {
+	return models.TokenBlacklist{
+		JTI:       jti,
+		UserID:    uuid.New().String(),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+}
+
+// newRoom returns an unsaved active room with a unique ID, inviting userIDs
+func newRoom(createdBy string, userIDs ...string) models.Room {
+	room := models.Room{
+		ID:         uuid.New().String(),
+		Name:       "Room " + uuid.New().String()[:8],
+		CreatedBy:  createdBy,
+		MaxPlayers: 4,
+		IsActive:   true,
+	}
+	for _, userID := range userIDs {
+		room.Invitations = append(room.Invitations, models.RoomInvitation{UserID: userID})
+	}
+	return room
+}
+
+// mustCreate creates a user or fails the test
+func mustCreate(t *testing.T, repo repository.UserRepository, user *models.User) {
+	t.Helper()
+	if err := repo.Create(context.Background(), user); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+}
+
+// mustCreateRoom creates a room or fails the test
+func mustCreateRoom(t *testing.T, repo repository.RoomRepository, room *models.Room) {
+	t.Helper()
+	if err := repo.Create(context.Background(), room); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+}
+
+// assertInvited checks that exactly userIDs are invited to a room
+func assertInvited(t *testing.T, room models.Room, userIDs ...string) {
+	t.Helper()
+	got := make(map[string]bool, len(room.Invitations))
+	for _, invitation := range room.Invitations {
+		got[invitation.UserID] = true
+	}
+	if len(room.Invitations) != len(userIDs) {
+		t.Errorf("room %s has %d invitations, want %v", room.ID, len(room.Invitations), userIDs)
+	}
+	for _, userID := range userIDs {
+		if !got[userID] {
+			t.Errorf("room %s: %s is not invited", room.ID, userID)
+		}
+	}
+}
+
+// appendMessages appends n chat messages to a room and returns them as stored
+func appendMessages(t *testing.T, repo repository.MessageRepository, roomID string, n int) []models.ChatMessage {
+	t.Helper()
+	messages := make([]models.ChatMessage, n)
+	for i := range messages {
+		messages[i] = models.ChatMessage{
+			RoomID:   roomID,
+			UserID:   uuid.New().String(),
+			Username: "User " + uuid.New().String()[:8],
+			Content:  "message " + uuid.New().String()[:8],
+		}
+		if err := repo.Append(context.Background(), &messages[i]); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	return messages
+}
+
+// assertMessages checks that got holds the wanted messages in order
+func assertMessages(t *testing.T, got, want []models.ChatMessage) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].ID != want[i].ID {
+			t.Errorf("message %d has ID %d, want %d", i, got[i].ID, want[i].ID)
+		}
+	}
+}
+
+// sendDirectMessages stores n direct messages from one user to another and returns them as stored
+func sendDirectMessages(t *testing.T, repo repository.DirectMessageRepository, senderID, recipientID string, n int) []models.DirectMessage {
+	t.Helper()
+	messages := make([]models.DirectMessage, n)
+	for i := range messages {
+		messages[i] = models.DirectMessage{
+			SenderID:    senderID,
+			RecipientID: recipientID,
+			Content:     "message " + uuid.New().String()[:8],
+		}
+		if err := repo.Create(context.Background(), &messages[i]); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	return messages
+}
+
+// assertDirectMessages checks that got holds the wanted messages in order
+func assertDirectMessages(t *testing.T, got, want []models.DirectMessage) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].ID != want[i].ID {
+			t.Errorf("message %d has ID %d, want %d", i, got[i].ID, want[i].ID)
+		}
+	}
+}
+
+// assertBlocked checks whether blockerID blocks blockedID
+func assertBlocked(t *testing.T, repo repository.BlockRepository, blockerID, blockedID string, want bool) {
+	t.Helper()
+	got, err := repo.IsBlocked(context.Background(), blockerID, blockedID)
+	if err != nil {
+		t.Fatalf("IsBlocked(%s, %s): %v", blockerID, blockedID, err)
+	}
+	if got != want {
+		t.Errorf("IsBlocked(%s, %s) = %v, want %v", blockerID, blockedID, got, want)
+	}
+}
+
+// assertRevoked checks a revocation lookup result
+func assertRevoked(t *testing.T, lookup func(context.Context, string) (bool, error), id string, want bool) {
+	t.Helper()
+	got, err := lookup(context.Background(), id)
+	if err != nil {
+		t.Fatalf("revocation lookup for %s: %v", id, err)
+	}
+	if got != want {
+		t.Errorf("revoked(%s) = %v, want %v", id, got, want)
+	}
+}
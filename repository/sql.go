@@ -0,0 +1,355 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SQLUserRepository is a UserRepository backed by GORM (Postgres or SQLite)
+type SQLUserRepository struct {
+	db       *gorm.DB
+	replicas []*gorm.DB
+	next     atomic.Uint64
+}
+
+// NewSQLUserRepository creates a user repository writing to db.
+// List, which tolerates replication lag, is spread round-robin over the
+// replicas when any are given.
+func NewSQLUserRepository(db *gorm.DB, replicas ...*gorm.DB) *SQLUserRepository {
+	return &SQLUserRepository{db: db, replicas: replicas}
+}
+
+// reader returns the next replica, or the primary if there are none
+func (r *SQLUserRepository) reader() *gorm.DB {
+	if len(r.replicas) == 0 {
+		return r.db
+	}
+	return r.replicas[(r.next.Add(1)-1)%uint64(len(r.replicas))]
+}
+
+// Create inserts a new user
+func (r *SQLUserRepository) Create(ctx context.Context, user *models.User) error {
+	return translateError(r.db.WithContext(ctx).Create(user).Error)
+}
+
+// GetByID returns a user by ID. It reads from the primary, since callers
+// authorize with the role and cache it: a replica behind a promotion would
+// hand out the old one.
+func (r *SQLUserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+// GetByGuestID returns a user by guest ID
+func (r *SQLUserRepository) GetByGuestID(ctx context.Context, guestID string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("guest_id = ?", guestID).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+// List returns all users ordered by creation time, reading from a replica when available
+func (r *SQLUserRepository) List(ctx context.Context) ([]models.User, error) {
+	var users []models.User
+	if err := r.reader().WithContext(ctx).Order("created_at, id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// UpdateRole changes a user's role
+func (r *SQLUserRepository) UpdateRole(ctx context.Context, id string, role models.UserRole) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Count returns the number of users matching the filter
+func (r *SQLUserRepository) Count(ctx context.Context, filter UserFilter) (int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.User{})
+	if filter.Role != nil {
+		query = query.Where("role = ?", *filter.Role)
+	}
+	if filter.IsGuest != nil {
+		query = query.Where("is_guest = ?", *filter.IsGuest)
+	}
+
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+// SQLRevocationRepository is a RevocationRepository backed by GORM (Postgres or SQLite)
+type SQLRevocationRepository struct {
+	db *gorm.DB
+}
+
+// NewSQLRevocationRepository creates a revocation repository using the given database
+func NewSQLRevocationRepository(db *gorm.DB) *SQLRevocationRepository {
+	return &SQLRevocationRepository{db: db}
+}
+
+// Revoke records one or more revocations atomically
+func (r *SQLRevocationRepository) Revoke(ctx context.Context, entries ...models.TokenBlacklist) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// Store UTC so SQLite, which compares timestamps as text, orders them correctly
+	for i := range entries {
+		entries[i].ExpiresAt = entries[i].ExpiresAt.UTC()
+	}
+	return translateError(r.db.WithContext(ctx).Create(&entries).Error)
+}
+
+// IsRevoked reports whether a token ID has been revoked
+func (r *SQLRevocationRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.TokenBlacklist{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// IsFamilyRevoked reports whether a token family has been revoked
+func (r *SQLRevocationRepository) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.TokenBlacklist{}).Where("family_id = ?", familyID).Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteExpired removes revocations that expired before now
+func (r *SQLRevocationRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at < ?", now.UTC()).Delete(&models.TokenBlacklist{})
+	return result.RowsAffected, result.Error
+}
+
+// SQLRoomRepository is a RoomRepository backed by GORM (Postgres or SQLite)
+type SQLRoomRepository struct {
+	db *gorm.DB
+}
+
+// NewSQLRoomRepository creates a room repository using the given database
+func NewSQLRoomRepository(db *gorm.DB) *SQLRoomRepository {
+	return &SQLRoomRepository{db: db}
+}
+
+// Create inserts a room with its invitations in one transaction
+func (r *SQLRoomRepository) Create(ctx context.Context, room *models.Room) error {
+	return translateError(r.db.WithContext(ctx).Create(room).Error)
+}
+
+// Close marks a room inactive
+func (r *SQLRoomRepository) Close(ctx context.Context, id string) error {
+	result := r.db.WithContext(ctx).Model(&models.Room{}).
+		Where("id = ? AND is_active = ?", id, true).
+		Updates(map[string]interface{}{"is_active": false, "closed_at": time.Now().UTC()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Invite allows users to join a room
+func (r *SQLRoomRepository) Invite(ctx context.Context, roomID string, userIDs ...string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Room{}).Where("id = ?", roomID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrNotFound
+		}
+		if len(userIDs) == 0 {
+			return nil
+		}
+
+		invitations := make([]models.RoomInvitation, len(userIDs))
+		for i, userID := range userIDs {
+			invitations[i] = models.RoomInvitation{RoomID: roomID, UserID: userID}
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&invitations).Error
+	})
+}
+
+// ListActive returns the active rooms with their invitations
+func (r *SQLRoomRepository) ListActive(ctx context.Context) ([]models.Room, error) {
+	var rooms []models.Room
+	err := r.db.WithContext(ctx).Preload("Invitations").
+		Where("is_active = ?", true).Order("created_at, id").Find(&rooms).Error
+	if err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// SQLMessageRepository is a MessageRepository backed by GORM (Postgres or SQLite)
+type SQLMessageRepository struct {
+	db *gorm.DB
+}
+
+// NewSQLMessageRepository creates a chat history repository using the given database
+func NewSQLMessageRepository(db *gorm.DB) *SQLMessageRepository {
+	return &SQLMessageRepository{db: db}
+}
+
+// Append stores a message
+func (r *SQLMessageRepository) Append(ctx context.Context, message *models.ChatMessage) error {
+	return r.db.WithContext(ctx).Create(message).Error
+}
+
+// ListBefore returns up to limit of a room's messages with an ID below before, oldest first
+func (r *SQLMessageRepository) ListBefore(ctx context.Context, roomID string, before int64, limit int) ([]models.ChatMessage, error) {
+	query := r.db.WithContext(ctx).Where("room_id = ?", roomID)
+	if before > 0 {
+		query = query.Where("id < ?", before)
+	}
+
+	// Take the newest page, then put it back in chronological order
+	var messages []models.ChatMessage
+	if err := query.Order("id DESC").Limit(limit).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+// SQLDirectMessageRepository is a DirectMessageRepository backed by GORM (Postgres or SQLite)
+type SQLDirectMessageRepository struct {
+	db *gorm.DB
+}
+
+// NewSQLDirectMessageRepository creates a direct message repository using the given database
+func NewSQLDirectMessageRepository(db *gorm.DB) *SQLDirectMessageRepository {
+	return &SQLDirectMessageRepository{db: db}
+}
+
+// Create stores a message
+func (r *SQLDirectMessageRepository) Create(ctx context.Context, message *models.DirectMessage) error {
+	return r.db.WithContext(ctx).Create(message).Error
+}
+
+// Undelivered returns up to limit undelivered messages to a user, oldest first
+func (r *SQLDirectMessageRepository) Undelivered(ctx context.Context, recipientID string, limit int) ([]models.DirectMessage, error) {
+	var messages []models.DirectMessage
+	err := r.db.WithContext(ctx).
+		Where("recipient_id = ? AND delivered_at IS NULL", recipientID).
+		Order("id").Limit(limit).Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// MarkDelivered records that messages reached their recipient
+func (r *SQLDirectMessageRepository) MarkDelivered(ctx context.Context, recipientID string, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Model(&models.DirectMessage{}).
+		Where("recipient_id = ? AND id IN ? AND delivered_at IS NULL", recipientID, ids).
+		Update("delivered_at", time.Now().UTC()).Error
+}
+
+// MarkRead marks a sender's unread messages up to upTo as read
+func (r *SQLDirectMessageRepository) MarkRead(ctx context.Context, recipientID, senderID string, upTo int64) (int64, error) {
+	now := time.Now().UTC()
+	result := r.db.WithContext(ctx).Model(&models.DirectMessage{}).
+		Where("recipient_id = ? AND sender_id = ? AND id <= ? AND read_at IS NULL", recipientID, senderID, upTo).
+		Updates(map[string]interface{}{
+			"read_at":      now,
+			"delivered_at": gorm.Expr("COALESCE(delivered_at, ?)", now),
+		})
+	return result.RowsAffected, result.Error
+}
+
+// Conversation returns up to limit messages exchanged by two users, oldest first
+func (r *SQLDirectMessageRepository) Conversation(ctx context.Context, userID, otherID string, before int64, limit int) ([]models.DirectMessage, error) {
+	query := r.db.WithContext(ctx).Where(
+		"(sender_id = ? AND recipient_id = ?) OR (sender_id = ? AND recipient_id = ?)",
+		userID, otherID, otherID, userID)
+	if before > 0 {
+		query = query.Where("id < ?", before)
+	}
+
+	// Take the newest page, then put it back in chronological order
+	var messages []models.DirectMessage
+	if err := query.Order("id DESC").Limit(limit).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+// SQLBlockRepository is a BlockRepository backed by GORM (Postgres or SQLite)
+type SQLBlockRepository struct {
+	db *gorm.DB
+}
+
+// NewSQLBlockRepository creates a block repository using the given database
+func NewSQLBlockRepository(db *gorm.DB) *SQLBlockRepository {
+	return &SQLBlockRepository{db: db}
+}
+
+// Block stops blockedID from messaging blockerID
+func (r *SQLBlockRepository) Block(ctx context.Context, blockerID, blockedID string) error {
+	block := models.UserBlock{BlockerID: blockerID, BlockedID: blockedID}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&block).Error
+}
+
+// Unblock lifts a block
+func (r *SQLBlockRepository) Unblock(ctx context.Context, blockerID, blockedID string) error {
+	return r.db.WithContext(ctx).
+		Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).
+		Delete(&models.UserBlock{}).Error
+}
+
+// IsBlocked reports whether blockerID blocks blockedID
+func (r *SQLBlockRepository) IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.UserBlock{}).
+		Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).Count(&count).Error
+	return count > 0, err
+}
+
+// ListBlocked returns the users blockerID blocks, oldest block first
+func (r *SQLBlockRepository) ListBlocked(ctx context.Context, blockerID string) ([]models.UserBlock, error) {
+	var blocks []models.UserBlock
+	err := r.db.WithContext(ctx).Where("blocker_id = ?", blockerID).
+		Order("created_at, blocked_id").Find(&blocks).Error
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// translateError maps GORM errors to repository errors
+func translateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	default:
+		return err
+	}
+}
@@ -0,0 +1,183 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/migrations"
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+	"github.com/OkanUysal/go-starter-example-project/repository/repositorytest"
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// testDatabases returns the migrated databases the SQL repositories are tested against:
+// a fresh SQLite file always, and Postgres when TEST_DATABASE_URL is set
+func testDatabases(t *testing.T) map[string]*gorm.DB {
+	t.Helper()
+
+	databases := map[string]*gorm.DB{
+		"sqlite": openTestDB(t, sqlite.Open(filepath.Join(t.TempDir(), "test.db"))),
+	}
+	if dsn := os.Getenv("TEST_DATABASE_URL"); dsn != "" {
+		databases["postgres"] = openTestDB(t, postgres.Open(dsn))
+	}
+	return databases
+}
+
+// openTestDB connects and applies migrations
+func openTestDB(t *testing.T, dialector gorm.Dialector) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger:         gormlogger.Default.LogMode(gormlogger.Silent),
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	migrator, err := migrations.NewMigrator(db, logger.Default())
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	return db
+}
+
+// truncate empties a table so every contract test starts clean
+func truncate(t *testing.T, db *gorm.DB, table string) {
+	t.Helper()
+	if err := db.Exec("DELETE FROM " + table).Error; err != nil {
+		t.Fatalf("failed to empty %s: %v", table, err)
+	}
+}
+
+func TestSQLUserRepository(t *testing.T) {
+	for name, db := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			repositorytest.UserRepositoryContract(t, func(t *testing.T) repository.UserRepository {
+				truncate(t, db, models.GetUserTableName())
+				return repository.NewSQLUserRepository(db)
+			})
+		})
+	}
+}
+
+func TestSQLRevocationRepository(t *testing.T) {
+	for name, db := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			repositorytest.RevocationRepositoryContract(t, func(t *testing.T) repository.RevocationRepository {
+				truncate(t, db, models.TokenBlacklist{}.TableName())
+				return repository.NewSQLRevocationRepository(db)
+			})
+		})
+	}
+}
+
+func TestSQLRoomRepository(t *testing.T) {
+	for name, db := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			repositorytest.RoomRepositoryContract(t, func(t *testing.T) repository.RoomRepository {
+				truncate(t, db, models.RoomInvitation{}.TableName())
+				truncate(t, db, models.Room{}.TableName())
+				return repository.NewSQLRoomRepository(db)
+			})
+		})
+	}
+}
+
+func TestSQLMessageRepository(t *testing.T) {
+	for name, db := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			repositorytest.MessageRepositoryContract(t, func(t *testing.T) repository.MessageRepository {
+				truncate(t, db, models.ChatMessage{}.TableName())
+				return repository.NewSQLMessageRepository(db)
+			})
+		})
+	}
+}
+
+func TestSQLDirectMessageRepository(t *testing.T) {
+	for name, db := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			repositorytest.DirectMessageRepositoryContract(t, func(t *testing.T) repository.DirectMessageRepository {
+				truncate(t, db, models.DirectMessage{}.TableName())
+				return repository.NewSQLDirectMessageRepository(db)
+			})
+		})
+	}
+}
+
+func TestSQLBlockRepository(t *testing.T) {
+	for name, db := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			repositorytest.BlockRepositoryContract(t, func(t *testing.T) repository.BlockRepository {
+				truncate(t, db, models.UserBlock{}.TableName())
+				return repository.NewSQLBlockRepository(db)
+			})
+		})
+	}
+}
+
+func TestSQLUserRepositoryReadsFromReplica(t *testing.T) {
+	ctx := context.Background()
+	primary := openTestDB(t, sqlite.Open(filepath.Join(t.TempDir(), "primary.db")))
+	replica := openTestDB(t, sqlite.Open(filepath.Join(t.TempDir(), "replica.db")))
+	repo := repository.NewSQLUserRepository(primary, replica)
+
+	// Stand-ins for a user whose promotion hasn't replicated yet, and one
+	// that exists only on the replica
+	promoted := models.User{ID: uuid.New().String(), DisplayName: "Promoted", Role: models.RoleUser}
+	onReplica := models.User{ID: uuid.New().String(), DisplayName: "Replicated", Role: models.RoleUser}
+	if err := replica.Create([]models.User{promoted, onReplica}).Error; err != nil {
+		t.Fatalf("seed replica: %v", err)
+	}
+	if err := repo.Create(ctx, &promoted); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := repo.UpdateRole(ctx, promoted.ID, models.RoleAdmin); err != nil {
+		t.Fatalf("UpdateRole: %v", err)
+	}
+
+	// GetByID carries the role, so it reads the primary
+	user, err := repo.GetByID(ctx, promoted.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if user.Role != models.RoleAdmin {
+		t.Errorf("GetByID role = %s, want the primary's admin", user.Role)
+	}
+	if _, err := repo.GetByID(ctx, onReplica.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetByID of a replica-only user error = %v, want ErrNotFound", err)
+	}
+
+	users, err := repo.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(users) != 2 || users[0].Role != models.RoleUser || users[1].Role != models.RoleUser {
+		t.Errorf("List = %v, want the replica's two users", users)
+	}
+
+	// Writes always go to the primary
+	if err := repo.UpdateRole(ctx, onReplica.ID, models.RoleAdmin); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("UpdateRole on replica-only user error = %v, want ErrNotFound", err)
+	}
+}
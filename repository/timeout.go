@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/OkanUysal/go-starter-example-project/models"
+)
+
+// UsersWithTimeout bounds every call to users by timeout, on top of any deadline
+// the caller's context already has. A zero timeout returns users unchanged.
+func UsersWithTimeout(users UserRepository, timeout time.Duration) UserRepository {
+	if timeout <= 0 {
+		return users
+	}
+	return &timeoutUserRepository{next: users, timeout: timeout}
+}
+
+// RevocationsWithTimeout bounds calls to revocations by timeout, except
+// DeleteExpired: cleanup runs from the CLI and may legitimately take longer.
+// A zero timeout returns revocations unchanged.
+func RevocationsWithTimeout(revocations RevocationRepository, timeout time.Duration) RevocationRepository {
+	if timeout <= 0 {
+		return revocations
+	}
+	return &timeoutRevocationRepository{next: revocations, timeout: timeout}
+}
+
+// RoomsWithTimeout bounds every call to rooms by timeout. A zero timeout
+// returns rooms unchanged.
+func RoomsWithTimeout(rooms RoomRepository, timeout time.Duration) RoomRepository {
+	if timeout <= 0 {
+		return rooms
+	}
+	return &timeoutRoomRepository{next: rooms, timeout: timeout}
+}
+
+// MessagesWithTimeout bounds every call to messages by timeout. A zero timeout
+// returns messages unchanged.
+func MessagesWithTimeout(messages MessageRepository, timeout time.Duration) MessageRepository {
+	if timeout <= 0 {
+		return messages
+	}
+	return &timeoutMessageRepository{next: messages, timeout: timeout}
+}
+
+// DirectMessagesWithTimeout bounds every call to messages by timeout. A zero
+// timeout returns messages unchanged.
+func DirectMessagesWithTimeout(messages DirectMessageRepository, timeout time.Duration) DirectMessageRepository {
+	if timeout <= 0 {
+		return messages
+	}
+	return &timeoutDirectMessageRepository{next: messages, timeout: timeout}
+}
+
+// BlocksWithTimeout bounds every call to blocks by timeout. A zero timeout
+// returns blocks unchanged.
+func BlocksWithTimeout(blocks BlockRepository, timeout time.Duration) BlockRepository {
+	if timeout <= 0 {
+		return blocks
+	}
+	return &timeoutBlockRepository{next: blocks, timeout: timeout}
+}
+
+// timeoutUserRepository applies a per-call timeout to a UserRepository
+type timeoutUserRepository struct {
+	next    UserRepository
+	timeout time.Duration
+}
+
+func (r *timeoutUserRepository) Create(ctx context.Context, user *models.User) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.Create(ctx, user)
+}
+
+func (r *timeoutUserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.GetByID(ctx, id)
+}
+
+func (r *timeoutUserRepository) GetByGuestID(ctx context.Context, guestID string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.GetByGuestID(ctx, guestID)
+}
+
+func (r *timeoutUserRepository) List(ctx context.Context) ([]models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.List(ctx)
+}
+
+func (r *timeoutUserRepository) UpdateRole(ctx context.Context, id string, role models.UserRole) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.UpdateRole(ctx, id, role)
+}
+
+func (r *timeoutUserRepository) Count(ctx context.Context, filter UserFilter) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.Count(ctx, filter)
+}
+
+// timeoutRevocationRepository applies a per-call timeout to a RevocationRepository
+type timeoutRevocationRepository struct {
+	next    RevocationRepository
+	timeout time.Duration
+}
+
+func (r *timeoutRevocationRepository) Revoke(ctx context.Context, entries ...models.TokenBlacklist) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.Revoke(ctx, entries...)
+}
+
+func (r *timeoutRevocationRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.IsRevoked(ctx, jti)
+}
+
+func (r *timeoutRevocationRepository) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.IsFamilyRevoked(ctx, familyID)
+}
+
+func (r *timeoutRevocationRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return r.next.DeleteExpired(ctx, now)
+}
+
+// timeoutRoomRepository applies a per-call timeout to a RoomRepository
+type timeoutRoomRepository struct {
+	next    RoomRepository
+	timeout time.Duration
+}
+
+func (r *timeoutRoomRepository) Create(ctx context.Context, room *models.Room) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.Create(ctx, room)
+}
+
+func (r *timeoutRoomRepository) Close(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.Close(ctx, id)
+}
+
+func (r *timeoutRoomRepository) Invite(ctx context.Context, roomID string, userIDs ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.Invite(ctx, roomID, userIDs...)
+}
+
+func (r *timeoutRoomRepository) ListActive(ctx context.Context) ([]models.Room, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.ListActive(ctx)
+}
+
+// timeoutMessageRepository applies a per-call timeout to a MessageRepository
+type timeoutMessageRepository struct {
+	next    MessageRepository
+	timeout time.Duration
+}
+
+func (r *timeoutMessageRepository) Append(ctx context.Context, message *models.ChatMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.Append(ctx, message)
+}
+
+func (r *timeoutMessageRepository) ListBefore(ctx context.Context, roomID string, before int64, limit int) ([]models.ChatMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.ListBefore(ctx, roomID, before, limit)
+}
+
+// timeoutDirectMessageRepository applies a per-call timeout to a DirectMessageRepository
+type timeoutDirectMessageRepository struct {
+	next    DirectMessageRepository
+	timeout time.Duration
+}
+
+func (r *timeoutDirectMessageRepository) Create(ctx context.Context, message *models.DirectMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.Create(ctx, message)
+}
+
+func (r *timeoutDirectMessageRepository) Undelivered(ctx context.Context, recipientID string, limit int) ([]models.DirectMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.Undelivered(ctx, recipientID, limit)
+}
+
+func (r *timeoutDirectMessageRepository) MarkDelivered(ctx context.Context, recipientID string, ids ...int64) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.MarkDelivered(ctx, recipientID, ids...)
+}
+
+func (r *timeoutDirectMessageRepository) MarkRead(ctx context.Context, recipientID, senderID string, upTo int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.MarkRead(ctx, recipientID, senderID, upTo)
+}
+
+func (r *timeoutDirectMessageRepository) Conversation(ctx context.Context, userID, otherID string, before int64, limit int) ([]models.DirectMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.Conversation(ctx, userID, otherID, before, limit)
+}
+
+// timeoutBlockRepository applies a per-call timeout to a BlockRepository
+type timeoutBlockRepository struct {
+	next    BlockRepository
+	timeout time.Duration
+}
+
+func (r *timeoutBlockRepository) Block(ctx context.Context, blockerID, blockedID string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.Block(ctx, blockerID, blockedID)
+}
+
+func (r *timeoutBlockRepository) Unblock(ctx context.Context, blockerID, blockedID string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.Unblock(ctx, blockerID, blockedID)
+}
+
+func (r *timeoutBlockRepository) IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.IsBlocked(ctx, blockerID, blockedID)
+}
+
+func (r *timeoutBlockRepository) ListBlocked(ctx context.Context, blockerID string) ([]models.UserBlock, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.next.ListBlocked(ctx, blockerID)
+}
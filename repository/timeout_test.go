@@ -0,0 +1,64 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+	"github.com/glebarez/sqlite"
+)
+
+// deadlineRevocations records whether each call's context had a deadline
+type deadlineRevocations struct {
+	repository.RevocationRepository
+	deadlines map[string]bool
+}
+
+func (r *deadlineRevocations) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	_, r.deadlines["IsRevoked"] = ctx.Deadline()
+	return r.RevocationRepository.IsRevoked(ctx, jti)
+}
+
+func (r *deadlineRevocations) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	_, r.deadlines["DeleteExpired"] = ctx.Deadline()
+	return r.RevocationRepository.DeleteExpired(ctx, now)
+}
+
+func TestRevocationsWithTimeout(t *testing.T) {
+	ctx := context.Background()
+	inner := &deadlineRevocations{
+		RevocationRepository: repository.NewMemoryRevocationRepository(),
+		deadlines:            map[string]bool{},
+	}
+
+	if got := repository.RevocationsWithTimeout(inner, 0); got != inner {
+		t.Error("RevocationsWithTimeout(0) wrapped the repository, want it unchanged")
+	}
+
+	revocations := repository.RevocationsWithTimeout(inner, time.Second)
+	if _, err := revocations.IsRevoked(ctx, "jti"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := revocations.DeleteExpired(ctx, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if !inner.deadlines["IsRevoked"] {
+		t.Error("IsRevoked ran without a deadline")
+	}
+	if inner.deadlines["DeleteExpired"] {
+		t.Error("DeleteExpired ran with a deadline, want none")
+	}
+}
+
+func TestUsersWithTimeoutKeepsCallerCancellation(t *testing.T) {
+	users := repository.UsersWithTimeout(repository.NewSQLUserRepository(openTestDB(t, sqlite.Open(filepath.Join(t.TempDir(), "test.db")))), time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := users.Create(ctx, &models.User{ID: "u1", DisplayName: "Guest"}); err == nil {
+		t.Error("Create with a cancelled context succeeded, want an error")
+	}
+}
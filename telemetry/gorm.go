@@ -0,0 +1,177 @@
+// Package telemetry holds the service's metrics and tracing instrumentation
+package telemetry
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// gormInstrumentationName identifies the DB spans' tracer
+const gormInstrumentationName = "github.com/OkanUysal/go-starter-example-project/telemetry/gorm"
+
+// Instance keys used to pass state from the before to the after callback
+const (
+	startKey = "telemetry:start"
+	spanKey  = "telemetry:span"
+)
+
+// dbQueryBuckets suits queries, which are mostly much faster than HTTP requests
+var dbQueryBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+// GormMetrics holds the database query collectors shared by every connection pool
+type GormMetrics struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	rows     *prometheus.CounterVec
+}
+
+// NewGormMetrics creates the query collectors and registers them (e.g. on the /metrics registry)
+func NewGormMetrics(registry prometheus.Registerer) (*GormMetrics, error) {
+	labels := []string{"db", "table", "operation"}
+
+	m := &GormMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "Database query duration in seconds by table and operation",
+			Buckets: dbQueryBuckets,
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_query_errors_total",
+			Help: "Failed database queries by table and operation (record not found is not an error)",
+		}, labels),
+		rows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_rows_affected_total",
+			Help: "Rows returned or affected by database queries by table and operation",
+		}, labels),
+	}
+
+	for _, collector := range []prometheus.Collector{m.duration, m.errors, m.rows} {
+		if err := registry.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Plugin returns a GORM plugin recording into these collectors with db as the
+// "db" label (e.g. "primary" or "replica_1"). Spans are created only when
+// tracerProvider is non-nil; on a nil *GormMetrics only spans are recorded.
+func (m *GormMetrics) Plugin(db string, tracerProvider trace.TracerProvider) gorm.Plugin {
+	plugin := &gormPlugin{metrics: m, db: db}
+	if tracerProvider != nil {
+		plugin.tracer = tracerProvider.Tracer(gormInstrumentationName)
+	}
+	return plugin
+}
+
+// gormPlugin instruments one *gorm.DB through callbacks
+type gormPlugin struct {
+	metrics *GormMetrics
+	db      string
+	tracer  trace.Tracer
+}
+
+// Name implements gorm.Plugin
+func (p *gormPlugin) Name() string {
+	return "telemetry"
+}
+
+// registerFunc is the Register method of a GORM callback position
+type registerFunc func(name string, fn func(*gorm.DB)) error
+
+// Initialize implements gorm.Plugin by wrapping each kind of statement in before/after callbacks
+func (p *gormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+
+	hooks := []struct {
+		operation string
+		before    registerFunc
+		after     registerFunc
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+
+	for _, hook := range hooks {
+		if err := hook.before("telemetry:before_"+hook.operation, p.before(hook.operation)); err != nil {
+			return err
+		}
+		if err := hook.after("telemetry:after_"+hook.operation, p.after(hook.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// before records the start time and opens a span as a child of the statement's context
+func (p *gormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		db.InstanceSet(startKey, time.Now())
+
+		if p.tracer == nil || db.Statement.Context == nil {
+			return
+		}
+		// The statement context is left untouched: in a transaction it is shared by
+		// later statements, which would otherwise nest under this span
+		_, span := p.tracer.Start(db.Statement.Context, "db."+operation,
+			trace.WithSpanKind(trace.SpanKindClient))
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+// after records duration, errors and rows, and ends the span
+func (p *gormPlugin) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		// The table is only known once GORM has parsed the statement
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		labels := prometheus.Labels{"db": p.db, "table": table, "operation": operation}
+
+		// A missing record is an expected outcome, not a failure
+		failed := db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound)
+
+		if p.metrics != nil {
+			if start, ok := db.InstanceGet(startKey); ok {
+				p.metrics.duration.With(labels).Observe(time.Since(start.(time.Time)).Seconds())
+			}
+			if failed {
+				p.metrics.errors.With(labels).Inc()
+			}
+			if db.Statement.RowsAffected > 0 {
+				p.metrics.rows.With(labels).Add(float64(db.Statement.RowsAffected))
+			}
+		}
+
+		value, ok := db.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		span.SetName("db." + operation + " " + table)
+		span.SetAttributes(
+			attribute.String("db.system", db.Dialector.Name()),
+			attribute.String("db.name", p.db),
+			attribute.String("db.sql.table", table),
+			attribute.String("db.operation", operation),
+			attribute.String("db.statement", db.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+		)
+		if failed {
+			span.RecordError(db.Error)
+			span.SetStatus(codes.Error, db.Error.Error())
+		}
+		span.End()
+	}
+}
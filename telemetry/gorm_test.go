@@ -0,0 +1,182 @@
+package telemetry_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+	"github.com/glebarez/sqlite"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type widget struct {
+	ID   int `gorm:"primaryKey"`
+	Name string
+}
+
+// openTestDB opens a SQLite database with plugin installed and a widgets table
+func openTestDB(t *testing.T, plugin gorm.Plugin) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "telemetry.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := db.Use(plugin); err != nil {
+		t.Fatalf("install plugin: %v", err)
+	}
+	if err := db.Exec("CREATE TABLE widgets (id INTEGER PRIMARY KEY, name TEXT NOT NULL)").Error; err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	return db
+}
+
+// openInstrumentedDB opens a test database recording metrics into a fresh registry
+func openInstrumentedDB(t *testing.T) (*gorm.DB, *prometheus.Registry) {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	dbMetrics, err := telemetry.NewGormMetrics(registry)
+	if err != nil {
+		t.Fatalf("NewGormMetrics: %v", err)
+	}
+	return openTestDB(t, dbMetrics.Plugin("primary", nil)), registry
+}
+
+// series returns the primary database's series of a metric for one table and operation
+func series(t *testing.T, registry *prometheus.Registry, name, table, operation string) *dto.Metric {
+	t.Helper()
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	want := map[string]string{"db": "primary", "table": table, "operation": operation}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	next:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if want[label.GetName()] != label.GetValue() {
+					continue next
+				}
+			}
+			return metric
+		}
+	}
+	t.Fatalf("no %s series for %s %s", name, operation, table)
+	return nil
+}
+
+func TestGormMetricsRecordsQueries(t *testing.T) {
+	db, registry := openInstrumentedDB(t)
+
+	if err := db.Create(&[]widget{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}).Error; err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	var found []widget
+	if err := db.Find(&found).Error; err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if err := db.Model(&widget{}).Where("id = ?", 1).Update("name", "c").Error; err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	for _, operation := range []string{"create", "query", "update"} {
+		histogram := series(t, registry, "db_query_duration_seconds", "widgets", operation).GetHistogram()
+		if got := histogram.GetSampleCount(); got != 1 {
+			t.Errorf("%s observations = %d, want 1", operation, got)
+		}
+	}
+
+	if got := series(t, registry, "db_rows_affected_total", "widgets", "create").GetCounter().GetValue(); got != 2 {
+		t.Errorf("created rows = %v, want 2", got)
+	}
+	if got := series(t, registry, "db_rows_affected_total", "widgets", "query").GetCounter().GetValue(); got != 2 {
+		t.Errorf("queried rows = %v, want 2", got)
+	}
+}
+
+func TestGormMetricsCountsErrors(t *testing.T) {
+	db, registry := openInstrumentedDB(t)
+
+	// A missing record is expected and not counted
+	var missing widget
+	if err := db.First(&missing, 42).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("First = %v, want ErrRecordNotFound", err)
+	}
+	if got := testutil.CollectAndCount(registry, "db_query_errors_total"); got != 0 {
+		t.Fatalf("error series after not found = %d, want 0", got)
+	}
+
+	// A duplicate primary key is counted
+	if err := db.Create(&widget{ID: 1, Name: "a"}).Error; err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := db.Create(&widget{ID: 1, Name: "a"}).Error; err == nil {
+		t.Fatal("duplicate create succeeded")
+	}
+
+	expected := `
+# HELP db_query_errors_total Failed database queries by table and operation (record not found is not an error)
+# TYPE db_query_errors_total counter
+db_query_errors_total{db="primary",operation="create",table="widgets"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "db_query_errors_total"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGormPluginRecordsSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	// Tracing works without metrics
+	var dbMetrics *telemetry.GormMetrics
+	db := openTestDB(t, dbMetrics.Plugin("primary", provider))
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	if err := db.WithContext(ctx).Create(&widget{ID: 1, Name: "a"}).Error; err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	db.WithContext(ctx).Create(&widget{ID: 1, Name: "a"})
+	parent.End()
+
+	var creates []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "db.create widgets" {
+			creates = append(creates, span)
+		}
+	}
+	if len(creates) != 2 {
+		t.Fatalf("got %d create spans, want 2", len(creates))
+	}
+	for _, span := range creates {
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("create span is not a child of the request span")
+		}
+	}
+	if creates[0].Status().Code == codes.Error || creates[1].Status().Code != codes.Error {
+		t.Errorf("span statuses = %v, %v, want ok then error", creates[0].Status(), creates[1].Status())
+	}
+}
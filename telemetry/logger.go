@@ -0,0 +1,29 @@
+package telemetry
+
+import (
+	"context"
+
+	"github.com/OkanUysal/go-logger"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Logger returns log with the request ID and the trace and span IDs found in
+// ctx, so every line can be matched to its request and trace. With none of them
+// in ctx it returns log unchanged.
+func Logger(ctx context.Context, log *logger.Logger) *logger.Logger {
+	var fields []logger.Field
+	if id := RequestIDFromContext(ctx); id != "" {
+		fields = append(fields, logger.String("request_id", id))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		fields = append(fields,
+			logger.String("trace_id", spanContext.TraceID().String()),
+			logger.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+
+	if len(fields) == 0 {
+		return log
+	}
+	return log.With(fields...)
+}
@@ -0,0 +1,34 @@
+package telemetry_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestLoggerAddsCorrelationIDs(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.New(logger.DefaultConfig().WithWriter(&buf).WithFormat(logger.FormatJSON))
+
+	// Without a request ID or span the logger is returned as is
+	if got := telemetry.Logger(context.Background(), log); got != log {
+		t.Error("Logger without correlation IDs returned a different logger")
+	}
+
+	ctx := telemetry.WithRequestID(context.Background(), "req-123")
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(ctx, "operation")
+	defer span.End()
+
+	telemetry.Logger(ctx, log).Info("correlated")
+	line := buf.String()
+	for _, want := range []string{"req-123", span.SpanContext().TraceID().String(), span.SpanContext().SpanID().String()} {
+		if !strings.Contains(line, want) {
+			t.Errorf("log line %q does not contain %s", line, want)
+		}
+	}
+}
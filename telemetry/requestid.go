@@ -0,0 +1,134 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds caller-supplied IDs, which end up in every log line
+const maxRequestIDLength = 128
+
+// requestIDKey stores the request ID in a context.Context
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "" if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns id if it is a usable request ID, or a freshly generated one.
+// Only short IDs of letters, digits and -_.: are accepted, so callers can't inject
+// arbitrary text into logs.
+func NewRequestID(id string) string {
+	if id == "" || len(id) > maxRequestIDLength {
+		return uuid.NewString()
+	}
+	for _, r := range id {
+		if !isRequestIDRune(r) {
+			return uuid.NewString()
+		}
+	}
+	return id
+}
+
+// isRequestIDRune reports whether r may appear in a caller-supplied request ID
+func isRequestIDRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+		strings.ContainsRune("-_.:", r)
+}
+
+// RequestID accepts the caller's X-Request-ID or generates one, stores it in the
+// request context and echoes it in the response header. JSON error bodies also
+// get a "request_id" field, so a failed call can be matched to its log lines.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := NewRequestID(c.GetHeader(RequestIDHeader))
+
+		ctx := WithRequestID(c.Request.Context(), id)
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(RequestIDHeader, id)
+
+		// Link the request span (if tracing is enabled) to the ID
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("http.request_id", id))
+
+		writer := &requestIDWriter{ResponseWriter: c.Writer, requestID: id}
+		c.Writer = writer
+		c.Next()
+		writer.flush()
+	}
+}
+
+// requestIDWriter holds back error responses so the request ID can be added to
+// their JSON body; successful responses are written straight through
+type requestIDWriter struct {
+	gin.ResponseWriter
+	requestID string
+	status    int
+	body      *bytes.Buffer
+}
+
+// WriteHeader records the status; error bodies written after it are buffered
+func (w *requestIDWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write buffers error response bodies and passes everything else through
+func (w *requestIDWriter) Write(data []byte) (int, error) {
+	if !w.buffering() {
+		return w.ResponseWriter.Write(data)
+	}
+	if w.body == nil {
+		w.body = &bytes.Buffer{}
+	}
+	return w.body.Write(data)
+}
+
+// WriteString buffers like Write
+func (w *requestIDWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// buffering reports whether the response is a JSON error not yet sent
+func (w *requestIDWriter) buffering() bool {
+	if w.status < http.StatusBadRequest || w.ResponseWriter.Written() {
+		return false
+	}
+	return strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+}
+
+// flush writes a buffered error body, adding the request ID if it is a JSON object
+func (w *requestIDWriter) flush() {
+	if w.body == nil {
+		return
+	}
+	body := w.body.Bytes()
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err == nil {
+		if _, exists := object["request_id"]; !exists {
+			object["request_id"], _ = json.Marshal(w.requestID)
+			if encoded, err := json.Marshal(object); err == nil {
+				body = encoded
+			}
+		}
+	}
+	w.ResponseWriter.Write(body)
+}
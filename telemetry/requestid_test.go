@@ -0,0 +1,81 @@
+package telemetry_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+	"github.com/gin-gonic/gin"
+)
+
+// serveRequestID routes one request through the middleware and returns the response
+func serveRequestID(t *testing.T, requestID string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(telemetry.RequestID())
+	r.GET("/", handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if requestID != "" {
+		req.Header.Set(telemetry.RequestIDHeader, requestID)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequestIDIsAcceptedOrGenerated(t *testing.T) {
+	var seen string
+	handler := func(c *gin.Context) {
+		seen = telemetry.RequestIDFromContext(c.Request.Context())
+		c.String(http.StatusOK, "ok")
+	}
+
+	rec := serveRequestID(t, "client-id.1:a_b", handler)
+	if got := rec.Header().Get(telemetry.RequestIDHeader); got != "client-id.1:a_b" || seen != got {
+		t.Errorf("caller's ID: header = %q, context = %q", got, seen)
+	}
+
+	for _, supplied := range []string{"", "bad id\nwith newline", strings.Repeat("a", 129)} {
+		rec := serveRequestID(t, supplied, handler)
+		got := rec.Header().Get(telemetry.RequestIDHeader)
+		if got == "" || got == supplied || seen != got {
+			t.Errorf("supplied %q: header = %q, context = %q, want a generated ID", supplied, got, seen)
+		}
+	}
+}
+
+func TestRequestIDInErrorBodies(t *testing.T) {
+	rec := serveRequestID(t, "req-1", func(c *gin.Context) {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": gin.H{"message": "denied"}})
+	})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", rec.Code)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body %q: %v", rec.Body.String(), err)
+	}
+	if body["request_id"] != "req-1" || body["success"] != false {
+		t.Errorf("error body = %v, want the original fields plus request_id", body)
+	}
+
+	// Successful and non-JSON responses are left alone
+	rec = serveRequestID(t, "req-2", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+	if strings.Contains(rec.Body.String(), "request_id") {
+		t.Errorf("success body = %s, want no request_id", rec.Body.String())
+	}
+	rec = serveRequestID(t, "req-3", func(c *gin.Context) {
+		c.String(http.StatusNotFound, "not found")
+	})
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "not found" {
+		t.Errorf("plain error = %d %q, want it unchanged", rec.Code, rec.Body.String())
+	}
+}
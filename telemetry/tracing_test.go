@@ -0,0 +1,43 @@
+package telemetry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestNewTracerProvider(t *testing.T) {
+	ctx := context.Background()
+
+	for _, exporter := range []string{"", telemetry.ExporterNone} {
+		provider, err := telemetry.NewTracerProvider(ctx, exporter, "test")
+		if err != nil || provider != nil {
+			t.Errorf("NewTracerProvider(%q) = %v, %v, want nil, nil", exporter, provider, err)
+		}
+	}
+
+	provider, err := telemetry.NewTracerProvider(ctx, telemetry.ExporterStdout, "test")
+	if err != nil || provider == nil {
+		t.Fatalf("NewTracerProvider(stdout) = %v, %v", provider, err)
+	}
+	provider.Shutdown(ctx)
+
+	if _, err := telemetry.NewTracerProvider(ctx, "zipkin", "test"); err == nil {
+		t.Error("NewTracerProvider(zipkin) succeeded, want an error")
+	}
+}
+
+func TestPropagatorReadsTraceContext(t *testing.T) {
+	carrier := propagation.MapCarrier{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+
+	ctx := telemetry.Propagator().Extract(context.Background(), carrier)
+	_, span := sdktrace.NewTracerProvider().Tracer("test").Start(ctx, "child")
+	defer span.End()
+
+	if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("child trace ID = %s, want the propagated one", got)
+	}
+}
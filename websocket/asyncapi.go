@@ -0,0 +1,275 @@
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// protocolMessage describes one message type of the WebSocket protocol
+type protocolMessage struct {
+	Type    MessageType
+	Summary string
+	// Payload is the type of the message's data
+	Payload interface{}
+}
+
+// clientMessages are the messages clients send, with the requests they carry
+var clientMessages = []protocolMessage{
+	{MessageTypeJoin, "Join a room", JoinRequest{}},
+	{MessageTypeChat, "Send a chat message to a room you are in", ChatRequest{}},
+	{MessageTypeDirect, "Send a direct message to another user", DirectRequest{}},
+	{MessageTypeDirectRead, "Mark another user's direct messages read, up to message_id", DirectReadRequest{}},
+	{MessageTypePresence, "Set your status on this connection", PresenceRequest{}},
+	{MessageTypeTypingStart, "Start typing in a room, or to another user", TypingRequest{}},
+	{MessageTypeTypingStop, "Stop typing in a room, or to another user", TypingRequest{}},
+	{MessageTypeAck, "Acknowledge a room's messages up to seq", AckRequest{}},
+	{MessageTypeResume, "Ask for the room messages missed since last_seq", ResumeRequest{}},
+	{MessageTypeCreateRoom, "Create a game room (admin only)", NewRoomRequest{}},
+	{MessageTypeCloseRoom, "Close a game room (admin only)", CloseRoomRequest{}},
+}
+
+// serverMessages are the messages the server sends. Events about rooms and
+// users carry an eventData; replies to a request have their own type.
+var serverMessages = []protocolMessage{
+	{MessageTypeConnected, "The connection's ID, once it has joined its room", ConnectedReply{}},
+	{MessageTypeJoin, "A user joined a room", eventData{}},
+	{MessageTypeLeave, "A user left a room", eventData{}},
+	{MessageTypeChat, "A chat message, with its message_id", eventData{}},
+	{MessageTypeGameEvent, "A game-specific event", eventData{}},
+	{MessageTypeRoomCreated, "A game room was created", eventData{}},
+	{MessageTypeRoomClosed, "A room was closed", eventData{}},
+	{MessageTypeInvite, "You were invited to a room", eventData{}},
+	{MessageTypeServerShutdown, "The server is stopping; reconnect to another instance", eventData{}},
+	{MessageTypeKicked, "This connection is closing: you connected from another device", KickedReply{}},
+	{MessageTypeHistory, "A room's recent chat messages, on joining it", eventData{}},
+	{MessageTypeDirect, "A direct message, with its message_id, to and sent_at", eventData{}},
+	{MessageTypeDirectRead, "The recipient read your direct messages up to message_id", eventData{}},
+	{MessageTypePresence, "A user's overall status changed", eventData{}},
+	{MessageTypeTypingStart, "A user started typing in a room, or to you", eventData{}},
+	{MessageTypeTypingStop, "A user stopped typing", eventData{}},
+	{MessageTypeDirectSent, "Your direct message was stored", DirectSentReply{}},
+	{MessageTypeResumed, "Every missed room message was replayed", ResumedReply{}},
+	{MessageTypeSnapshot, "Too many room messages were missed to replay: the room's current state", SnapshotReply{}},
+	{MessageTypeError, "A message was rejected", ErrorReply{}},
+}
+
+// eventData are the fields of the events the server sends about rooms and
+// users. Each event fills those that apply and may add its own.
+type eventData struct {
+	RoomID    string    `json:"room_id,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	Content   string    `json:"content,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp,omitempty"`
+}
+
+// AsyncAPI returns the AsyncAPI description of the WebSocket protocol, as
+// indented JSON. docs/asyncapi.json is generated from it.
+func AsyncAPI() ([]byte, error) {
+	schemas := map[string]interface{}{}
+	messages := map[string]interface{}{}
+
+	refs := func(direction string, catalog []protocolMessage) []interface{} {
+		list := make([]interface{}, 0, len(catalog))
+		for _, msg := range catalog {
+			name := direction + "." + string(msg.Type)
+			messages[name] = map[string]interface{}{
+				"name":    string(msg.Type),
+				"summary": msg.Summary,
+				"payload": envelopeSchema(msg, schemas),
+			}
+			list = append(list, map[string]interface{}{"$ref": "#/components/messages/" + name})
+		}
+		return list
+	}
+
+	versions := make([]interface{}, len(supportedProtocolVersions))
+	for i, version := range supportedProtocolVersions {
+		versions[i] = version
+	}
+	formatNames := make([]interface{}, len(formats))
+	for i, format := range formats {
+		formatNames[i] = string(format)
+	}
+
+	doc := map[string]interface{}{
+		"asyncapi": "2.6.0",
+		"info": map[string]interface{}{
+			"title":   "Go Starter Example Project WebSocket API",
+			"version": strconv.Itoa(ProtocolVersion),
+			"description": "Real-time rooms, chat, direct messages and presence. Every frame is an envelope " +
+				"{\"type\": ..., \"data\": {...}}; room broadcasts also carry room_id and seq. " +
+				"Pick a protocol version with the protocol query parameter or the subprotocol " + subprotocolPrefix + "{version}. " +
+				"Envelopes are JSON text frames, or MessagePack binary frames with the format query parameter msgpack " +
+				"or the subprotocol " + subprotocolPrefix + "{version}.msgpack. " +
+				"A user may connect from several devices at once, unless the server is set to close the oldest connection " +
+				"or refuse the new one.",
+		},
+		"servers": map[string]interface{}{
+			"local": map[string]interface{}{
+				"url":      "localhost:8080",
+				"protocol": "ws",
+				"security": []interface{}{map[string]interface{}{"BearerAuth": []interface{}{}}},
+			},
+		},
+		"defaultContentType": "application/json",
+		"channels": map[string]interface{}{
+			"/api/ws": map[string]interface{}{
+				"bindings": map[string]interface{}{
+					"ws": map[string]interface{}{
+						"method": "GET",
+						"query": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"room_id":  map[string]interface{}{"type": "string", "description": "Room to join on connecting (default: lobby)"},
+								"token":    map[string]interface{}{"type": "string", "description": "JWT access token, instead of the Authorization header"},
+								"protocol": map[string]interface{}{"type": "integer", "enum": versions, "description": "Protocol version (default: the latest)"},
+								"format":   map[string]interface{}{"type": "string", "enum": formatNames, "description": "Wire format (default: json)"},
+							},
+						},
+					},
+				},
+				"publish": map[string]interface{}{
+					"summary": "Messages clients send",
+					"message": map[string]interface{}{"oneOf": refs("client", clientMessages)},
+				},
+				"subscribe": map[string]interface{}{
+					"summary": "Messages the server sends",
+					"message": map[string]interface{}{"oneOf": refs("server", serverMessages)},
+				},
+			},
+		},
+		"components": map[string]interface{}{
+			"messages": messages,
+			"schemas":  schemas,
+			"securitySchemes": map[string]interface{}{
+				"BearerAuth": map[string]interface{}{
+					"type":        "httpApiKey",
+					"in":          "header",
+					"name":        "Authorization",
+					"description": `"Bearer" followed by a space and the JWT access token`,
+				},
+			},
+		},
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// envelopeSchema describes the frame a message is sent in
+func envelopeSchema(msg protocolMessage, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{
+		"type": map[string]interface{}{"type": "string", "const": string(msg.Type)},
+		"data": typeSchema(reflect.TypeOf(msg.Payload), schemas),
+	}
+	if _, event := msg.Payload.(eventData); event {
+		properties["room_id"] = map[string]interface{}{"type": "string", "description": "Set on room broadcasts"}
+		properties["seq"] = map[string]interface{}{"type": "integer", "description": "Position in the room's sequence, on room broadcasts other than typing"}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   []interface{}{"type"},
+	}
+}
+
+// typeSchema describes a Go type as a JSON schema. Named structs are added to
+// schemas and referred to.
+func typeSchema(typ reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch typ {
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case reflect.TypeOf(ErrorCode("")):
+		codes := make([]interface{}, len(errorCodes))
+		for i, code := range errorCodes {
+			codes[i] = string(code)
+		}
+		return map[string]interface{}{"type": "string", "enum": codes}
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return typeSchema(typ.Elem(), schemas)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(typ.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(typ.Elem(), schemas)}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Struct:
+		// Unexported types are documented under their exported name
+		name := strings.ToUpper(typ.Name()[:1]) + typ.Name()[1:]
+		if _, done := schemas[name]; !done {
+			schemas[name] = map[string]interface{}{} // Placeholder, in case the type refers to itself
+			schemas[name] = structSchema(typ, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema describes a struct's JSON fields, with the rules of their binding tags
+func structSchema(typ reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []interface{}{}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := jsonName(field)
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+
+		schema := typeSchema(field.Type, schemas)
+		for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+			rule, param, _ := strings.Cut(rule, "=")
+			switch rule {
+			case "required":
+				required = append(required, name)
+			case "max":
+				schema["maxLength"], _ = strconv.Atoi(param)
+			case "gt":
+				schema["exclusiveMinimum"], _ = strconv.Atoi(param)
+			case "gte":
+				schema["minimum"], _ = strconv.Atoi(param)
+			case "oneof":
+				var values []interface{}
+				for _, value := range strings.Fields(param) {
+					values = append(values, value)
+				}
+				schema["enum"] = values
+			}
+		}
+		properties[name] = schema
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if typ == reflect.TypeOf(eventData{}) {
+		schema["additionalProperties"] = true
+	}
+	return schema
+}
@@ -0,0 +1,388 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cluster event kinds
+const (
+	// eventBroadcast delivers Message to the members of RoomID
+	eventBroadcast = "broadcast"
+
+	// eventSend delivers Message to UserID
+	eventSend = "send"
+
+	// eventRoomSaved creates or updates Room (e.g. new invitations)
+	eventRoomSaved = "room_saved"
+
+	// eventRoomClosed closes RoomID
+	eventRoomClosed = "room_closed"
+
+	// eventDirect delivers direct message MessageID (as Message) to UserID
+	// and records the delivery
+	eventDirect = "direct"
+)
+
+// ErrRoomFull is returned by Backplane.AddMember when a room has no place left
+var ErrRoomFull = errors.New("room is full")
+
+// ClusterEvent is what nodes publish to each other through the backplane
+type ClusterEvent struct {
+	Origin    string    `json:"origin"` // node ID of the publisher, which ignores its own events
+	Kind      string    `json:"kind"`
+	RoomID    string    `json:"room_id,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	MessageID int64     `json:"message_id,omitempty"`
+	Message   *Envelope `json:"message,omitempty"`
+	Room      *RoomInfo `json:"room,omitempty"`
+}
+
+// Backplane links the room managers of every replica: it carries events between
+// nodes, stores the game rooms, and tracks room members cluster-wide so player
+// counts and room limits don't depend on which node a client hit.
+type Backplane interface {
+	// Subscribe registers a node and calls handler for every published event,
+	// its own included, until unsubscribe is called. A node's members only
+	// count while it is subscribed.
+	Subscribe(nodeID string, handler func(*ClusterEvent)) (unsubscribe func(), err error)
+
+	// Publish sends an event to every subscribed node
+	Publish(ctx context.Context, event *ClusterEvent) error
+
+	// SaveRoom stores a game room, replacing any earlier version
+	SaveRoom(ctx context.Context, room *RoomInfo) error
+
+	// DeleteRoom removes a room, its members, sequence and acks
+	DeleteRoom(ctx context.Context, roomID string) error
+
+	// Rooms returns every stored room
+	Rooms(ctx context.Context) ([]*RoomInfo, error)
+
+	// AddMember records that user is in a room through nodeID, and reports
+	// whether that joined them to it: false if they already are in it through
+	// another live node. It returns ErrRoomFull if the room already has limit
+	// users on live nodes (0: no limit). Checking and adding is one atomic
+	// step, so concurrent joins can't overfill a room. A member always fits again.
+	AddMember(ctx context.Context, roomID, nodeID string, user *UserInfo, limit int) (bool, error)
+
+	// RemoveMember records that a user is no longer in a room through nodeID,
+	// and reports whether that made them leave it: true unless they are still
+	// in it through another live node
+	RemoveMember(ctx context.Context, roomID, nodeID, userID string) (bool, error)
+
+	// Members returns the users in a room on every live node, each once, oldest join first
+	Members(ctx context.Context, roomID string) ([]*UserInfo, error)
+
+	// SetPresence records a user's status on their connection to nodeID
+	SetPresence(ctx context.Context, userID, nodeID string, status PresenceStatus) error
+
+	// RemovePresence records that a user's connection to nodeID closed, and when
+	RemovePresence(ctx context.Context, userID, nodeID string) error
+
+	// Presence returns each user's status across every live node, in the order given
+	Presence(ctx context.Context, userIDs ...string) ([]Presence, error)
+
+	// NextSequence returns the next number in a room's message sequence, starting at 1
+	NextSequence(ctx context.Context, roomID string) (int64, error)
+
+	// Sequence returns the last number NextSequence handed out for a room (0: none)
+	Sequence(ctx context.Context, roomID string) (int64, error)
+
+	// SaveAck records that a user received a room's messages up to seq; an
+	// older ack than the one recorded is ignored
+	SaveAck(ctx context.Context, roomID, userID string, seq int64) error
+
+	// Ack returns the last sequence number a user acknowledged in a room (0: none)
+	Ack(ctx context.Context, roomID, userID string) (int64, error)
+
+	// Ping checks the connection to the backplane
+	Ping(ctx context.Context) error
+}
+
+// MemoryBackplane is a Backplane for room managers in one process: the default
+// for a single node, and a stand-in for Redis when testing several nodes.
+type MemoryBackplane struct {
+	mu          sync.RWMutex
+	subscribers map[int]func(*ClusterEvent)
+	nodes       map[int]string // subscription -> node ID
+	nextID      int
+	rooms       map[string]*RoomInfo
+	members     map[string]map[string]map[string]*UserInfo // room ID -> user ID -> node ID -> user
+	presence    map[string]map[string]PresenceStatus       // user ID -> node ID -> status
+	lastSeen    map[string]time.Time
+	sequences   map[string]int64
+	acks        map[string]map[string]int64 // room ID -> user ID -> sequence number
+}
+
+// NewMemoryBackplane creates an empty in-process backplane
+func NewMemoryBackplane() *MemoryBackplane {
+	return &MemoryBackplane{
+		subscribers: make(map[int]func(*ClusterEvent)),
+		nodes:       make(map[int]string),
+		rooms:       make(map[string]*RoomInfo),
+		members:     make(map[string]map[string]map[string]*UserInfo),
+		presence:    make(map[string]map[string]PresenceStatus),
+		lastSeen:    make(map[string]time.Time),
+		sequences:   make(map[string]int64),
+		acks:        make(map[string]map[string]int64),
+	}
+}
+
+// Subscribe implements Backplane. Unsubscribing removes the node's members
+// and connections, as a stopped node's would stop counting in Redis.
+func (b *MemoryBackplane) Subscribe(nodeID string, handler func(*ClusterEvent)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = handler
+	b.nodes[id] = nodeID
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+		delete(b.nodes, id)
+		b.removeNode(nodeID)
+	}, nil
+}
+
+// removeNode drops a node from every room and user's connections; the caller holds b.mu
+func (b *MemoryBackplane) removeNode(nodeID string) {
+	for _, members := range b.members {
+		for userID, nodes := range members {
+			delete(nodes, nodeID)
+			if len(nodes) == 0 {
+				delete(members, userID)
+			}
+		}
+	}
+	now := time.Now()
+	for userID, nodes := range b.presence {
+		if _, connected := nodes[nodeID]; !connected {
+			continue
+		}
+		delete(nodes, nodeID)
+		if len(nodes) == 0 {
+			delete(b.presence, userID)
+		}
+		b.lastSeen[userID] = now
+	}
+}
+
+// Publish implements Backplane. Handlers run synchronously, outside the lock.
+func (b *MemoryBackplane) Publish(ctx context.Context, event *ClusterEvent) error {
+	b.mu.RLock()
+	handlers := make([]func(*ClusterEvent), 0, len(b.subscribers))
+	for _, handler := range b.subscribers {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+	return nil
+}
+
+// SaveRoom implements Backplane
+func (b *MemoryBackplane) SaveRoom(ctx context.Context, room *RoomInfo) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rooms[room.ID] = room.metadata()
+	return nil
+}
+
+// DeleteRoom implements Backplane
+func (b *MemoryBackplane) DeleteRoom(ctx context.Context, roomID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.rooms, roomID)
+	delete(b.members, roomID)
+	delete(b.sequences, roomID)
+	delete(b.acks, roomID)
+	return nil
+}
+
+// Rooms implements Backplane
+func (b *MemoryBackplane) Rooms(ctx context.Context) ([]*RoomInfo, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	rooms := make([]*RoomInfo, 0, len(b.rooms))
+	for _, room := range b.rooms {
+		rooms = append(rooms, room.metadata())
+	}
+	return rooms, nil
+}
+
+// AddMember implements Backplane
+func (b *MemoryBackplane) AddMember(ctx context.Context, roomID, nodeID string, user *UserInfo, limit int) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	members := b.members[roomID]
+	nodes, member := members[user.UserID]
+	if !member && limit > 0 && len(members) >= limit {
+		return false, ErrRoomFull
+	}
+	if members == nil {
+		members = make(map[string]map[string]*UserInfo)
+		b.members[roomID] = members
+	}
+	if nodes == nil {
+		nodes = make(map[string]*UserInfo)
+		members[user.UserID] = nodes
+	}
+	added := *user
+	nodes[nodeID] = &added
+	return !member, nil
+}
+
+// RemoveMember implements Backplane
+func (b *MemoryBackplane) RemoveMember(ctx context.Context, roomID, nodeID, userID string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	nodes := b.members[roomID][userID]
+	delete(nodes, nodeID)
+	if len(nodes) > 0 {
+		return false, nil
+	}
+	delete(b.members[roomID], userID)
+	return true, nil
+}
+
+// Members implements Backplane
+func (b *MemoryBackplane) Members(ctx context.Context, roomID string) ([]*UserInfo, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	members := make([]*UserInfo, 0, len(b.members[roomID]))
+	for _, nodes := range b.members[roomID] {
+		var first *UserInfo
+		for _, user := range nodes {
+			if first == nil || user.JoinedAt.Before(first.JoinedAt) {
+				first = user
+			}
+		}
+		member := *first
+		members = append(members, &member)
+	}
+	sortMembers(members)
+	return members, nil
+}
+
+// SetPresence implements Backplane
+func (b *MemoryBackplane) SetPresence(ctx context.Context, userID, nodeID string, status PresenceStatus) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.presence[userID] == nil {
+		b.presence[userID] = make(map[string]PresenceStatus)
+	}
+	b.presence[userID][nodeID] = status
+	return nil
+}
+
+// RemovePresence implements Backplane
+func (b *MemoryBackplane) RemovePresence(ctx context.Context, userID, nodeID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.presence[userID], nodeID)
+	if len(b.presence[userID]) == 0 {
+		delete(b.presence, userID)
+	}
+	b.lastSeen[userID] = time.Now()
+	return nil
+}
+
+// Presence implements Backplane
+func (b *MemoryBackplane) Presence(ctx context.Context, userIDs ...string) ([]Presence, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	presence := make([]Presence, len(userIDs))
+	for i, userID := range userIDs {
+		statuses := make([]PresenceStatus, 0, len(b.presence[userID]))
+		for _, status := range b.presence[userID] {
+			statuses = append(statuses, status)
+		}
+		var lastSeen *time.Time
+		if seen, ok := b.lastSeen[userID]; ok {
+			lastSeen = &seen
+		}
+		presence[i] = combinePresence(userID, statuses, lastSeen)
+	}
+	return presence, nil
+}
+
+// NextSequence implements Backplane
+func (b *MemoryBackplane) NextSequence(ctx context.Context, roomID string) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sequences[roomID]++
+	return b.sequences[roomID], nil
+}
+
+// Sequence implements Backplane
+func (b *MemoryBackplane) Sequence(ctx context.Context, roomID string) (int64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.sequences[roomID], nil
+}
+
+// SaveAck implements Backplane
+func (b *MemoryBackplane) SaveAck(ctx context.Context, roomID, userID string, seq int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.acks[roomID] == nil {
+		b.acks[roomID] = make(map[string]int64)
+	}
+	if seq > b.acks[roomID][userID] {
+		b.acks[roomID][userID] = seq
+	}
+	return nil
+}
+
+// Ack implements Backplane
+func (b *MemoryBackplane) Ack(ctx context.Context, roomID, userID string) (int64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.acks[roomID][userID], nil
+}
+
+// Ping implements Backplane
+func (b *MemoryBackplane) Ping(ctx context.Context) error {
+	return nil
+}
+
+// combinePresence merges a user's status on each of their connections: online
+// if any connection is, away if all are, and offline without one
+func combinePresence(userID string, statuses []PresenceStatus, lastSeen *time.Time) Presence {
+	presence := Presence{UserID: userID, Status: PresenceOffline}
+	for _, status := range statuses {
+		if status == PresenceOnline {
+			return Presence{UserID: userID, Status: PresenceOnline}
+		}
+		presence.Status = PresenceAway
+	}
+	if presence.Status == PresenceOffline {
+		presence.LastSeen = lastSeen
+	}
+	return presence
+}
+
+// sortMembers orders members by join time
+func sortMembers(members []*UserInfo) {
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].JoinedAt.Before(members[j].JoinedAt)
+	})
+}
@@ -0,0 +1,495 @@
+package websocket_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/websocket"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// eventTimeout bounds how long a test waits for a published event
+const eventTimeout = 2 * time.Second
+
+// newRedisBackplane returns a backplane on a fresh in-process Redis server
+func newRedisBackplane(t *testing.T) *websocket.RedisBackplane {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return websocket.NewRedisBackplane(client, logger.New(logger.DefaultConfig().WithWriter(io.Discard)))
+}
+
+func TestMemoryBackplane(t *testing.T) {
+	backplaneContract(t, func(t *testing.T) websocket.Backplane {
+		return websocket.NewMemoryBackplane()
+	})
+}
+
+func TestRedisBackplane(t *testing.T) {
+	backplaneContract(t, func(t *testing.T) websocket.Backplane {
+		return newRedisBackplane(t)
+	})
+}
+
+// backplaneContract checks the behaviour every Backplane must provide
+func backplaneContract(t *testing.T, newBackplane func(t *testing.T) websocket.Backplane) {
+	ctx := context.Background()
+
+	t.Run("rooms", func(t *testing.T) {
+		b := newBackplane(t)
+
+		room := &websocket.RoomInfo{
+			ID:           "room-1",
+			Type:         websocket.RoomTypeGame,
+			Name:         "Duel",
+			MaxPlayers:   2,
+			IsActive:     true,
+			Users:        map[string]*websocket.UserInfo{"u1": {UserID: "u1"}},
+			AllowedUsers: map[string]bool{"u1": true},
+		}
+		if err := b.SaveRoom(ctx, room); err != nil {
+			t.Fatalf("SaveRoom: %v", err)
+		}
+		room.AllowedUsers["u2"] = true
+		if err := b.SaveRoom(ctx, room); err != nil {
+			t.Fatalf("SaveRoom again: %v", err)
+		}
+
+		rooms, err := b.Rooms(ctx)
+		if err != nil {
+			t.Fatalf("Rooms: %v", err)
+		}
+		if len(rooms) != 1 {
+			t.Fatalf("Rooms returned %d rooms, want 1", len(rooms))
+		}
+		got := rooms[0]
+		if got.ID != "room-1" || got.Name != "Duel" || got.MaxPlayers != 2 || !got.IsActive {
+			t.Errorf("stored room = %+v", got)
+		}
+		if !got.AllowedUsers["u1"] || !got.AllowedUsers["u2"] {
+			t.Errorf("stored invitations = %v, want u1 and u2", got.AllowedUsers)
+		}
+		if len(got.Users) != 0 {
+			t.Errorf("stored room has members %v, want none (members are tracked separately)", got.Users)
+		}
+
+		if err := b.DeleteRoom(ctx, "room-1"); err != nil {
+			t.Fatalf("DeleteRoom: %v", err)
+		}
+		if rooms, _ := b.Rooms(ctx); len(rooms) != 0 {
+			t.Errorf("Rooms after delete returned %d rooms, want 0", len(rooms))
+		}
+	})
+
+	t.Run("members", func(t *testing.T) {
+		b := newBackplane(t)
+		unsubscribe, err := b.Subscribe("node-1", func(*websocket.ClusterEvent) {})
+		if err != nil {
+			t.Fatalf("Subscribe: %v", err)
+		}
+		defer unsubscribe()
+
+		joined := time.Now().Truncate(time.Second)
+		for i, userID := range []string{"u2", "u1"} {
+			user := &websocket.UserInfo{UserID: userID, Username: "User " + userID, JoinedAt: joined.Add(time.Duration(i) * time.Second)}
+			if _, err := b.AddMember(ctx, "room-1", "node-1", user, 0); err != nil {
+				t.Fatalf("AddMember(%s): %v", userID, err)
+			}
+		}
+
+		members, err := b.Members(ctx, "room-1")
+		if err != nil {
+			t.Fatalf("Members: %v", err)
+		}
+		if len(members) != 2 || members[0].UserID != "u2" || members[1].UserID != "u1" {
+			t.Fatalf("Members = %v, want u2 then u1", members)
+		}
+		if members[0].Username != "User u2" {
+			t.Errorf("member username = %q, want %q", members[0].Username, "User u2")
+		}
+
+		if left, err := b.RemoveMember(ctx, "room-1", "node-1", "u2"); err != nil || !left {
+			t.Fatalf("RemoveMember = %v, %v, want left", left, err)
+		}
+		if members, _ := b.Members(ctx, "room-1"); len(members) != 1 || members[0].UserID != "u1" {
+			t.Errorf("Members after remove = %v, want u1", members)
+		}
+
+		// Deleting a room removes its members
+		if err := b.DeleteRoom(ctx, "room-1"); err != nil {
+			t.Fatalf("DeleteRoom: %v", err)
+		}
+		if members, _ := b.Members(ctx, "room-1"); len(members) != 0 {
+			t.Errorf("Members of a deleted room = %v, want none", members)
+		}
+	})
+
+	t.Run("member limit", func(t *testing.T) {
+		b := newBackplane(t)
+		unsubscribe, err := b.Subscribe("node-1", func(*websocket.ClusterEvent) {})
+		if err != nil {
+			t.Fatalf("Subscribe: %v", err)
+		}
+		defer unsubscribe()
+
+		// However many join at once, only limit of them get in
+		var added atomic.Int32
+		var wg sync.WaitGroup
+		for i := range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				user := &websocket.UserInfo{UserID: fmt.Sprintf("u%d", i)}
+				joined, err := b.AddMember(ctx, "room-1", "node-1", user, 2)
+				if err != nil && !errors.Is(err, websocket.ErrRoomFull) {
+					t.Errorf("AddMember(%s): %v", user.UserID, err)
+				}
+				if joined {
+					added.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		members, err := b.Members(ctx, "room-1")
+		if err != nil {
+			t.Fatalf("Members: %v", err)
+		}
+		if added.Load() != 2 || len(members) != 2 {
+			t.Fatalf("%d of 10 concurrent joins added with %d members, want 2", added.Load(), len(members))
+		}
+
+		// A member fits again, and a freed place can be taken
+		if joined, err := b.AddMember(ctx, "room-1", "node-1", members[0], 2); err != nil || joined {
+			t.Errorf("AddMember of a member = %v, %v, want added without joining", joined, err)
+		}
+		if _, err := b.RemoveMember(ctx, "room-1", "node-1", members[1].UserID); err != nil {
+			t.Fatalf("RemoveMember: %v", err)
+		}
+		if joined, err := b.AddMember(ctx, "room-1", "node-1", &websocket.UserInfo{UserID: "late"}, 2); err != nil || !joined {
+			t.Errorf("AddMember after a member left = %v, %v, want joined", joined, err)
+		}
+	})
+
+	t.Run("members on several nodes", func(t *testing.T) {
+		b := newBackplane(t)
+		for _, nodeID := range []string{"node-1", "node-2"} {
+			unsubscribe, err := b.Subscribe(nodeID, func(*websocket.ClusterEvent) {})
+			if err != nil {
+				t.Fatalf("Subscribe: %v", err)
+			}
+			defer unsubscribe()
+		}
+
+		// A user in the room through two nodes joins it once and takes one place
+		user := &websocket.UserInfo{UserID: "u1", JoinedAt: time.Now().Truncate(time.Second)}
+		if joined, err := b.AddMember(ctx, "room-1", "node-1", user, 1); err != nil || !joined {
+			t.Fatalf("AddMember on node-1 = %v, %v, want joined", joined, err)
+		}
+		later := &websocket.UserInfo{UserID: "u1", JoinedAt: user.JoinedAt.Add(time.Second)}
+		if joined, err := b.AddMember(ctx, "room-1", "node-2", later, 1); err != nil || joined {
+			t.Fatalf("AddMember on node-2 = %v, %v, want added without joining", joined, err)
+		}
+		members, err := b.Members(ctx, "room-1")
+		if err != nil {
+			t.Fatalf("Members: %v", err)
+		}
+		if len(members) != 1 || !members[0].JoinedAt.Equal(user.JoinedAt) {
+			t.Errorf("Members = %v, want u1 once, from the first join", members)
+		}
+
+		// They only leave with their last node
+		if left, err := b.RemoveMember(ctx, "room-1", "node-1", "u1"); err != nil || left {
+			t.Errorf("RemoveMember on node-1 = %v, %v, want still in the room", left, err)
+		}
+		if members, _ := b.Members(ctx, "room-1"); len(members) != 1 {
+			t.Errorf("Members after leaving node-1 = %v, want u1", members)
+		}
+		if left, err := b.RemoveMember(ctx, "room-1", "node-2", "u1"); err != nil || !left {
+			t.Errorf("RemoveMember on node-2 = %v, %v, want left", left, err)
+		}
+		if members, _ := b.Members(ctx, "room-1"); len(members) != 0 {
+			t.Errorf("Members after leaving node-2 = %v, want none", members)
+		}
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		b := newBackplane(t)
+		unsubscribe1, err := b.Subscribe("node-1", func(*websocket.ClusterEvent) {})
+		if err != nil {
+			t.Fatalf("Subscribe(node-1): %v", err)
+		}
+		defer unsubscribe1()
+		unsubscribe2, err := b.Subscribe("node-2", func(*websocket.ClusterEvent) {})
+		if err != nil {
+			t.Fatalf("Subscribe(node-2): %v", err)
+		}
+
+		b.AddMember(ctx, "room-1", "node-1", &websocket.UserInfo{UserID: "u1"}, 0)
+		b.AddMember(ctx, "room-1", "node-2", &websocket.UserInfo{UserID: "u2"}, 0)
+		b.SetPresence(ctx, "u1", "node-1", websocket.PresenceOnline)
+		b.SetPresence(ctx, "u2", "node-2", websocket.PresenceOnline)
+
+		// A node that stops takes its members and connections with it
+		unsubscribe2()
+		if members, err := b.Members(ctx, "room-1"); err != nil || len(members) != 1 || members[0].UserID != "u1" {
+			t.Errorf("Members after node-2 stopped = %v, %v, want only u1", members, err)
+		}
+		presence, err := b.Presence(ctx, "u1", "u2")
+		if err != nil {
+			t.Fatalf("Presence: %v", err)
+		}
+		if presence[0].Status != websocket.PresenceOnline || presence[1].Status != websocket.PresenceOffline {
+			t.Errorf("Presence after node-2 stopped = %+v, want u1 online and u2 offline", presence)
+		}
+		if presence[1].LastSeen == nil {
+			t.Error("user of a stopped node has no last seen time")
+		}
+
+		// Their place in the room is free again
+		if joined, err := b.AddMember(ctx, "room-1", "node-1", &websocket.UserInfo{UserID: "u3"}, 2); err != nil || !joined {
+			t.Errorf("AddMember after node-2 stopped = %v, %v, want joined", joined, err)
+		}
+	})
+
+	t.Run("presence", func(t *testing.T) {
+		b := newBackplane(t)
+		for _, nodeID := range []string{"node-1", "node-2"} {
+			unsubscribe, err := b.Subscribe(nodeID, func(*websocket.ClusterEvent) {})
+			if err != nil {
+				t.Fatalf("Subscribe(%s): %v", nodeID, err)
+			}
+			defer unsubscribe()
+		}
+
+		assertPresence := func(userID string, want websocket.PresenceStatus) websocket.Presence {
+			t.Helper()
+			presence, err := b.Presence(ctx, userID)
+			if err != nil {
+				t.Fatalf("Presence: %v", err)
+			}
+			if len(presence) != 1 || presence[0].UserID != userID || presence[0].Status != want {
+				t.Fatalf("Presence(%s) = %+v, want %s", userID, presence, want)
+			}
+			return presence[0]
+		}
+
+		if got := assertPresence("u1", websocket.PresenceOffline); got.LastSeen != nil {
+			t.Errorf("never connected user was last seen at %v", got.LastSeen)
+		}
+
+		// Online on any connection wins over away
+		b.SetPresence(ctx, "u1", "node-1", websocket.PresenceAway)
+		assertPresence("u1", websocket.PresenceAway)
+		b.SetPresence(ctx, "u1", "node-2", websocket.PresenceOnline)
+		assertPresence("u1", websocket.PresenceOnline)
+
+		if err := b.RemovePresence(ctx, "u1", "node-2"); err != nil {
+			t.Fatalf("RemovePresence: %v", err)
+		}
+		assertPresence("u1", websocket.PresenceAway)
+		b.RemovePresence(ctx, "u1", "node-1")
+		if got := assertPresence("u1", websocket.PresenceOffline); got.LastSeen == nil {
+			t.Error("disconnected user has no last seen time")
+		}
+
+		// Several users at once, in the order asked
+		b.SetPresence(ctx, "u2", "node-1", websocket.PresenceOnline)
+		presence, err := b.Presence(ctx, "u2", "u3", "u1")
+		if err != nil {
+			t.Fatalf("Presence: %v", err)
+		}
+		if len(presence) != 3 || presence[0].Status != websocket.PresenceOnline ||
+			presence[1].Status != websocket.PresenceOffline || presence[2].UserID != "u1" {
+			t.Errorf("Presence(u2, u3, u1) = %+v", presence)
+		}
+	})
+
+	t.Run("sequences and acks", func(t *testing.T) {
+		b := newBackplane(t)
+
+		if seq, err := b.Sequence(ctx, "room-1"); err != nil || seq != 0 {
+			t.Fatalf("Sequence of a new room = %d, %v; want 0", seq, err)
+		}
+		for want := int64(1); want <= 3; want++ {
+			seq, err := b.NextSequence(ctx, "room-1")
+			if err != nil {
+				t.Fatalf("NextSequence: %v", err)
+			}
+			if seq != want {
+				t.Errorf("NextSequence = %d, want %d", seq, want)
+			}
+		}
+		if seq, _ := b.NextSequence(ctx, "room-2"); seq != 1 {
+			t.Errorf("another room's first sequence number = %d, want 1", seq)
+		}
+		if seq, _ := b.Sequence(ctx, "room-1"); seq != 3 {
+			t.Errorf("Sequence = %d, want 3", seq)
+		}
+
+		// Acks only move forward
+		for _, seq := range []int64{2, 1} {
+			if err := b.SaveAck(ctx, "room-1", "u1", seq); err != nil {
+				t.Fatalf("SaveAck(%d): %v", seq, err)
+			}
+		}
+		if seq, err := b.Ack(ctx, "room-1", "u1"); err != nil || seq != 2 {
+			t.Errorf("Ack = %d, %v; want 2", seq, err)
+		}
+		if seq, _ := b.Ack(ctx, "room-1", "u2"); seq != 0 {
+			t.Errorf("Ack of a user who never acked = %d, want 0", seq)
+		}
+
+		// Deleting a room starts its sequence over
+		if err := b.DeleteRoom(ctx, "room-1"); err != nil {
+			t.Fatalf("DeleteRoom: %v", err)
+		}
+		if seq, _ := b.Sequence(ctx, "room-1"); seq != 0 {
+			t.Errorf("Sequence of a deleted room = %d, want 0", seq)
+		}
+		if seq, _ := b.Ack(ctx, "room-1", "u1"); seq != 0 {
+			t.Errorf("Ack in a deleted room = %d, want 0", seq)
+		}
+	})
+
+	t.Run("events", func(t *testing.T) {
+		b := newBackplane(t)
+
+		received := map[string]chan *websocket.ClusterEvent{
+			"node-1": make(chan *websocket.ClusterEvent, 10),
+			"node-2": make(chan *websocket.ClusterEvent, 10),
+		}
+		unsubscribes := map[string]func(){}
+		for nodeID, events := range received {
+			unsubscribe, err := b.Subscribe(nodeID, func(event *websocket.ClusterEvent) { events <- event })
+			if err != nil {
+				t.Fatalf("Subscribe(%s): %v", nodeID, err)
+			}
+			unsubscribes[nodeID] = unsubscribe
+		}
+
+		event := &websocket.ClusterEvent{
+			Origin:  "node-1",
+			Kind:    "broadcast",
+			RoomID:  "lobby",
+			Message: &websocket.Envelope{Type: "chat", Data: map[string]interface{}{"content": "hi"}},
+		}
+		if err := b.Publish(ctx, event); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+
+		// Every node gets it, the publisher included
+		for nodeID, events := range received {
+			select {
+			case got := <-events:
+				if got.Origin != "node-1" || got.RoomID != "lobby" || got.Message == nil || got.Message.Data["content"] != "hi" {
+					t.Errorf("%s received %+v", nodeID, got)
+				}
+			case <-time.After(eventTimeout):
+				t.Fatalf("%s did not receive the event", nodeID)
+			}
+		}
+
+		// An unsubscribed node gets nothing more
+		unsubscribes["node-2"]()
+		if err := b.Publish(ctx, event); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		select {
+		case <-received["node-1"]:
+		case <-time.After(eventTimeout):
+			t.Fatal("node-1 did not receive the second event")
+		}
+		select {
+		case got := <-received["node-2"]:
+			t.Errorf("unsubscribed node received %+v", got)
+		default:
+		}
+		unsubscribes["node-1"]()
+	})
+}
+
+func TestRedisBackplaneSkipsPresenceOfDeadNodes(t *testing.T) {
+	ctx := context.Background()
+	b := newRedisBackplane(t)
+
+	unsubscribe, err := b.Subscribe("alive", func(*websocket.ClusterEvent) {})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer unsubscribe()
+
+	b.SetPresence(ctx, "u1", "alive", websocket.PresenceAway)
+	b.SetPresence(ctx, "u1", "gone", websocket.PresenceOnline)
+	b.SetPresence(ctx, "u2", "gone", websocket.PresenceOnline)
+
+	presence, err := b.Presence(ctx, "u1", "u2")
+	if err != nil {
+		t.Fatalf("Presence: %v", err)
+	}
+	if presence[0].Status != websocket.PresenceAway || presence[1].Status != websocket.PresenceOffline {
+		t.Errorf("Presence = %+v, want u1 away and u2 offline", presence)
+	}
+}
+
+func TestRedisBackplaneSkipsMembersOfDeadNodes(t *testing.T) {
+	ctx := context.Background()
+	b := newRedisBackplane(t)
+
+	unsubscribe, err := b.Subscribe("alive", func(*websocket.ClusterEvent) {})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer unsubscribe()
+
+	// "gone" never subscribed, like a node that crashed and whose heartbeat expired
+	b.AddMember(ctx, "room-1", "alive", &websocket.UserInfo{UserID: "u1"}, 0)
+	b.AddMember(ctx, "room-1", "gone", &websocket.UserInfo{UserID: "u2"}, 0)
+
+	members, err := b.Members(ctx, "room-1")
+	if err != nil {
+		t.Fatalf("Members: %v", err)
+	}
+	if len(members) != 1 || members[0].UserID != "u1" {
+		t.Errorf("Members = %v, want only u1", members)
+	}
+}
+
+func TestRedisBackplaneExpiresMembersOfStalledNodes(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	b := websocket.NewRedisBackplane(client, logger.New(logger.DefaultConfig().WithWriter(io.Discard)))
+
+	unsubscribe, err := b.Subscribe("node-1", func(*websocket.ClusterEvent) {})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer unsubscribe()
+
+	server.SetTime(time.Now())
+	if joined, err := b.AddMember(ctx, "room-1", "node-1", &websocket.UserInfo{UserID: "u1"}, 1); err != nil || !joined {
+		t.Fatalf("AddMember = %v, %v, want joined", joined, err)
+	}
+
+	// Without heartbeats, like a node that hangs, its members stop counting
+	// and their places are free again
+	server.SetTime(time.Now().Add(time.Minute))
+	if members, err := b.Members(ctx, "room-1"); err != nil || len(members) != 0 {
+		t.Errorf("Members of a stalled node = %v, %v, want none", members, err)
+	}
+	if joined, err := b.AddMember(ctx, "room-1", "node-2", &websocket.UserInfo{UserID: "u2"}, 1); err != nil || !joined {
+		t.Errorf("AddMember after node-1 stalled = %v, %v, want joined", joined, err)
+	}
+}
@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ConnectionPolicy decides what happens when a user who already holds the
+// allowed number of connections opens another one, e.g. from a second device
+type ConnectionPolicy string
+
+const (
+	// ConnectionPolicyAllowMany lets a user connect from any number of devices at once (the default)
+	ConnectionPolicyAllowMany ConnectionPolicy = "allow_many"
+
+	// ConnectionPolicyKickOldest closes the user's oldest connection to make room for the new one
+	ConnectionPolicyKickOldest ConnectionPolicy = "kick_oldest"
+
+	// ConnectionPolicyRejectNew refuses the new connection, keeping the existing ones
+	ConnectionPolicyRejectNew ConnectionPolicy = "reject_new"
+)
+
+// connectionPolicies are the policies that may be configured
+var connectionPolicies = []ConnectionPolicy{
+	ConnectionPolicyAllowMany, ConnectionPolicyKickOldest, ConnectionPolicyRejectNew,
+}
+
+// defaultMaxConnections is how many connections per user kick_oldest and
+// reject_new allow when no limit is set: one, a single session
+const defaultMaxConnections = 1
+
+// errTooManyConnections is returned when reject_new refuses a connection
+var errTooManyConnections = errors.New("already connected from too many devices")
+
+// ParseConnectionPolicy returns the connection policy a setting names
+func ParseConnectionPolicy(name string) (ConnectionPolicy, error) {
+	for _, policy := range connectionPolicies {
+		if ConnectionPolicy(name) == policy {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown connection policy %q (use allow_many, kick_oldest or reject_new)", name)
+}
+
+// connections are one user's clients by connection ID
+type connections map[string]*Client
+
+// oldest returns the count connections opened first, oldest first
+func (c connections) oldest(count int) []*Client {
+	clients := make([]*Client, 0, len(c))
+	for _, client := range c {
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].connectedAt.Before(clients[j].connectedAt)
+	})
+	if count > len(clients) {
+		count = len(clients)
+	}
+	return clients[:count]
+}
+
+// SetConnectionPolicy sets what happens when a user holding max connections
+// (kick_oldest and reject_new; zero: 1) opens another one. Connections are
+// counted on this node.
+func (h *Hub) SetConnectionPolicy(policy ConnectionPolicy, max int) {
+	if max <= 0 {
+		max = defaultMaxConnections
+	}
+	h.policy = policy
+	h.maxConnections = max
+}
+
+// admit applies the connection policy to a user opening a connection. It
+// returns the connections to close to make room for it, or
+// errTooManyConnections if it is refused. The caller holds the user's
+// lifecycle lock, so their connections don't change meanwhile.
+func (h *Hub) admit(userID string) ([]*Client, error) {
+	if h.policy == "" || h.policy == ConnectionPolicyAllowMany {
+		return nil, nil
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	excess := len(h.clients[userID]) - h.maxConnections + 1
+	if excess <= 0 {
+		return nil, nil
+	}
+	if h.policy == ConnectionPolicyRejectNew {
+		return nil, errTooManyConnections
+	}
+	return h.clients[userID].oldest(excess), nil
+}
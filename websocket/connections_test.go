@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseConnectionPolicy(t *testing.T) {
+	for _, policy := range connectionPolicies {
+		if got, err := ParseConnectionPolicy(string(policy)); err != nil || got != policy {
+			t.Errorf("ParseConnectionPolicy(%s) = %s, %v", policy, got, err)
+		}
+	}
+	if _, err := ParseConnectionPolicy("kick_newest"); err == nil {
+		t.Error("ParseConnectionPolicy(kick_newest) succeeded")
+	}
+}
+
+func TestAdmitAppliesConnectionPolicy(t *testing.T) {
+	hub := NewHub(nil, 0)
+	start := time.Now()
+	phone := &Client{ID: "phone", UserID: "u1", connectedAt: start}
+	browser := &Client{ID: "browser", UserID: "u1", connectedAt: start.Add(time.Second)}
+	hub.clients["u1"] = connections{browser.ID: browser, phone.ID: phone}
+
+	// Without a policy, any number of connections is allowed
+	if replaced, err := hub.admit("u1"); err != nil || len(replaced) != 0 {
+		t.Errorf("admit without a policy = %v, %v", replaced, err)
+	}
+
+	hub.SetConnectionPolicy(ConnectionPolicyRejectNew, 2)
+	if _, err := hub.admit("u1"); !errors.Is(err, errTooManyConnections) {
+		t.Errorf("reject_new over the limit: %v, want errTooManyConnections", err)
+	}
+	if _, err := hub.admit("u2"); err != nil {
+		t.Errorf("reject_new for another user: %v", err)
+	}
+
+	// kick_oldest makes room by replacing the connections opened first
+	hub.SetConnectionPolicy(ConnectionPolicyKickOldest, 2)
+	if replaced, err := hub.admit("u1"); err != nil || len(replaced) != 1 || replaced[0] != phone {
+		t.Errorf("kick_oldest with 2 = %v, %v, want the phone replaced", replaced, err)
+	}
+	hub.SetConnectionPolicy(ConnectionPolicyKickOldest, 0)
+	if replaced, err := hub.admit("u1"); err != nil || len(replaced) != 2 || replaced[0] != phone || replaced[1] != browser {
+		t.Errorf("kick_oldest with the default limit = %v, %v, want both replaced, oldest first", replaced, err)
+	}
+}
+
+func TestRefusedConnectionIsToldAndClosed(t *testing.T) {
+	hub := NewHub(nil, 0)
+	hub.SetOnConnect(func(ctx context.Context, client *Client, info ConnectionInfo) error {
+		client.enqueue(reply(MessageTypeError, ErrorReply{Code: ErrorCodeRoomFull, Message: ErrRoomFull.Error()}))
+		return ErrRoomFull
+	})
+	disconnected := make(chan bool, 1)
+	hub.SetOnDisconnect(func(client *Client, roomIDs []string, last bool) {
+		disconnected <- last
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := hub.HandleConnection(w, r, ConnectionInfo{UserID: "u1", Format: FormatJSON}); err != nil {
+			t.Errorf("HandleConnection: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	// The connection is told why, then closed as a policy violation
+	var msg Envelope
+	if err := conn.ReadJSON(&msg); err != nil || msg.Type != string(MessageTypeError) || msg.Data["code"] != string(ErrorCodeRoomFull) {
+		t.Fatalf("first message = %+v, %v, want a room_full error", msg, err)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("connection closed with %v, want policy violation", err)
+	}
+	if last := <-disconnected; !last {
+		t.Error("refused connection wasn't the user's last")
+	}
+	if count := hub.ConnectionCount("u1"); count != 0 {
+		t.Errorf("connection count = %d, want 0", count)
+	}
+}
+
+func TestSlowConnectOnlyHoldsUpItsUser(t *testing.T) {
+	hub := NewHub(nil, 0)
+	release := make(chan struct{})
+	hub.SetOnConnect(func(ctx context.Context, client *Client, info ConnectionInfo) error {
+		if client.UserID == "slow" {
+			<-release
+		}
+		client.enqueue(reply(MessageTypeConnected, ConnectedReply{UserID: client.UserID}))
+		return nil
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := ConnectionInfo{UserID: r.URL.Query().Get("user"), Format: FormatJSON}
+		if err := hub.HandleConnection(w, r, info); err != nil {
+			t.Errorf("HandleConnection: %v", err)
+		}
+	}))
+	defer server.Close()
+	dial := func(userID string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?user="+userID, nil)
+		if err != nil {
+			t.Fatalf("failed to connect %s: %v", userID, err)
+		}
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		return conn
+	}
+
+	// Another user connects while the slow one's connect handler still runs
+	slow := dial("slow")
+	defer slow.Close()
+	fast := dial("fast")
+	var msg Envelope
+	if err := fast.ReadJSON(&msg); err != nil || msg.Type != string(MessageTypeConnected) {
+		t.Fatalf("fast user's first message = %+v, %v, want connected", msg, err)
+	}
+	fast.Close()
+	close(release)
+	if err := slow.ReadJSON(&msg); err != nil || msg.Type != string(MessageTypeConnected) {
+		t.Fatalf("slow user's first message = %+v, %v, want connected", msg, err)
+	}
+	slow.Close()
+
+	// Users' locks go once nobody holds them
+	locks := func() int {
+		hub.lifecycleMu.Lock()
+		defer hub.lifecycleMu.Unlock()
+		return len(hub.lifecycle)
+	}
+	for deadline := time.Now().Add(2 * time.Second); locks() > 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := locks(); n != 0 {
+		t.Errorf("lifecycle locks left = %d, want none", n)
+	}
+}
@@ -0,0 +1,179 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+)
+
+// pendingDirectPage is how many undelivered direct messages are loaded at a
+// time when a user connects
+const pendingDirectPage = 100
+
+// Errors returned for direct messages and blocks
+var (
+	errMessageSelf = errors.New("you can't message yourself")
+	errBlocked     = errors.New("this user is not accepting your messages")
+	errBlockSelf   = errors.New("you can't block yourself")
+)
+
+// SendDirect stores a direct message and delivers it to every connection of
+// its recipient, on any node. Recipients who are offline receive it when they
+// next connect. Messages to a user who blocks the sender are rejected.
+func (rm *RoomManager) SendDirect(ctx context.Context, senderID, recipientID, content string) (*models.DirectMessage, error) {
+	if senderID == recipientID {
+		return nil, errMessageSelf
+	}
+
+	blocked, err := rm.blocks.IsBlocked(ctx, recipientID, senderID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, errBlocked
+	}
+
+	message := &models.DirectMessage{
+		SenderID:    senderID,
+		RecipientID: recipientID,
+		Content:     content,
+	}
+	if err := rm.directMessages.Create(ctx, message); err != nil {
+		return nil, err
+	}
+
+	rm.stopTyping(ctx, typingKey{userID: senderID, to: recipientID})
+	msg := rm.directEnvelope(message)
+	rm.deliverDirect(ctx, recipientID, message.ID, msg)
+	rm.publish(ctx, &ClusterEvent{Kind: eventDirect, UserID: recipientID, MessageID: message.ID, Message: &msg})
+
+	telemetry.Logger(ctx, rm.logger).Info("Direct message sent",
+		logger.String("user_id", senderID),
+		logger.String("recipient_id", recipientID))
+
+	return message, nil
+}
+
+// MarkDirectRead marks the messages senderID sent to readerID, up to and
+// including messageID, as read, and tells the sender
+func (rm *RoomManager) MarkDirectRead(ctx context.Context, readerID, senderID string, messageID int64) error {
+	read, err := rm.directMessages.MarkRead(ctx, readerID, senderID, messageID)
+	if err != nil {
+		return err
+	}
+	if read == 0 {
+		return nil
+	}
+
+	rm.send(ctx, senderID, envelope(&Message{
+		Type:   MessageTypeDirectRead,
+		UserID: readerID,
+		Data: map[string]interface{}{
+			"message_id": messageID,
+			"read_count": read,
+		},
+	}))
+	return nil
+}
+
+// Conversation returns up to limit of the direct messages two users exchanged
+// with an ID below before (0: the latest), oldest first
+func (rm *RoomManager) Conversation(ctx context.Context, userID, otherID string, before int64, limit int) ([]models.DirectMessage, error) {
+	return rm.directMessages.Conversation(ctx, userID, otherID, before, limit)
+}
+
+// Block stops blockedID from sending direct messages to blockerID
+func (rm *RoomManager) Block(ctx context.Context, blockerID, blockedID string) error {
+	if blockerID == blockedID {
+		return errBlockSelf
+	}
+	return rm.blocks.Block(ctx, blockerID, blockedID)
+}
+
+// Unblock lets blockedID send direct messages to blockerID again
+func (rm *RoomManager) Unblock(ctx context.Context, blockerID, blockedID string) error {
+	return rm.blocks.Unblock(ctx, blockerID, blockedID)
+}
+
+// Blocked returns the users blockerID blocks, oldest block first
+func (rm *RoomManager) Blocked(ctx context.Context, blockerID string) ([]models.UserBlock, error) {
+	return rm.blocks.ListBlocked(ctx, blockerID)
+}
+
+// deliverPending sends a user who just connected to this node the direct
+// messages they received while offline, oldest first
+func (rm *RoomManager) deliverPending(ctx context.Context, userID string) {
+	for {
+		messages, err := rm.directMessages.Undelivered(ctx, userID, pendingDirectPage)
+		if err != nil {
+			telemetry.Logger(ctx, rm.logger).Error("Failed to load undelivered direct messages",
+				logger.Err(err),
+				logger.String("user_id", userID))
+			return
+		}
+
+		ids := make([]int64, 0, len(messages))
+		for i := range messages {
+			if !rm.hub.SendToUser(userID, rm.directEnvelope(&messages[i])) {
+				// Disconnected again; the rest waits for the next connection
+				break
+			}
+			ids = append(ids, messages[i].ID)
+		}
+		if !rm.markDelivered(ctx, userID, ids...) || len(ids) < pendingDirectPage {
+			return
+		}
+	}
+}
+
+// deliverDirect sends a direct message to its recipient if connected to this
+// node, and records the delivery
+func (rm *RoomManager) deliverDirect(ctx context.Context, recipientID string, messageID int64, msg Envelope) {
+	if rm.hub.SendToUser(recipientID, msg) {
+		rm.markDelivered(ctx, recipientID, messageID)
+	}
+}
+
+// markDelivered records deliveries, reporting whether that succeeded. A
+// failure is logged: the messages are delivered again on the next connection.
+func (rm *RoomManager) markDelivered(ctx context.Context, recipientID string, ids ...int64) bool {
+	if err := rm.directMessages.MarkDelivered(ctx, recipientID, ids...); err != nil {
+		telemetry.Logger(ctx, rm.logger).Error("Failed to mark direct messages delivered",
+			logger.Err(err),
+			logger.String("user_id", recipientID))
+		return false
+	}
+	return true
+}
+
+// directEnvelope returns the message a direct message's recipient receives
+func (rm *RoomManager) directEnvelope(message *models.DirectMessage) Envelope {
+	return envelope(&Message{
+		Type:     MessageTypeDirect,
+		UserID:   message.SenderID,
+		Username: rm.username(message.SenderID),
+		Content:  message.Content,
+		Data: map[string]interface{}{
+			"message_id": message.ID,
+			"to":         message.RecipientID,
+			"sent_at":    message.CreatedAt,
+		},
+	})
+}
+
+// username returns the name a user joined a room on this node with, or the
+// user ID if they aren't in one here
+func (rm *RoomManager) username(userID string) string {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	for _, room := range rm.rooms {
+		if user, exists := room.Users[userID]; exists {
+			return user.Username
+		}
+	}
+	return userID
+}
@@ -1,17 +1,44 @@
 package websocket
 
 import (
+	"errors"
 	"fmt"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/OkanUysal/go-logger"
 	"github.com/OkanUysal/go-response"
 	"github.com/OkanUysal/go-starter-example-project/auth"
-	"github.com/OkanUysal/go-starter-example-project/config"
-	gowebsocket "github.com/OkanUysal/go-websocket"
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
 	"github.com/gin-gonic/gin"
 )
 
+// Page sizes of GET /ws/rooms/{room_id}/messages and /ws/dm/{user_id}
+const (
+	defaultHistoryPage = 50
+	maxHistoryPage     = 100
+)
+
+// maxPresenceUsers is how many users GET /presence looks up at once
+const maxPresenceUsers = 100
+
+// Handler serves the WebSocket HTTP endpoints for a room manager
+type Handler struct {
+	rooms  *RoomManager
+	auth   *auth.Service
+	logger *logger.Logger
+}
+
+// NewHandler creates WebSocket HTTP handlers backed by the given room manager
+func NewHandler(rooms *RoomManager, authService *auth.Service, log *logger.Logger) *Handler {
+	return &Handler{
+		rooms:  rooms,
+		auth:   authService,
+		logger: log,
+	}
+}
+
 // WebSocketConnect handles WebSocket connection
 // @Summary Connect to WebSocket
 // @Description Establish WebSocket connection for real-time communication. Requires authentication via Bearer token in header OR token query parameter.
@@ -19,11 +46,17 @@ import (
 // @Security BearerAuth
 // @Param room_id query string true "Room ID to join (use 'lobby' for public lobby)"
 // @Param token query string false "JWT token (alternative to Authorization header for WebSocket connections)"
+// @Param protocol query int false "Protocol version (default: the latest). Alternatively offer the subprotocol starter.v{version}"
+// @Param format query string false "Wire format: json (default) or msgpack, for binary MessagePack frames. Alternatively offer the subprotocol starter.v{version}.msgpack" Enums(json, msgpack)
 // @Success 101 "Switching Protocols"
+// @Failure 400 {object} map[string]string "Unsupported protocol version or format"
 // @Failure 401 {object} map[string]string "Unauthorized - Token required"
+// @Failure 403 {object} map[string]string "Not invited to the room"
 // @Failure 404 {object} map[string]string "Room not found"
+// @Failure 409 {object} map[string]string "Room is not active or full, or already connected from too many devices (reject_new connection policy)"
+// @Failure 503 {object} map[string]string "Server is shutting down"
 // @Router /ws [get]
-func WebSocketConnect(c *gin.Context) {
+func (h *Handler) WebSocketConnect(c *gin.Context) {
 	roomID := c.Query("room_id")
 	if roomID == "" {
 		roomID = LobbyRoomID
@@ -35,11 +68,14 @@ func WebSocketConnect(c *gin.Context) {
 		response.Error(c, 401, "Unauthorized", nil)
 		return
 	}
+	role, _ := auth.GetRole(c)
+
+	ctx := c.Request.Context()
+	log := telemetry.Logger(ctx, h.logger)
 
 	// Get user info for username
 	username := userID // Default to userID
-	authService := auth.NewService()
-	if user, err := authService.GetUserByID(userID); err == nil {
+	if user, err := h.auth.GetUserByID(ctx, userID); err == nil {
 		// Use display_name if available, otherwise fallback to guest ID
 		if user.DisplayName != "" {
 			username = user.DisplayName
@@ -48,48 +84,61 @@ func WebSocketConnect(c *gin.Context) {
 		}
 	}
 
-	manager := GetRoomManager()
+	manager := h.rooms
 
-	// Check if room exists
-	_, err := manager.GetRoom(roomID)
+	// Refuse new connections while draining
+	if manager.IsShuttingDown() {
+		response.Error(c, 503, "Server is shutting down", nil)
+		return
+	}
+
+	// Agree on the protocol version and wire format before upgrading, while an error can still be returned
+	protocol, format, err := negotiateProtocol(c.Request)
 	if err != nil {
+		response.Error(c, 400, err.Error(), nil)
+		return
+	}
+
+	// Check that the room can be joined
+	switch err := manager.CanJoin(ctx, roomID, userID); {
+	case errors.Is(err, errRoomNotFound):
 		response.Error(c, 404, "Room not found", nil)
 		return
+	case errors.Is(err, errJoinNotAuthorized):
+		response.Error(c, 403, err.Error(), nil)
+		return
+	case errors.Is(err, errRoomInactive), errors.Is(err, ErrRoomFull):
+		response.Error(c, 409, err.Error(), nil)
+		return
+	case err != nil:
+		response.InternalError(c, err)
+		return
 	}
 
-	// Handle WebSocket connection - go-websocket HandleConnection signature: (hub, w, r, userID)
-	// Note: This upgrades the HTTP connection to WebSocket, no response should be sent after this
-	// The client will join the room after connection by sending a join message
-	err = gowebsocket.HandleConnection(manager.GetHub(), c.Writer, c.Request, userID)
+	// Note: This upgrades the HTTP connection to WebSocket, no response should be sent after this,
+	// unless the connection policy refused it first.
+	// The user joins the requested room once the connection is registered; if
+	// that fails after all, they are sent the error and disconnected.
+	err = manager.GetHub().HandleConnection(c.Writer, c.Request, ConnectionInfo{
+		UserID:   userID,
+		Username: username,
+		Role:     models.UserRole(role),
+		RoomID:   roomID,
+		Protocol: protocol,
+		Format:   format,
+	})
+	if errors.Is(err, errTooManyConnections) {
+		response.Error(c, 409, err.Error(), nil)
+		return
+	}
 	if err != nil {
-		config.Logger.Error("WebSocket connection failed",
+		log.Error("WebSocket connection failed",
 			logger.Err(err),
 			logger.String("user_id", userID),
 			logger.String("room_id", roomID))
 		// Don't send response after WebSocket upgrade attempt
 		return
 	}
-
-	// After connection is established, automatically join the requested room
-	// We'll do this via a goroutine to avoid blocking
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				config.Logger.Error("Panic in auto-join",
-					logger.String("error", fmt.Sprint(r)),
-					logger.String("user_id", userID),
-					logger.String("room_id", roomID))
-			}
-		}()
-
-		time.Sleep(100 * time.Millisecond) // Give connection time to establish
-		if joinErr := manager.JoinRoom(roomID, userID, username); joinErr != nil {
-			config.Logger.Error("Failed to auto-join room after connection",
-				logger.Err(joinErr),
-				logger.String("user_id", userID),
-				logger.String("room_id", roomID))
-		}
-	}()
 }
 
 // GetRooms returns all active rooms
@@ -100,9 +149,9 @@ func WebSocketConnect(c *gin.Context) {
 // @Success 200 {object} map[string]interface{} "List of rooms"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Router /ws/rooms [get]
-func GetRooms(c *gin.Context) {
-	manager := GetRoomManager()
-	rooms := manager.GetAllRooms()
+func (h *Handler) GetRooms(c *gin.Context) {
+	manager := h.rooms
+	rooms := manager.GetAllRooms(c.Request.Context())
 
 	response.Success(c, gin.H{
 		"rooms": rooms,
@@ -120,11 +169,11 @@ func GetRooms(c *gin.Context) {
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 404 {object} map[string]string "Room not found"
 // @Router /ws/rooms/{room_id} [get]
-func GetRoomInfo(c *gin.Context) {
+func (h *Handler) GetRoomInfo(c *gin.Context) {
 	roomID := c.Param("room_id")
 
-	manager := GetRoomManager()
-	room, err := manager.GetRoom(roomID)
+	manager := h.rooms
+	room, err := manager.GetRoom(c.Request.Context(), roomID)
 	if err != nil {
 		response.Error(c, 404, "Room not found", nil)
 		return
@@ -135,6 +184,253 @@ func GetRoomInfo(c *gin.Context) {
 	}, "Room information retrieved successfully")
 }
 
+// GetRoomMessages returns a page of a room's chat history
+// @Summary Get room chat history
+// @Description Get a room's chat messages, newest page first. Pass the oldest returned message ID as before to page further back. Game rooms' history requires an invitation when room authorization is enabled.
+// @Tags websocket
+// @Security BearerAuth
+// @Param room_id path string true "Room ID"
+// @Param before query int false "Only return messages with an ID below this one"
+// @Param limit query int false "Maximum number of messages (default 50, at most 100)"
+// @Success 200 {object} map[string]interface{} "Chat messages, oldest first"
+// @Failure 400 {object} map[string]string "Invalid before or limit"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Not invited to the room"
+// @Failure 404 {object} map[string]string "Room not found"
+// @Router /ws/rooms/{room_id}/messages [get]
+func (h *Handler) GetRoomMessages(c *gin.Context) {
+	roomID := c.Param("room_id")
+	userID, _ := auth.GetUserID(c)
+
+	before, limit, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+
+	messages, err := h.rooms.History(c.Request.Context(), roomID, userID, before, limit)
+	switch {
+	case errors.Is(err, errRoomNotFound):
+		response.Error(c, 404, "Room not found", nil)
+		return
+	case errors.Is(err, errNotAuthorized):
+		response.Error(c, 403, err.Error(), nil)
+		return
+	case err != nil:
+		response.Error(c, 500, "Failed to load messages", err)
+		return
+	}
+
+	// A full page may have older messages behind it
+	var nextBefore int64
+	if len(messages) == limit {
+		nextBefore = messages[0].ID
+	}
+
+	response.Success(c, gin.H{
+		"messages":    messages,
+		"total":       len(messages),
+		"next_before": nextBefore,
+	}, "Messages retrieved successfully")
+}
+
+// GetConversation returns a page of the direct messages between the caller and another user
+// @Summary Get direct message conversation
+// @Description Get the direct messages exchanged with another user, newest page first. Pass the oldest returned message ID as before to page further back.
+// @Tags websocket
+// @Security BearerAuth
+// @Param user_id path string true "The other user's ID"
+// @Param before query int false "Only return messages with an ID below this one"
+// @Param limit query int false "Maximum number of messages (default 50, at most 100)"
+// @Success 200 {object} map[string]interface{} "Direct messages, oldest first"
+// @Failure 400 {object} map[string]string "Invalid before or limit"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /ws/dm/{user_id} [get]
+func (h *Handler) GetConversation(c *gin.Context) {
+	otherID := c.Param("user_id")
+	userID, _ := auth.GetUserID(c)
+
+	before, limit, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+
+	messages, err := h.rooms.Conversation(c.Request.Context(), userID, otherID, before, limit)
+	if err != nil {
+		response.Error(c, 500, "Failed to load messages", err)
+		return
+	}
+
+	var nextBefore int64
+	if len(messages) == limit {
+		nextBefore = messages[0].ID
+	}
+
+	response.Success(c, gin.H{
+		"messages":    messages,
+		"total":       len(messages),
+		"next_before": nextBefore,
+	}, "Messages retrieved successfully")
+}
+
+// GetBlocks lists the users the caller blocks
+// @Summary List blocked users
+// @Description Get the users whose direct messages the caller refuses, oldest block first
+// @Tags websocket
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Blocked users"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /ws/blocks [get]
+func (h *Handler) GetBlocks(c *gin.Context) {
+	userID, _ := auth.GetUserID(c)
+
+	blocks, err := h.rooms.Blocked(c.Request.Context(), userID)
+	if err != nil {
+		response.Error(c, 500, "Failed to load blocked users", err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"blocks": blocks,
+		"total":  len(blocks),
+	}, "Blocked users retrieved successfully")
+}
+
+// BlockUser stops a user from sending the caller direct messages
+// @Summary Block user
+// @Description Refuse direct messages from a user. Blocking a user twice is not an error.
+// @Tags websocket
+// @Security BearerAuth
+// @Param user_id path string true "User ID to block"
+// @Success 200 {object} map[string]string "User blocked"
+// @Failure 400 {object} map[string]string "Cannot block yourself"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "User not found"
+// @Router /ws/blocks/{user_id} [post]
+func (h *Handler) BlockUser(c *gin.Context) {
+	blockedID := c.Param("user_id")
+	userID, _ := auth.GetUserID(c)
+	ctx := c.Request.Context()
+
+	if _, err := h.auth.GetUserByID(ctx, blockedID); err != nil {
+		response.Error(c, 404, "User not found", nil)
+		return
+	}
+
+	err := h.rooms.Block(ctx, userID, blockedID)
+	switch {
+	case errors.Is(err, errBlockSelf):
+		response.Error(c, 400, err.Error(), nil)
+		return
+	case err != nil:
+		response.Error(c, 500, "Failed to block user", err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"user_id": blockedID,
+	}, "User blocked successfully")
+}
+
+// UnblockUser lets a blocked user send the caller direct messages again
+// @Summary Unblock user
+// @Description Accept direct messages from a previously blocked user again. Unblocking a user who isn't blocked is not an error.
+// @Tags websocket
+// @Security BearerAuth
+// @Param user_id path string true "User ID to unblock"
+// @Success 200 {object} map[string]string "User unblocked"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /ws/blocks/{user_id} [delete]
+func (h *Handler) UnblockUser(c *gin.Context) {
+	blockedID := c.Param("user_id")
+	userID, _ := auth.GetUserID(c)
+
+	if err := h.rooms.Unblock(c.Request.Context(), userID, blockedID); err != nil {
+		response.Error(c, 500, "Failed to unblock user", err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"user_id": blockedID,
+	}, "User unblocked successfully")
+}
+
+// GetPresence returns the status of several users
+// @Summary Get user presence
+// @Description Get whether users are online, away or offline across all their connections, with when offline users were last seen
+// @Tags websocket
+// @Security BearerAuth
+// @Param user_ids query string true "Comma-separated user IDs (at most 100)"
+// @Success 200 {object} map[string]interface{} "Presence of each user, in the order given"
+// @Failure 400 {object} map[string]string "Missing or too many user IDs"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /presence [get]
+func (h *Handler) GetPresence(c *gin.Context) {
+	var userIDs []string
+	for _, userID := range strings.Split(c.Query("user_ids"), ",") {
+		if userID = strings.TrimSpace(userID); userID != "" {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	if len(userIDs) == 0 {
+		response.Error(c, 400, "user_ids is required", nil)
+		return
+	}
+	if len(userIDs) > maxPresenceUsers {
+		response.Error(c, 400, fmt.Sprintf("at most %d user IDs are allowed", maxPresenceUsers), nil)
+		return
+	}
+
+	presence, err := h.rooms.Presence(c.Request.Context(), userIDs...)
+	if err != nil {
+		response.Error(c, 500, "Failed to load presence", err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"presence": presence,
+	}, "Presence retrieved successfully")
+}
+
+// GetAsyncAPI returns the AsyncAPI description of the WebSocket protocol
+// @Summary WebSocket protocol description
+// @Description AsyncAPI document of the messages exchanged over /ws, with their payloads, validation rules and error codes
+// @Tags websocket
+// @Produce json
+// @Success 200 {object} map[string]interface{} "AsyncAPI document"
+// @Router /asyncapi.json [get]
+func (h *Handler) GetAsyncAPI(c *gin.Context) {
+	doc, err := AsyncAPI()
+	if err != nil {
+		response.Error(c, 500, "Failed to describe the protocol", err)
+		return
+	}
+	c.Data(200, "application/json", doc)
+}
+
+// pageQuery reads the before and limit query parameters of a message page,
+// replying with 400 and returning false if either is invalid
+func pageQuery(c *gin.Context) (before int64, limit int, ok bool) {
+	if value := c.Query("before"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			response.Error(c, 400, "before must be a positive message ID", nil)
+			return 0, 0, false
+		}
+		before = parsed
+	}
+
+	limit = defaultHistoryPage
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			response.Error(c, 400, "limit must be a positive number", nil)
+			return 0, 0, false
+		}
+		limit = min(parsed, maxHistoryPage)
+	}
+	return before, limit, true
+}
+
 // CreateRoom creates a new game room (admin only)
 // @Summary Create game room
 // @Description Create a new game room (admin only)
@@ -148,7 +444,7 @@ func GetRoomInfo(c *gin.Context) {
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 403 {object} map[string]string "Admin access required"
 // @Router /ws/rooms [post]
-func CreateRoom(c *gin.Context) {
+func (h *Handler) CreateRoom(c *gin.Context) {
 	var req CreateRoomRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Error(c, 400, "Invalid request", err)
@@ -157,8 +453,8 @@ func CreateRoom(c *gin.Context) {
 
 	userID, _ := auth.GetUserID(c)
 
-	manager := GetRoomManager()
-	room, err := manager.CreateRoom(req.Name, userID, req.MaxPlayers)
+	manager := h.rooms
+	room, err := manager.CreateRoom(c.Request.Context(), req.Name, userID, req.MaxPlayers)
 	if err != nil {
 		response.Error(c, 500, "Failed to create room", err)
 		return
@@ -190,17 +486,18 @@ func CreateRoom(c *gin.Context) {
 // @Failure 403 {object} map[string]string "Admin access required"
 // @Failure 404 {object} map[string]string "Room not found"
 // @Router /ws/rooms/{room_id} [delete]
-func CloseRoom(c *gin.Context) {
+func (h *Handler) CloseRoom(c *gin.Context) {
 	roomID := c.Param("room_id")
 
-	manager := GetRoomManager()
-	err := manager.CloseRoom(roomID)
+	manager := h.rooms
+	err := manager.CloseRoom(c.Request.Context(), roomID)
 	if err != nil {
-		if err.Error() == "cannot close lobby room" {
+		switch {
+		case errors.Is(err, errCloseLobby):
 			response.Error(c, 400, err.Error(), nil)
-		} else if err.Error() == "room not found" {
+		case errors.Is(err, errRoomNotFound):
 			response.Error(c, 404, err.Error(), nil)
-		} else {
+		default:
 			response.Error(c, 500, "Failed to close room", err)
 		}
 		return
@@ -234,24 +531,24 @@ func CloseRoom(c *gin.Context) {
 // @Failure 403 {object} map[string]string "Admin access required"
 // @Failure 404 {object} map[string]string "Room not found"
 // @Router /ws/invite [post]
-func InviteToRoom(c *gin.Context) {
+func (h *Handler) InviteToRoom(c *gin.Context) {
 	var req InviteRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Error(c, 400, "Invalid request", err)
 		return
 	}
 
-	manager := GetRoomManager()
+	manager := h.rooms
 
 	// Check if room exists
-	room, err := manager.GetRoom(req.RoomID)
+	room, err := manager.GetRoom(c.Request.Context(), req.RoomID)
 	if err != nil {
 		response.Error(c, 404, "Room not found", err)
 		return
 	}
 
 	// Grant permission to join (if room auth is enabled)
-	if err := manager.InviteToRoom(req.RoomID, req.UserIDs); err != nil {
+	if err := manager.InviteToRoom(c.Request.Context(), req.RoomID, req.UserIDs); err != nil {
 		response.Error(c, 500, "Failed to invite users", err)
 		return
 	}
@@ -268,7 +565,7 @@ func InviteToRoom(c *gin.Context) {
 		})
 	}
 
-	config.Logger.Info("Room invitations sent",
+	telemetry.Logger(c.Request.Context(), h.logger).Info("Room invitations sent",
 		logger.String("room_id", req.RoomID),
 		logger.Int("user_count", len(req.UserIDs)))
 
@@ -0,0 +1,627 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Connection tuning
+const (
+	// writeWait bounds each write to a client
+	writeWait = 10 * time.Second
+
+	// pongWait is how long a client may stay silent before it is dropped;
+	// pings are sent often enough that a live client always answers in time
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+
+	// maxMessageSize limits inbound frames
+	maxMessageSize = 64 * 1024
+
+	// sendBufferSize is how many outbound messages may queue per client before
+	// further ones are dropped, so one slow reader can't stall a broadcast
+	sendBufferSize = 256
+)
+
+// errRoomNotInHub is returned when joining a room the hub doesn't know yet
+var errRoomNotInHub = errors.New("room not found")
+
+// Envelope is the JSON frame exchanged with clients: {"type": ..., "data": {...}}.
+// Messages broadcast to a room also carry the room's ID and their sequence
+// number in it.
+type Envelope struct {
+	Type   string                 `json:"type"`
+	RoomID string                 `json:"room_id,omitempty"`
+	Seq    int64                  `json:"seq,omitempty"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+// ConnectionInfo describes a connection being opened: the user it is for,
+// the room they asked to join and what was negotiated for it
+type ConnectionInfo struct {
+	UserID   string
+	Username string
+	Role     models.UserRole
+	RoomID   string
+	Protocol int
+	Format   Format
+}
+
+// Client is one WebSocket connection. A user connected from several devices
+// has a client for each.
+type Client struct {
+	// ID identifies the connection, unlike UserID which all of a user's connections share
+	ID       string
+	UserID   string
+	Username string
+
+	// Role is the user's role when they connected, for handlers to authorize messages
+	Role models.UserRole
+
+	// Protocol and Format are the protocol version and wire format negotiated for the connection
+	Protocol int
+	Format   Format
+
+	// status is whether the connection is online or away, guarded by the hub's mu
+	status PresenceStatus
+
+	hub         *Hub
+	conn        *websocket.Conn
+	connectedAt time.Time
+	send        chan *frame
+	done        chan struct{}
+	closeOnce   sync.Once
+
+	// closeCode is the status of the close frame, set before the client is closed
+	closeCode int
+
+	// flushed is closed once the writer has sent what was queued and the close frame
+	flushed chan struct{}
+}
+
+// Hub tracks connected clients and which rooms they are in, and delivers
+// messages to them. Each client has its own writer goroutine, so sending
+// never blocks on the network. A user is in a room as long as one of their
+// connections is.
+type Hub struct {
+	mu           sync.RWMutex
+	clients      map[string]connections            // user ID -> the user's connections
+	rooms        map[string]map[string]connections // room ID -> member user ID -> their connections in the room
+	onConnect    func(ctx context.Context, client *Client, info ConnectionInfo) error
+	onMessage    func(*Client, Envelope)
+	onDisconnect func(client *Client, roomIDs []string, last bool)
+	metrics      *Metrics
+	upgrader     websocket.Upgrader
+
+	// compressMinSize is the smallest message compressed for clients that
+	// negotiated compression (zero: compression is off)
+	compressMinSize int
+
+	// policy decides whether a user holding maxConnections may open another one
+	policy         ConnectionPolicy
+	maxConnections int
+
+	// lifecycle holds a lock per user serializing setting up and tearing
+	// down their connections, so a quick reconnect can't interleave with the
+	// old connection's cleanup (e.g. leaving a room just after the new
+	// connection joined it). Other users' handlers don't wait on it.
+	lifecycleMu sync.Mutex
+	lifecycle   map[string]*lifecycleLock
+}
+
+// lifecycleLock is a user's lifecycle lock, kept while anyone holds or waits for it
+type lifecycleLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewHub creates an empty hub recording into metrics (may be nil). Clients
+// that offer per-message compression get their messages of compressMinSize
+// bytes or more compressed; zero turns compression off.
+func NewHub(metrics *Metrics, compressMinSize int) *Hub {
+	return &Hub{
+		clients:   make(map[string]connections),
+		rooms:     make(map[string]map[string]connections),
+		lifecycle: make(map[string]*lifecycleLock),
+		metrics:   metrics,
+		upgrader: websocket.Upgrader{
+			// Connections are authenticated by token, not cookies, so any origin may connect
+			CheckOrigin:       func(*http.Request) bool { return true },
+			EnableCompression: compressMinSize > 0,
+		},
+		compressMinSize: compressMinSize,
+	}
+}
+
+// SetOnConnect sets the handler called when a connection is registered, with
+// the request's context. The client's messages are only read, and its
+// disconnect (or that of the user's other connections) only handled, once the
+// handler returned. If it returns an error the connection is refused: it is
+// closed with a policy violation after what was queued to it, and connections
+// it would have replaced are kept.
+func (h *Hub) SetOnConnect(fn func(ctx context.Context, client *Client, info ConnectionInfo) error) {
+	h.onConnect = fn
+}
+
+// SetOnMessage sets the handler called, in order, for each message a client sends
+func (h *Hub) SetOnMessage(fn func(client *Client, msg Envelope)) {
+	h.onMessage = fn
+}
+
+// SetOnDisconnect sets the handler called when a user's connection closes,
+// with the rooms the user left because no other connection of theirs is in
+// them, and whether it was the user's last connection
+func (h *Hub) SetOnDisconnect(fn func(client *Client, roomIDs []string, last bool)) {
+	h.onDisconnect = fn
+}
+
+// HandleConnection applies the connection policy, upgrades the request,
+// registers the connection described by info and runs the connect handler.
+// A client that offered subprotocols is answered with the negotiated one. It
+// returns errTooManyConnections, before upgrading, if the policy refuses the
+// connection.
+func (h *Hub) HandleConnection(w http.ResponseWriter, r *http.Request, info ConnectionInfo) error {
+	unlock := h.lockLifecycle(info.UserID)
+
+	replaced, err := h.admit(info.UserID)
+	if err != nil {
+		unlock()
+		return err
+	}
+
+	var header http.Header
+	if len(websocket.Subprotocols(r)) > 0 {
+		header = http.Header{"Sec-Websocket-Protocol": {subprotocolName(info.Protocol, info.Format)}}
+	}
+	conn, err := h.upgrader.Upgrade(w, r, header)
+	if err != nil {
+		unlock()
+		return err
+	}
+
+	client := &Client{
+		ID:          uuid.NewString(),
+		UserID:      info.UserID,
+		Username:    info.Username,
+		Role:        info.Role,
+		Protocol:    info.Protocol,
+		Format:      info.Format,
+		hub:         h,
+		conn:        conn,
+		connectedAt: time.Now(),
+		send:        make(chan *frame, sendBufferSize),
+		done:        make(chan struct{}),
+		flushed:     make(chan struct{}),
+		closeCode:   websocket.CloseGoingAway,
+	}
+
+	h.mu.Lock()
+	if h.clients[info.UserID] == nil {
+		h.clients[info.UserID] = make(connections)
+	}
+	h.clients[info.UserID][client.ID] = client
+	h.mu.Unlock()
+	h.metrics.clientConnected()
+
+	// The connect handler may already send to the client. Reading starts
+	// after it, so the client's first message finds it set up (e.g. in its room).
+	go client.writePump()
+	refused := func() bool {
+		defer unlock()
+		if h.onConnect != nil {
+			// The upgrade request is over once this returns, but its request ID is still wanted
+			if err := h.onConnect(context.WithoutCancel(r.Context()), client, info); err != nil {
+				h.unregister(client)
+				return true
+			}
+		}
+
+		// Connections the new one replaces go only now, so the rooms both are
+		// in aren't left and joined again
+		for _, old := range replaced {
+			old.enqueue(reply(MessageTypeKicked, KickedReply{
+				Reason:       "connected from another device",
+				ConnectionID: client.ID,
+			}))
+			h.unregister(old)
+		}
+		return false
+	}()
+	if refused {
+		client.closeCode = websocket.ClosePolicyViolation
+		client.close()
+		return nil
+	}
+	for _, old := range replaced {
+		old.close()
+	}
+	go client.readPump()
+	return nil
+}
+
+// lockLifecycle takes the lock serializing a user's connection setups and
+// teardowns, and returns the function releasing it
+func (h *Hub) lockLifecycle(userID string) func() {
+	h.lifecycleMu.Lock()
+	lock := h.lifecycle[userID]
+	if lock == nil {
+		lock = &lifecycleLock{}
+		h.lifecycle[userID] = lock
+	}
+	lock.refs++
+	h.lifecycleMu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		h.lifecycleMu.Lock()
+		defer h.lifecycleMu.Unlock()
+		if lock.refs--; lock.refs == 0 {
+			delete(h.lifecycle, userID)
+		}
+	}
+}
+
+// CreateRoomWithID registers a room so clients can join it
+func (h *Hub) CreateRoomWithID(roomID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, exists := h.rooms[roomID]; exists {
+		return errors.New("room already exists")
+	}
+	h.rooms[roomID] = make(map[string]connections)
+	h.metrics.setRoomUsers(roomID, 0)
+	return nil
+}
+
+// CloseRoom forgets a room and its members; their connections stay open
+func (h *Hub) CloseRoom(roomID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.rooms, roomID)
+	h.metrics.deleteRoomUsers(roomID)
+}
+
+// JoinRoom adds a connection to a room, reporting whether it is the first of
+// its user's connections there, i.e. whether the user just joined the room
+func (h *Hub) JoinRoom(client *Client, roomID string) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	members, exists := h.rooms[roomID]
+	if !exists {
+		return false, errRoomNotInHub
+	}
+	if h.clients[client.UserID][client.ID] != client {
+		return false, errors.New("client not found")
+	}
+	own, joined := members[client.UserID]
+	if !joined {
+		own = make(connections)
+		members[client.UserID] = own
+	}
+	own[client.ID] = client
+	h.metrics.setRoomUsers(roomID, len(members))
+	return !joined, nil
+}
+
+// LeaveRoom removes a user from a room, on all their connections
+func (h *Hub) LeaveRoom(userID, roomID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if members, exists := h.rooms[roomID]; exists {
+		delete(members, userID)
+		h.metrics.setRoomUsers(roomID, len(members))
+	}
+}
+
+// IsMember reports whether a connected user is in a room
+func (h *Hub) IsMember(userID, roomID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	_, member := h.rooms[roomID][userID]
+	return member
+}
+
+// UserRooms returns the rooms a connected user is in
+func (h *Hub) UserRooms(userID string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var roomIDs []string
+	for roomID, members := range h.rooms {
+		if _, member := members[userID]; member {
+			roomIDs = append(roomIDs, roomID)
+		}
+	}
+	return roomIDs
+}
+
+// GetRoomClientCount returns how many users are in a room
+func (h *Hub) GetRoomClientCount(roomID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.rooms[roomID])
+}
+
+// BroadcastToRoom queues msg for every connection in a room. It is encoded
+// once per wire format among them, not once per connection.
+func (h *Hub) BroadcastToRoom(roomID string, msg Envelope) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	encoded := newFrames(msg)
+	for _, own := range h.rooms[roomID] {
+		for _, client := range own {
+			f, err := encoded.get(client.Format)
+			if err != nil {
+				h.metrics.messageDropped(msg.Type)
+				continue
+			}
+			client.enqueueFrame(f)
+		}
+	}
+}
+
+// SendToUser queues msg for each of a user's connections, reporting whether
+// it was queued for any: false if the user isn't connected or their buffers are full
+func (h *Hub) SendToUser(userID string, msg Envelope) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	queued := false
+	for _, client := range h.clients[userID] {
+		if client.enqueue(msg) {
+			queued = true
+		}
+	}
+	return queued
+}
+
+// Broadcast queues msg for every connection on this node, encoded once per
+// wire format, and returns how many connections it was queued for
+func (h *Hub) Broadcast(msg Envelope) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	encoded := newFrames(msg)
+	queued := 0
+	for _, own := range h.clients {
+		for _, client := range own {
+			f, err := encoded.get(client.Format)
+			if err != nil {
+				h.metrics.messageDropped(msg.Type)
+				continue
+			}
+			if client.enqueueFrame(f) {
+				queued++
+			}
+		}
+	}
+	return queued
+}
+
+// SetStatus records whether a connection is online or away
+func (h *Hub) SetStatus(client *Client, status PresenceStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client.status = status
+}
+
+// UserStatus returns a user's status across their connections to this hub:
+// online if any is, away if all are, offline without one
+func (h *Hub) UserStatus(userID string) PresenceStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	statuses := make([]PresenceStatus, 0, len(h.clients[userID]))
+	for _, client := range h.clients[userID] {
+		statuses = append(statuses, client.status)
+	}
+	return combinePresence(userID, statuses, nil).Status
+}
+
+// ConnectionCount returns how many connections a user has to this hub
+func (h *Hub) ConnectionCount(userID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients[userID])
+}
+
+// Close disconnects every client and waits until their writers have flushed
+// what was queued for them and sent a close frame, or until ctx expires
+func (h *Hub) Close(ctx context.Context) error {
+	h.mu.RLock()
+	var clients []*Client
+	for _, own := range h.clients {
+		for _, client := range own {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		client.close()
+	}
+	for _, client := range clients {
+		select {
+		case <-client.flushed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// remove unregisters a closed client, unless the connection policy already did
+func (h *Hub) remove(client *Client) {
+	unlock := h.lockLifecycle(client.UserID)
+	defer unlock()
+
+	h.unregister(client)
+}
+
+// unregister forgets a connection and takes it out of every room, then runs
+// the disconnect handler. The caller holds the user's lifecycle lock.
+func (h *Hub) unregister(client *Client) {
+	h.mu.Lock()
+	own := h.clients[client.UserID]
+	if own[client.ID] != client {
+		h.mu.Unlock()
+		return
+	}
+	delete(own, client.ID)
+	last := len(own) == 0
+	if last {
+		delete(h.clients, client.UserID)
+	}
+
+	// The user only leaves the rooms none of their other connections are in
+	var roomIDs []string
+	for roomID, members := range h.rooms {
+		inRoom, member := members[client.UserID]
+		if !member {
+			continue
+		}
+		if _, joined := inRoom[client.ID]; !joined {
+			continue
+		}
+		delete(inRoom, client.ID)
+		if len(inRoom) == 0 {
+			delete(members, client.UserID)
+			h.metrics.setRoomUsers(roomID, len(members))
+			roomIDs = append(roomIDs, roomID)
+		}
+	}
+	h.mu.Unlock()
+
+	if h.onDisconnect != nil {
+		h.onDisconnect(client, roomIDs, last)
+	}
+}
+
+// enqueue encodes msg in the client's format and queues it without blocking;
+// if the client's buffer is full the message is dropped and enqueue returns false
+func (c *Client) enqueue(msg Envelope) bool {
+	f, err := newFrame(msg, c.Format)
+	if err != nil {
+		c.hub.metrics.messageDropped(msg.Type)
+		return false
+	}
+	return c.enqueueFrame(f)
+}
+
+// enqueueFrame queues an encoded message like enqueue
+func (c *Client) enqueueFrame(f *frame) bool {
+	select {
+	case c.send <- f:
+		c.hub.metrics.messageSent(f.msgType)
+		return true
+	default:
+		c.hub.metrics.messageDropped(f.msgType)
+		return false
+	}
+}
+
+// close ends the connection once; both pumps then exit
+func (c *Client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.hub.remove(c)
+		c.hub.metrics.clientDisconnected()
+	})
+}
+
+// readPump passes inbound messages to the hub's handler until the connection fails
+func (c *Client) readPump() {
+	defer func() {
+		c.close()
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		frameType, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		// A malformed frame is answered, not fatal: the client may just have a bug
+		var msg Envelope
+		if err := decodeMessage(frameType, data, &msg); err != nil || msg.Type == "" {
+			c.enqueue(reply(MessageTypeError, ErrorReply{
+				Code:    ErrorCodeInvalidMessage,
+				Message: `messages must be JSON text or MessagePack binary objects like {"type": "...", "data": {...}}`,
+			}))
+			continue
+		}
+		if c.hub.onMessage != nil {
+			c.hub.onMessage(c, msg)
+		}
+	}
+}
+
+// writePump writes queued messages and keepalive pings. Once the client is
+// closed, messages already queued are still flushed before the close frame.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+		close(c.flushed)
+	}()
+
+	for {
+		select {
+		case f := <-c.send:
+			if !c.write(f) {
+				c.close()
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			for {
+				select {
+				case f := <-c.send:
+					if !c.write(f) {
+						return
+					}
+				default:
+					c.conn.WriteControl(websocket.CloseMessage,
+						websocket.FormatCloseMessage(c.closeCode, ""),
+						time.Now().Add(writeWait))
+					return
+				}
+			}
+		}
+	}
+}
+
+// write sends one message, reporting whether the connection is still usable.
+// Small messages aren't worth compressing, so only larger ones are.
+func (c *Client) write(f *frame) bool {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	c.conn.EnableWriteCompression(c.hub.compressMinSize > 0 && f.size >= c.hub.compressMinSize)
+	return c.conn.WritePreparedMessage(f.prepared) == nil
+}
@@ -1,90 +1,376 @@
 package websocket
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/OkanUysal/go-logger"
-	"github.com/OkanUysal/go-starter-example-project/config"
-	gowebsocket "github.com/OkanUysal/go-websocket"
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/OkanUysal/go-starter-example-project/repository"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// RoomManager manages all WebSocket rooms
-type RoomManager struct {
-	hub   *gowebsocket.Hub
-	rooms map[string]*RoomInfo
-	mu    sync.RWMutex
-}
+// instrumentationName identifies the WebSocket message spans' tracer
+const instrumentationName = "github.com/OkanUysal/go-starter-example-project/websocket"
+
+// Chat history defaults
+const (
+	// defaultHistorySize is how many messages per room the default in-memory history keeps
+	defaultHistorySize = 100
+
+	// defaultHistoryOnJoin is how many recent messages a user receives on joining a room
+	defaultHistoryOnJoin = 50
+)
 
+// Errors returned for rooms and their history
 var (
-	manager     *RoomManager
-	managerOnce sync.Once
+	errRoomNotFound      = errors.New("room not found")
+	errRoomInactive      = errors.New("room is not active")
+	errRoomClosed        = errors.New("room already closed")
+	errCloseLobby        = errors.New("cannot close lobby room")
+	errNotAuthorized     = errors.New("you are not authorized to read this room")
+	errJoinNotAuthorized = errors.New("you are not authorized to join this room")
 )
 
-// GetRoomManager returns the singleton room manager instance
-func GetRoomManager() *RoomManager {
-	managerOnce.Do(func() {
-		manager = &RoomManager{
-			hub:   gowebsocket.NewHub(nil), // Use default config
-			rooms: make(map[string]*RoomInfo),
-		}
+// ManagerOptions holds the optional dependencies and settings of a RoomManager
+type ManagerOptions struct {
+	// RoomAuthEnabled requires an invitation to join game rooms
+	RoomAuthEnabled bool
 
-		// Set up message handler
-		manager.hub.SetOnMessage(manager.handleMessage)
-
-		// Create lobby room (always open)
-		manager.rooms[LobbyRoomID] = &RoomInfo{
-			ID:        LobbyRoomID,
-			Type:      RoomTypeLobby,
-			Name:      "Public Lobby",
-			CreatedAt: time.Now(),
-			IsActive:  true,
-			Users:     make(map[string]*UserInfo),
-		}
+	// TracerProvider traces each inbound message (optional)
+	TracerProvider trace.TracerProvider
 
-		// Also create lobby in hub (for BroadcastToRoom to work)
-		// We need to manually create the room in hub's internal map
-		// Since go-websocket doesn't expose a way to create room with custom ID,
-		// we'll create it when first user joins
+	// Metrics records hub and room metrics (optional)
+	Metrics *Metrics
 
-		config.Logger.Info("WebSocket room manager initialized",
-			logger.String("lobby_id", LobbyRoomID))
-	})
-	return manager
+	// Backplane shares rooms, messages and members with the other replicas
+	// (optional: defaults to an in-process backplane, for a single node)
+	Backplane Backplane
+
+	// Store persists game rooms and invitations so they survive restarts
+	// (optional: defaults to an in-memory store, lost on restart)
+	Store repository.RoomRepository
+
+	// History stores chat messages (optional: defaults to an in-memory ring
+	// buffer of each room's latest 100 messages)
+	History repository.MessageRepository
+
+	// HistoryOnJoin is how many recent messages a user receives on joining a room (zero: 50)
+	HistoryOnJoin int
+
+	// DirectMessages stores direct messages until they are delivered, and
+	// afterwards as conversations (optional: defaults to an in-memory store)
+	DirectMessages repository.DirectMessageRepository
+
+	// Blocks stores which users refuse direct messages from which (optional:
+	// defaults to an in-memory store)
+	Blocks repository.BlockRepository
+
+	// Friends lists who besides a user's rooms is told about their presence
+	// changes (optional: without it, only the rooms are)
+	Friends FriendSource
+
+	// TypingTimeout is how long a typing indicator lasts unless renewed (zero: 5s)
+	TypingTimeout time.Duration
+
+	// ReplayBufferSize is how many recent messages per room this node keeps
+	// for clients resuming after a reconnect (zero: 256)
+	ReplayBufferSize int
+
+	// MessageRate is how many messages per second each user may send on
+	// average (zero: 20), in bursts of up to MessageBurst (zero: 40)
+	MessageRate  int
+	MessageBurst int
+
+	// Compression compresses messages of at least CompressionMinSize bytes
+	// (zero: 256) for clients that offer per-message compression
+	Compression        bool
+	CompressionMinSize int
+
+	// ConnectionPolicy decides what happens when a user holding
+	// MaxConnections connections (zero: 1) to this node opens another one
+	// (default: allow_many, without a limit)
+	ConnectionPolicy ConnectionPolicy
+	MaxConnections   int
 }
 
-// Start starts the room manager hub
-func (rm *RoomManager) Start() {
-	go rm.hub.Run()
+// RoomManager manages all WebSocket rooms
+type RoomManager struct {
+	hub             *Hub
+	rooms           map[string]*RoomInfo
+	mu              sync.RWMutex
+	running         bool
+	shuttingDown    bool
+	roomAuthEnabled bool
+	logger          *logger.Logger
+	tracer          trace.Tracer
+	metrics         *Metrics
+
+	// nodeID identifies this replica on the backplane
+	nodeID      string
+	backplane   Backplane
+	unsubscribe func()
+
+	// store is where game rooms are written through to
+	store repository.RoomRepository
+
+	// history stores chat messages; historyOnJoin of them are sent on join
+	history       repository.MessageRepository
+	historyOnJoin int
+
+	// directMessages and blocks back direct messaging between users
+	directMessages repository.DirectMessageRepository
+	blocks         repository.BlockRepository
+
+	// friends follow users' presence; typing tracks typing indicators on this node
+	friends FriendSource
+	typing  *typingIndicators
+
+	// replay keeps each room's recent sequenced messages for resuming clients;
+	// delivery queues them for this node's connections in sequence order
+	replay   *replayBuffer
+	delivery *orderedDelivery
+
+	// registry routes each inbound message to the handler of its type
+	registry *Registry
+}
+
+// NewRoomManager creates a room manager with its own hub and lobby
+func NewRoomManager(log *logger.Logger, opts ManagerOptions) *RoomManager {
+	if opts.Backplane == nil {
+		opts.Backplane = NewMemoryBackplane()
+	}
+	if opts.Store == nil {
+		opts.Store = repository.NewMemoryRoomRepository()
+	}
+	if opts.History == nil {
+		opts.History = repository.NewMemoryMessageRepository(defaultHistorySize)
+	}
+	if opts.HistoryOnJoin <= 0 {
+		opts.HistoryOnJoin = defaultHistoryOnJoin
+	}
+	if opts.DirectMessages == nil {
+		opts.DirectMessages = repository.NewMemoryDirectMessageRepository()
+	}
+	if opts.Blocks == nil {
+		opts.Blocks = repository.NewMemoryBlockRepository()
+	}
+	if opts.TypingTimeout <= 0 {
+		opts.TypingTimeout = defaultTypingTimeout
+	}
+	if opts.ReplayBufferSize <= 0 {
+		opts.ReplayBufferSize = defaultReplayBufferSize
+	}
+	if opts.MessageRate <= 0 {
+		opts.MessageRate = defaultMessageRate
+	}
+	if opts.MessageBurst <= 0 {
+		opts.MessageBurst = defaultMessageBurst
+	}
+	compressMinSize := 0
+	if opts.Compression {
+		compressMinSize = opts.CompressionMinSize
+		if compressMinSize <= 0 {
+			compressMinSize = defaultCompressionMinSize
+		}
+	}
+
+	rm := &RoomManager{
+		hub:             NewHub(opts.Metrics, compressMinSize),
+		rooms:           make(map[string]*RoomInfo),
+		roomAuthEnabled: opts.RoomAuthEnabled,
+		logger:          log,
+		tracer:          telemetry.Tracer(opts.TracerProvider, instrumentationName),
+		metrics:         opts.Metrics,
+		nodeID:          uuid.New().String(),
+		backplane:       opts.Backplane,
+		store:           opts.Store,
+		history:         opts.History,
+		historyOnJoin:   opts.HistoryOnJoin,
+		directMessages:  opts.DirectMessages,
+		blocks:          opts.Blocks,
+		friends:         opts.Friends,
+		typing: &typingIndicators{
+			timeout: opts.TypingTimeout,
+			timers:  make(map[typingKey]*time.Timer),
+		},
+		replay:   newReplayBuffer(opts.ReplayBufferSize),
+		registry: NewRegistry(),
+	}
+	rm.delivery = newOrderedDelivery(gapWait, rm.hub.BroadcastToRoom)
+
+	// Every message is measured and logged, and a panicking handler answered;
+	// over the rate limit, it is rejected before reaching its handler
+	rm.registry.Use(rm.instrument, rm.logMessages, rm.recoverPanics,
+		RateLimit(float64(opts.MessageRate), opts.MessageBurst))
+	rm.registerHandlers()
+
+	// Set up connection lifecycle and message handlers
+	rm.hub.SetOnConnect(rm.handleConnect)
+	rm.hub.SetOnMessage(rm.handleMessage)
+	rm.hub.SetOnDisconnect(rm.handleDisconnect)
+	rm.hub.SetConnectionPolicy(opts.ConnectionPolicy, opts.MaxConnections)
+
+	// Create lobby room (always open). Every node has its own lobby; messages
+	// to it reach the other nodes' lobbies through the backplane.
+	rm.rooms[LobbyRoomID] = &RoomInfo{
+		ID:        LobbyRoomID,
+		Type:      RoomTypeLobby,
+		Name:      "Public Lobby",
+		CreatedAt: time.Now(),
+		IsActive:  true,
+		Users:     make(map[string]*UserInfo),
+	}
+	opts.Metrics.roomOpened(RoomTypeLobby)
+
+	// The lobby is created in the hub with its fixed ID on Start
+
+	log.Info("WebSocket room manager initialized",
+		logger.String("lobby_id", LobbyRoomID),
+		logger.String("node_id", rm.nodeID))
 
-	// Give hub time to initialize
-	time.Sleep(100 * time.Millisecond)
+	return rm
+}
 
+// Start starts the room manager hub, reopens the active game rooms saved in
+// the store, joins the backplane and loads the game rooms that other nodes
+// already have
+func (rm *RoomManager) Start() error {
 	// Create lobby room in hub with fixed ID
-	err := rm.hub.CreateRoomWithID(LobbyRoomID, &gowebsocket.RoomConfig{
-		Name:       "Public Lobby",
-		MaxClients: 0, // unlimited
-		IsPrivate:  false,
-	})
+	err := rm.hub.CreateRoomWithID(LobbyRoomID)
 
 	if err != nil {
-		config.Logger.Error("Failed to create lobby room", logger.Err(err))
+		rm.logger.Error("Failed to create lobby room", logger.Err(err))
 	} else {
-		config.Logger.Info("Lobby room created in hub", logger.String("room_id", LobbyRoomID))
+		rm.logger.Info("Lobby room created in hub", logger.String("room_id", LobbyRoomID))
+	}
+
+	// Reopen saved rooms with their IDs, so clients can reconnect to them after a restart
+	saved, err := rm.store.ListActive(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to load saved rooms: %w", err)
+	}
+	for _, room := range saved {
+		rm.applyRoom(roomFromRecord(room))
+	}
+	if len(saved) > 0 {
+		rm.logger.Info("Reopened saved rooms", logger.Int("room_count", len(saved)))
+	}
+
+	// Subscribe before loading, so a room created in between isn't missed
+	unsubscribe, err := rm.backplane.Subscribe(rm.nodeID, rm.handleClusterEvent)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to the websocket backplane: %w", err)
+	}
+
+	rooms, err := rm.backplane.Rooms(context.Background())
+	if err != nil {
+		unsubscribe()
+		return fmt.Errorf("failed to load rooms from the websocket backplane: %w", err)
+	}
+	for _, room := range rooms {
+		rm.applyRoom(room)
+	}
+
+	rm.mu.Lock()
+	rm.unsubscribe = unsubscribe
+	rm.running = true
+	rm.mu.Unlock()
+
+	if len(rooms) > 0 {
+		rm.logger.Info("Loaded rooms from the websocket backplane", logger.Int("room_count", len(rooms)))
 	}
+	return nil
+}
+
+// IsRunning reports whether the hub is started and accepting clients
+func (rm *RoomManager) IsRunning() bool {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.running && !rm.shuttingDown
+}
+
+// IsShuttingDown reports whether the manager has started shutting down
+func (rm *RoomManager) IsShuttingDown() bool {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.shuttingDown
+}
+
+// NotifyShutdown tells every connection on this node, in a room or not, that
+// the server is going away, so clients can reconnect elsewhere instead of
+// waiting for a dropped socket. Shutdown then flushes the notice before
+// closing the connections.
+func (rm *RoomManager) NotifyShutdown(ctx context.Context) error {
+	rm.mu.Lock()
+	rm.shuttingDown = true
+	rm.mu.Unlock()
+
+	// Sent to local connections only: the other nodes keep running
+	sent := rm.hub.Broadcast(envelope(&Message{
+		Type: MessageTypeServerShutdown,
+		Data: map[string]interface{}{
+			"message": "Server is shutting down, please reconnect",
+		},
+	}))
+
+	rm.logger.Info("Shutdown notice sent to WebSocket clients",
+		logger.Int("connection_count", sent))
+	return nil
+}
+
+// Shutdown disconnects all clients, closes every room in the hub and leaves
+// the backplane. Rooms stay open on the other nodes.
+func (rm *RoomManager) Shutdown(ctx context.Context) error {
+	// Disconnecting first removes this node's members from the backplane
+	if err := rm.hub.Close(ctx); err != nil {
+		rm.logger.Warn("WebSocket clients were closed before flushing", logger.Err(err))
+	}
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rm.shuttingDown = true
+	rm.running = false
+
+	for roomID, room := range rm.rooms {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if room.IsActive {
+			room.IsActive = false
+			rm.delivery.drop(roomID)
+			rm.hub.CloseRoom(roomID)
+			rm.metrics.roomClosed(room.Type)
+		}
+	}
+
+	if rm.unsubscribe != nil {
+		rm.unsubscribe()
+		rm.unsubscribe = nil
+	}
+
+	rm.logger.Info("WebSocket room manager stopped",
+		logger.Int("room_count", len(rm.rooms)))
+
+	return nil
 }
 
 // GetHub returns the WebSocket hub
-func (rm *RoomManager) GetHub() *gowebsocket.Hub {
+func (rm *RoomManager) GetHub() *Hub {
 	return rm.hub
 }
 
 // CreateRoom creates a new game room (admin only)
-func (rm *RoomManager) CreateRoom(name, createdBy string, maxPlayers int) (*RoomInfo, error) {
-	rm.mu.Lock()
-	defer rm.mu.Unlock()
+func (rm *RoomManager) CreateRoom(ctx context.Context, name, createdBy string, maxPlayers int) (*RoomInfo, error) {
+	log := telemetry.Logger(ctx, rm.logger)
 
 	roomID := uuid.New().String()
 
@@ -101,59 +387,100 @@ func (rm *RoomManager) CreateRoom(name, createdBy string, maxPlayers int) (*Room
 	}
 
 	// If room auth is enabled, creator is automatically allowed
-	if config.RoomAuthEnabled {
+	if rm.roomAuthEnabled {
 		room.AllowedUsers[createdBy] = true
 	}
 
+	// Persist the room first, so it is never shared without surviving a restart
+	if err := rm.store.Create(ctx, room.record()); err != nil {
+		log.Error("Failed to save room",
+			logger.Err(err),
+			logger.String("room_id", roomID))
+		return nil, err
+	}
+
+	// Store the room for the other nodes (and nodes started later)
+	if err := rm.backplane.SaveRoom(ctx, room); err != nil {
+		log.Error("Failed to save room to the websocket backplane",
+			logger.Err(err),
+			logger.String("room_id", roomID))
+		rm.closeSaved(ctx, roomID)
+		return nil, err
+	}
+
+	rm.mu.Lock()
 	rm.rooms[roomID] = room
 
 	// Create room in hub as well
-	err := rm.hub.CreateRoomWithID(roomID, &gowebsocket.RoomConfig{
-		Name:       name,
-		MaxClients: maxPlayers,
-		IsPrivate:  false,
-	})
+	err := rm.hub.CreateRoomWithID(roomID)
 
 	if err != nil {
-		config.Logger.Error("Failed to create room in hub",
+		log.Error("Failed to create room in hub",
 			logger.Err(err),
 			logger.String("room_id", roomID))
 		delete(rm.rooms, roomID)
+		rm.mu.Unlock()
+		rm.backplane.DeleteRoom(ctx, roomID)
+		rm.closeSaved(ctx, roomID)
 		return nil, err
 	}
+	rm.metrics.roomOpened(RoomTypeGame)
+	info := room.metadata()
+	rm.mu.Unlock()
+
+	rm.publish(ctx, &ClusterEvent{Kind: eventRoomSaved, Room: info})
 
-	config.Logger.Info("Game room created",
+	log.Info("Game room created",
 		logger.String("room_id", roomID),
 		logger.String("name", name),
 		logger.String("created_by", createdBy),
 		logger.Int("max_players", maxPlayers))
 
-	return room, nil
+	return info, nil
 }
 
-// CloseRoom closes a game room (admin only)
-func (rm *RoomManager) CloseRoom(roomID string) error {
-	rm.mu.Lock()
-	defer rm.mu.Unlock()
+// CloseRoom closes a game room (admin only) on every node
+func (rm *RoomManager) CloseRoom(ctx context.Context, roomID string) error {
+	log := telemetry.Logger(ctx, rm.logger)
 
 	if roomID == LobbyRoomID {
-		return fmt.Errorf("cannot close lobby room")
+		return errCloseLobby
 	}
 
+	rm.mu.RLock()
 	room, exists := rm.rooms[roomID]
+	active := exists && room.IsActive
+	rm.mu.RUnlock()
+
 	if !exists {
-		return fmt.Errorf("room not found")
+		return errRoomNotFound
+	}
+	if !active {
+		return errRoomClosed
 	}
 
+	// Persist the closure first, so the room isn't reopened on the next start.
+	// ErrNotFound means another node closed it in the meantime.
+	if err := rm.store.Close(ctx, roomID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Error("Failed to save room closure",
+			logger.Err(err),
+			logger.String("room_id", roomID))
+		return err
+	}
+
+	rm.mu.Lock()
 	if !room.IsActive {
-		return fmt.Errorf("room already closed")
+		rm.mu.Unlock()
+		return errRoomClosed
 	}
 
 	// Mark as inactive
 	room.IsActive = false
+	rm.metrics.roomClosed(room.Type)
+	rm.mu.Unlock()
 
 	// Notify all users in the room
-	rm.hub.BroadcastToRoom(roomID, gowebsocket.Message{
+	rm.broadcast(ctx, roomID, Envelope{
 		Type: string(MessageTypeRoomClosed),
 		Data: map[string]interface{}{
 			"room_id": roomID,
@@ -161,10 +488,18 @@ func (rm *RoomManager) CloseRoom(roomID string) error {
 		},
 	})
 
-	// Remove all clients from the room
+	// Remove all clients from the room, here and on the other nodes
+	rm.delivery.drop(roomID)
 	rm.hub.CloseRoom(roomID)
+	rm.replay.drop(roomID)
+	if err := rm.backplane.DeleteRoom(ctx, roomID); err != nil {
+		log.Error("Failed to delete room from the websocket backplane",
+			logger.Err(err),
+			logger.String("room_id", roomID))
+	}
+	rm.publish(ctx, &ClusterEvent{Kind: eventRoomClosed, RoomID: roomID})
 
-	config.Logger.Info("Game room closed",
+	log.Info("Game room closed",
 		logger.String("room_id", roomID),
 		logger.String("name", room.Name))
 
@@ -172,135 +507,258 @@ func (rm *RoomManager) CloseRoom(roomID string) error {
 }
 
 // InviteToRoom grants users permission to join a room (admin only)
-func (rm *RoomManager) InviteToRoom(roomID string, userIDs []string) error {
-	rm.mu.Lock()
-	defer rm.mu.Unlock()
+func (rm *RoomManager) InviteToRoom(ctx context.Context, roomID string, userIDs []string) error {
+	log := telemetry.Logger(ctx, rm.logger)
 
+	rm.mu.RLock()
 	room, exists := rm.rooms[roomID]
+	active := exists && room.IsActive
+	rm.mu.RUnlock()
+
 	if !exists {
-		return fmt.Errorf("room not found")
+		return errRoomNotFound
+	}
+	if !active {
+		return errRoomInactive
 	}
 
-	if !room.IsActive {
-		return fmt.Errorf("room is not active")
+	// The lobby isn't saved or shared, only game rooms are
+	shared := room.Type == RoomTypeGame
+	if shared {
+		if err := rm.store.Invite(ctx, roomID, userIDs...); err != nil {
+			log.Error("Failed to save invitations",
+				logger.Err(err),
+				logger.String("room_id", roomID))
+			return err
+		}
 	}
 
 	// Add users to allowed list
+	rm.mu.Lock()
 	for _, userID := range userIDs {
 		room.AllowedUsers[userID] = true
 	}
+	info := room.metadata()
+	rm.mu.Unlock()
 
-	config.Logger.Info("Users invited to room",
+	if shared {
+		if err := rm.backplane.SaveRoom(ctx, info); err != nil {
+			log.Error("Failed to save invitations to the websocket backplane",
+				logger.Err(err),
+				logger.String("room_id", roomID))
+			return err
+		}
+		rm.publish(ctx, &ClusterEvent{Kind: eventRoomSaved, Room: info})
+	}
+
+	log.Info("Users invited to room",
 		logger.String("room_id", roomID),
 		logger.Int("user_count", len(userIDs)))
 
 	return nil
 }
 
-// GetRoom returns room information
-func (rm *RoomManager) GetRoom(roomID string) (*RoomInfo, error) {
-	rm.mu.RLock()
-	defer rm.mu.RUnlock()
+// closeSaved marks a room that couldn't be opened as closed in the store
+func (rm *RoomManager) closeSaved(ctx context.Context, roomID string) {
+	if err := rm.store.Close(ctx, roomID); err != nil {
+		telemetry.Logger(ctx, rm.logger).Error("Failed to close saved room",
+			logger.Err(err),
+			logger.String("room_id", roomID))
+	}
+}
 
+// GetRoom returns room information, with the members on every node
+func (rm *RoomManager) GetRoom(ctx context.Context, roomID string) (*RoomInfo, error) {
+	rm.mu.RLock()
 	room, exists := rm.rooms[roomID]
 	if !exists {
-		return nil, fmt.Errorf("room not found")
+		rm.mu.RUnlock()
+		return nil, errRoomNotFound
 	}
+	info := room.metadata()
+	rm.mu.RUnlock()
 
-	// Update player count
-	room.PlayerCount = rm.hub.GetRoomClientCount(roomID)
-
-	return room, nil
+	rm.fillMembers(ctx, info)
+	return info, nil
 }
 
-// GetAllRooms returns all active rooms
-func (rm *RoomManager) GetAllRooms() []*RoomInfo {
+// GetAllRooms returns all active rooms, with the members on every node
+func (rm *RoomManager) GetAllRooms(ctx context.Context) []*RoomInfo {
 	rm.mu.RLock()
-	defer rm.mu.RUnlock()
-
 	rooms := make([]*RoomInfo, 0, len(rm.rooms))
 	for _, room := range rm.rooms {
 		if room.IsActive {
-			// Update player count
-			room.PlayerCount = rm.hub.GetRoomClientCount(room.ID)
-			rooms = append(rooms, room)
+			rooms = append(rooms, room.metadata())
 		}
 	}
+	rm.mu.RUnlock()
 
+	for _, info := range rooms {
+		rm.fillMembers(ctx, info)
+	}
 	return rooms
 }
 
-// JoinRoom adds a client to a room
-func (rm *RoomManager) JoinRoom(roomID, userID, username string) error {
+// fillMembers sets a room's users and player count from the backplane, falling
+// back to this node's own members if it can't be reached
+func (rm *RoomManager) fillMembers(ctx context.Context, info *RoomInfo) {
+	members, err := rm.backplane.Members(ctx, info.ID)
+	if err != nil {
+		telemetry.Logger(ctx, rm.logger).Warn("Failed to load room members from the websocket backplane",
+			logger.Err(err),
+			logger.String("room_id", info.ID))
+		info.PlayerCount = rm.hub.GetRoomClientCount(info.ID)
+		return
+	}
+
+	info.Users = make(map[string]*UserInfo, len(members))
+	for _, member := range members {
+		info.Users[member.UserID] = member
+	}
+	info.PlayerCount = len(members)
+}
+
+// CanJoin reports why a user couldn't join a room, before they connect to it:
+// errRoomNotFound, errRoomInactive, errJoinNotAuthorized or, if it has max
+// players without them, ErrRoomFull. JoinRoom still decides, as the room may
+// fill up in between.
+func (rm *RoomManager) CanJoin(ctx context.Context, roomID, userID string) error {
 	rm.mu.RLock()
 	room, exists := rm.rooms[roomID]
+	var info *RoomInfo
+	if exists {
+		info = room.metadata()
+	}
 	rm.mu.RUnlock()
 
 	if !exists {
-		return fmt.Errorf("room not found")
+		rm.metrics.joinRejected(rejectRoomNotFound)
+		return errRoomNotFound
 	}
-
-	if !room.IsActive {
-		return fmt.Errorf("room is not active")
+	if err := rm.checkJoin(info, userID); err != nil {
+		return err
+	}
+	if info.Type != RoomTypeGame || info.MaxPlayers <= 0 {
+		return nil
 	}
 
-	// Check authorization for game rooms if feature is enabled
-	if config.RoomAuthEnabled && room.Type == RoomTypeGame {
-		if !room.AllowedUsers[userID] {
-			return fmt.Errorf("you are not authorized to join this room")
+	members, err := rm.backplane.Members(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("failed to count room members: %w", err)
+	}
+	for _, member := range members {
+		if member.UserID == userID {
+			return nil
 		}
 	}
+	if len(members) >= info.MaxPlayers {
+		rm.metrics.joinRejected(rejectRoomFull)
+		return ErrRoomFull
+	}
+	return nil
+}
 
-	// Check max players for game rooms
-	if room.Type == RoomTypeGame && room.MaxPlayers > 0 {
-		currentCount := rm.hub.GetRoomClientCount(roomID)
-		if currentCount >= room.MaxPlayers {
-			return fmt.Errorf("room is full")
-		}
+// checkJoin checks that a room is open and, for game rooms when room
+// authorization is enabled, that the user is invited
+func (rm *RoomManager) checkJoin(info *RoomInfo, userID string) error {
+	if !info.IsActive {
+		rm.metrics.joinRejected(rejectRoomInactive)
+		return errRoomInactive
+	}
+	if rm.roomAuthEnabled && info.Type == RoomTypeGame && !info.AllowedUsers[userID] {
+		rm.metrics.joinRejected(rejectNotAuthorized)
+		return errJoinNotAuthorized
 	}
+	return nil
+}
 
-	// Add user to room
-	rm.mu.Lock()
-	room.Users[userID] = &UserInfo{
+// JoinRoom adds a connection to a room. The room's members are told when its
+// user joins; a user already in the room from another connection only has it
+// confirmed on the new one.
+func (rm *RoomManager) JoinRoom(ctx context.Context, roomID string, client *Client) error {
+	log := telemetry.Logger(ctx, rm.logger)
+	userID, username := client.UserID, client.Username
+
+	rm.mu.RLock()
+	room, exists := rm.rooms[roomID]
+	var info *RoomInfo
+	if exists {
+		info = room.metadata()
+	}
+	rm.mu.RUnlock()
+
+	if !exists {
+		rm.metrics.joinRejected(rejectRoomNotFound)
+		return errRoomNotFound
+	}
+	if err := rm.checkJoin(info, userID); err != nil {
+		return err
+	}
+
+	// Take a place in the room on every node, which for game rooms fails once
+	// they have max players; another device of a member takes no place. The
+	// room's members are only told about users not yet in it on any node.
+	member := rm.hub.IsMember(userID, roomID)
+	user := &UserInfo{
 		UserID:   userID,
 		Username: username,
 		JoinedAt: time.Now(),
 	}
-	rm.mu.Unlock()
+	announce := false
+	if !member {
+		limit := 0
+		if info.Type == RoomTypeGame {
+			limit = info.MaxPlayers
+		}
+		var err error
+		announce, err = rm.backplane.AddMember(ctx, roomID, rm.nodeID, user, limit)
+		if errors.Is(err, ErrRoomFull) {
+			rm.metrics.joinRejected(rejectRoomFull)
+			return ErrRoomFull
+		}
+		if err != nil {
+			log.Error("Failed to add room member to the websocket backplane",
+				logger.Err(err),
+				logger.String("user_id", userID),
+				logger.String("room_id", roomID))
+			return fmt.Errorf("failed to count room members: %w", err)
+		}
+	}
 
-	// Try to join the user to the room in the hub
-	err := rm.hub.JoinRoom(userID, roomID)
-	if err != nil && err.Error() == "room not found" {
+	// Try to join the connection to the room in the hub, with the room's
+	// messages queued in order from then on
+	rm.trackDelivery(ctx, roomID)
+	first, err := rm.hub.JoinRoom(client, roomID)
+	if errors.Is(err, errRoomNotInHub) {
 		// Room doesn't exist in hub, create it with our ID
-		createErr := rm.hub.CreateRoomWithID(roomID, &gowebsocket.RoomConfig{
-			Name:       room.Name,
-			MaxClients: room.MaxPlayers,
-			IsPrivate:  false,
-		})
+		createErr := rm.hub.CreateRoomWithID(roomID)
 
 		if createErr != nil {
-			config.Logger.Error("Failed to create room in hub",
+			log.Error("Failed to create room in hub",
 				logger.Err(createErr),
 				logger.String("room_id", roomID))
 			return createErr
 		}
 
-		config.Logger.Info("Created room in hub", logger.String("room_id", roomID))
+		log.Info("Created room in hub", logger.String("room_id", roomID))
 
 		// Try joining again
-		err = rm.hub.JoinRoom(userID, roomID)
+		first, err = rm.hub.JoinRoom(client, roomID)
 	}
 
 	if err != nil {
-		config.Logger.Error("Failed to join hub room",
+		log.Error("Failed to join hub room",
 			logger.Err(err),
 			logger.String("user_id", userID),
 			logger.String("room_id", roomID))
+		// Give the place taken above back
+		if !member {
+			rm.removeMember(ctx, roomID, userID)
+		}
 		return err
 	}
 
-	// Broadcast join message to room
-	rm.hub.BroadcastToRoom(roomID, gowebsocket.Message{
+	joined := Envelope{
 		Type: string(MessageTypeJoin),
 		Data: map[string]interface{}{
 			"room_id":  roomID,
@@ -308,9 +766,30 @@ func (rm *RoomManager) JoinRoom(roomID, userID, username string) error {
 			"username": username,
 			"message":  fmt.Sprintf("%s joined the room", username),
 		},
-	})
+	}
+	if first {
+		rm.mu.Lock()
+		room.Users[userID] = user
+		rm.mu.Unlock()
+	}
+	if !announce {
+		client.enqueue(joined)
+		rm.sendHistory(ctx, roomID, client)
+		log.Info("User joined room from another connection",
+			logger.String("user_id", userID),
+			logger.String("connection_id", client.ID),
+			logger.String("room_id", roomID))
+		return nil
+	}
+	rm.metrics.joined(info.Type)
+
+	// Broadcast join message to room
+	rm.broadcast(ctx, roomID, joined)
 
-	config.Logger.Info("User joined room",
+	// Catch the user up on what was said before
+	rm.sendHistory(ctx, roomID, client)
+
+	log.Info("User joined room",
 		logger.String("user_id", userID),
 		logger.String("username", username),
 		logger.String("room_id", roomID))
@@ -318,20 +797,77 @@ func (rm *RoomManager) JoinRoom(roomID, userID, username string) error {
 	return nil
 }
 
-// LeaveRoom removes a client from a room
-func (rm *RoomManager) LeaveRoom(roomID, userID, username string) {
+// sendHistory sends a room's latest chat messages to a connection
+func (rm *RoomManager) sendHistory(ctx context.Context, roomID string, client *Client) {
+	messages, err := rm.history.ListBefore(ctx, roomID, 0, rm.historyOnJoin)
+	if err != nil {
+		telemetry.Logger(ctx, rm.logger).Error("Failed to load chat history",
+			logger.Err(err),
+			logger.String("room_id", roomID))
+		return
+	}
+
+	client.enqueue(envelope(&Message{
+		Type:   MessageTypeHistory,
+		RoomID: roomID,
+		Data: map[string]interface{}{
+			"messages": messages,
+		},
+	}))
+}
+
+// History returns up to limit of a room's chat messages sent before the
+// message with ID before (0: the latest), oldest first. Game rooms'
+// history is only readable by users allowed to join them.
+func (rm *RoomManager) History(ctx context.Context, roomID, userID string, before int64, limit int) ([]models.ChatMessage, error) {
+	rm.mu.RLock()
+	room, exists := rm.rooms[roomID]
+	allowed := exists && (!rm.roomAuthEnabled || room.Type != RoomTypeGame || room.AllowedUsers[userID])
+	rm.mu.RUnlock()
+
+	if !exists {
+		return nil, errRoomNotFound
+	}
+	if !allowed {
+		return nil, errNotAuthorized
+	}
+	return rm.history.ListBefore(ctx, roomID, before, limit)
+}
+
+// LeaveRoom removes a user from a room on this node, on all their
+// connections. The room's members are told once the user is in it on no
+// node any more. It returns errNotInRoom if the user isn't in it here.
+func (rm *RoomManager) LeaveRoom(ctx context.Context, roomID, userID, username string) error {
+	log := telemetry.Logger(ctx, rm.logger)
+
 	// Remove user from room
 	rm.mu.Lock()
-	if room, exists := rm.rooms[roomID]; exists {
+	room, exists := rm.rooms[roomID]
+	var roomType RoomType
+	member := false
+	if exists {
+		roomType = room.Type
+		_, member = room.Users[userID]
 		delete(room.Users, userID)
 	}
 	rm.mu.Unlock()
+	if !member {
+		return errNotInRoom
+	}
 
 	// Leave the room in the hub
+	rm.stopTyping(ctx, typingKey{userID: userID, roomID: roomID})
 	rm.hub.LeaveRoom(userID, roomID)
+	if !rm.removeMember(ctx, roomID, userID) {
+		log.Info("User left room from this node",
+			logger.String("user_id", userID),
+			logger.String("room_id", roomID))
+		return nil
+	}
+	rm.metrics.left(roomType)
 
 	// Broadcast leave message to room
-	rm.hub.BroadcastToRoom(roomID, gowebsocket.Message{
+	rm.broadcast(ctx, roomID, Envelope{
 		Type: string(MessageTypeLeave),
 		Data: map[string]interface{}{
 			"room_id":  roomID,
@@ -341,40 +877,101 @@ func (rm *RoomManager) LeaveRoom(roomID, userID, username string) {
 		},
 	})
 
-	config.Logger.Info("User left room",
+	log.Info("User left room",
 		logger.String("user_id", userID),
 		logger.String("username", username),
 		logger.String("room_id", roomID))
+	return nil
 }
 
-// BroadcastToRoom sends a message to all clients in a room
-func (rm *RoomManager) BroadcastToRoom(roomID string, message *Message) {
-	message.Timestamp = time.Now()
+// handleConnect sets up a user's new connection: it joins the connection to
+// the room they asked for, then tells the connection its ID, records the user
+// online and hands over the direct messages received while they were offline.
+// If the room can't be joined, or setting up panics, the connection is told
+// why and refused, without the user ever having been online through it.
+func (rm *RoomManager) handleConnect(ctx context.Context, client *Client, info ConnectionInfo) (err error) {
+	log := telemetry.Logger(ctx, rm.logger)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("Panic in connect handler",
+				logger.String("error", fmt.Sprint(r)),
+				logger.String("user_id", client.UserID),
+				logger.String("room_id", info.RoomID))
+			err = fmt.Errorf("connect handler panicked: %v", r)
+		}
+	}()
 
-	// Build data map
-	data := map[string]interface{}{
-		"room_id":   message.RoomID,
-		"user_id":   message.UserID,
-		"username":  message.Username,
-		"content":   message.Content,
-		"timestamp": message.Timestamp,
+	if err := rm.JoinRoom(ctx, info.RoomID, client); err != nil {
+		log.Warn("Refused connection that couldn't join its room",
+			logger.Err(err),
+			logger.String("user_id", client.UserID),
+			logger.String("room_id", info.RoomID))
+		rm.replyError(ctx, client, string(MessageTypeJoin), err)
+		return err
 	}
+	client.enqueue(reply(MessageTypeConnected, ConnectedReply{
+		ConnectionID: client.ID,
+		UserID:       client.UserID,
+		Protocol:     client.Protocol,
+		Format:       client.Format,
+	}))
+	rm.connected(ctx, client)
+	rm.deliverPending(ctx, client.UserID)
+	return nil
+}
 
-	// Merge message.Data if exists
-	if message.Data != nil {
-		for k, v := range message.Data {
-			data[k] = v
+// handleDisconnect makes a user whose connection closed leave the rooms none
+// of their other connections are in, telling the other members, and records
+// them offline once their last connection closed (or with the status of the
+// remaining ones)
+func (rm *RoomManager) handleDisconnect(client *Client, roomIDs []string, last bool) {
+	ctx := context.Background()
+	for _, roomID := range roomIDs {
+		if err := rm.LeaveRoom(ctx, roomID, client.UserID, client.Username); err != nil {
+			telemetry.Logger(ctx, rm.logger).Warn("Failed to leave room on disconnecting",
+				logger.Err(err),
+				logger.String("user_id", client.UserID),
+				logger.String("room_id", roomID))
 		}
 	}
+	if last {
+		rm.disconnected(ctx, client.UserID, roomIDs)
+		return
+	}
+	if err := rm.refreshStatus(ctx, client.UserID); err != nil {
+		telemetry.Logger(ctx, rm.logger).Error("Failed to record presence",
+			logger.Err(err),
+			logger.String("user_id", client.UserID))
+	}
+}
 
-	rm.hub.BroadcastToRoom(roomID, gowebsocket.Message{
-		Type: string(message.Type),
-		Data: data,
-	})
+// removeMember removes a user's membership through this node from a room's
+// members on the backplane, and reports whether that made them leave the
+// room. If the backplane can't be reached they are taken to have left.
+func (rm *RoomManager) removeMember(ctx context.Context, roomID, userID string) bool {
+	left, err := rm.backplane.RemoveMember(ctx, roomID, rm.nodeID, userID)
+	if err != nil {
+		telemetry.Logger(ctx, rm.logger).Error("Failed to remove room member from the websocket backplane",
+			logger.Err(err),
+			logger.String("user_id", userID),
+			logger.String("room_id", roomID))
+		return true
+	}
+	return left
 }
 
-// SendToClient sends a message to a specific client
+// BroadcastToRoom sends a message to all clients in a room, on every node
+func (rm *RoomManager) BroadcastToRoom(roomID string, message *Message) {
+	rm.broadcast(context.Background(), roomID, envelope(message))
+}
+
+// SendToClient sends a message to a specific client, on whichever node it is connected to
 func (rm *RoomManager) SendToClient(clientID string, message *Message) {
+	rm.send(context.Background(), clientID, envelope(message))
+}
+
+// envelope stamps a message and flattens it into the wire format
+func envelope(message *Message) Envelope {
 	message.Timestamp = time.Now()
 
 	// Build data map
@@ -393,157 +990,188 @@ func (rm *RoomManager) SendToClient(clientID string, message *Message) {
 		}
 	}
 
-	rm.hub.SendToUser(clientID, gowebsocket.Message{
+	return Envelope{
 		Type: string(message.Type),
 		Data: data,
-	})
+	}
 }
 
-// handleMessage processes incoming WebSocket messages
-func (rm *RoomManager) handleMessage(client *gowebsocket.Client, msg gowebsocket.Message) {
-	config.Logger.Info("WebSocket message received",
-		logger.String("user_id", client.UserID),
-		logger.String("type", msg.Type))
-
-	// Extract message data
-	data := msg.Data
-
-	switch msg.Type {
-	case "join":
-		// Handle room join request
-		roomID, _ := data["room_id"].(string)
-		if roomID == "" {
-			rm.SendToClient(client.UserID, &Message{
-				Type: MessageTypeError,
-				Data: map[string]interface{}{
-					"message": "Room ID is required",
-				},
-			})
-			return
-		}
+// broadcast numbers msg in the room's sequence, delivers it to the room's
+// members on this node and publishes it for the others
+func (rm *RoomManager) broadcast(ctx context.Context, roomID string, msg Envelope) {
+	if rm.sequence(ctx, roomID, &msg) {
+		rm.delivery.add(roomID, msg)
+	} else {
+		rm.hub.BroadcastToRoom(roomID, msg)
+	}
+	rm.publish(ctx, &ClusterEvent{Kind: eventBroadcast, RoomID: roomID, Message: &msg})
+}
 
-		// Get username
-		username := client.UserID
-		rm.mu.RLock()
-		for _, room := range rm.rooms {
-			if user, exists := room.Users[client.UserID]; exists {
-				username = user.Username
-				break
+// send delivers msg to a user connected to this node and publishes it for the others
+func (rm *RoomManager) send(ctx context.Context, userID string, msg Envelope) {
+	rm.hub.SendToUser(userID, msg)
+	rm.publish(ctx, &ClusterEvent{Kind: eventSend, UserID: userID, Message: &msg})
+}
+
+// publish sends an event to the other nodes. Failures are logged: the local
+// part of the operation has already happened.
+func (rm *RoomManager) publish(ctx context.Context, event *ClusterEvent) {
+	event.Origin = rm.nodeID
+	if err := rm.backplane.Publish(ctx, event); err != nil {
+		telemetry.Logger(ctx, rm.logger).Error("Failed to publish to the websocket backplane",
+			logger.Err(err),
+			logger.String("kind", event.Kind))
+	}
+}
+
+// handleClusterEvent applies an event published by another node
+func (rm *RoomManager) handleClusterEvent(event *ClusterEvent) {
+	if event.Origin == rm.nodeID {
+		return
+	}
+
+	switch event.Kind {
+	case eventBroadcast:
+		if event.Message != nil {
+			if event.Message.Seq > 0 {
+				rm.replay.add(event.RoomID, *event.Message)
+				rm.delivery.add(event.RoomID, *event.Message)
+			} else {
+				rm.hub.BroadcastToRoom(event.RoomID, *event.Message)
 			}
 		}
-		rm.mu.RUnlock()
 
-		// Join the room
-		if err := rm.JoinRoom(roomID, client.UserID, username); err != nil {
-			rm.SendToClient(client.UserID, &Message{
-				Type: MessageTypeError,
-				Data: map[string]interface{}{
-					"message": err.Error(),
-				},
-			})
-			return
+	case eventSend:
+		if event.Message != nil {
+			rm.hub.SendToUser(event.UserID, *event.Message)
 		}
 
-	case "chat":
-		// Handle chat message
-		roomID, _ := data["room_id"].(string)
-		content, _ := data["content"].(string)
-
-		if roomID == "" || content == "" {
-			rm.SendToClient(client.UserID, &Message{
-				Type: MessageTypeError,
-				Data: map[string]interface{}{
-					"message": "Invalid chat message format",
-				},
-			})
-			return
+	case eventRoomSaved:
+		if event.Room != nil {
+			rm.applyRoom(event.Room)
 		}
 
-		// Get user info from room
-		rm.mu.RLock()
-		room, exists := rm.rooms[roomID]
-		rm.mu.RUnlock()
+	case eventDirect:
+		if event.Message != nil {
+			rm.deliverDirect(context.Background(), event.UserID, event.MessageID, *event.Message)
+		}
 
-		if !exists {
-			rm.SendToClient(client.UserID, &Message{
-				Type: MessageTypeError,
-				Data: map[string]interface{}{
-					"message": "Room not found",
-				},
-			})
-			return
+	case eventRoomClosed:
+		rm.mu.Lock()
+		if room, exists := rm.rooms[event.RoomID]; exists && room.IsActive {
+			room.IsActive = false
+			rm.metrics.roomClosed(room.Type)
 		}
+		rm.mu.Unlock()
+		rm.delivery.drop(event.RoomID)
+		rm.hub.CloseRoom(event.RoomID)
+		rm.replay.drop(event.RoomID)
+
+	default:
+		rm.logger.Warn("Unknown backplane event", logger.String("kind", event.Kind))
+	}
+}
+
+// applyRoom adds a game room created on another node, or merges its new invitations
+func (rm *RoomManager) applyRoom(room *RoomInfo) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
 
-		// Get username
-		username := client.UserID
-		for userID, user := range room.Users {
-			if userID == client.UserID {
-				username = user.Username
-				break
+	if existing, exists := rm.rooms[room.ID]; exists {
+		// Invitations are never revoked, so merging can't lose a concurrent one
+		for userID, allowed := range room.AllowedUsers {
+			if allowed {
+				existing.AllowedUsers[userID] = true
 			}
 		}
+		return
+	}
+	if !room.IsActive {
+		return
+	}
 
-		// Broadcast chat message to room
-		rm.BroadcastToRoom(roomID, &Message{
-			Type:     MessageTypeChat,
-			RoomID:   roomID,
-			UserID:   client.UserID,
-			Username: username,
-			Content:  content,
-		})
-
-	case "create_room":
-		// Handle room creation (admin only)
-		roomName, _ := data["name"].(string)
-		if roomName == "" {
-			roomName = "Game Room"
-		}
+	info := room.metadata()
+	info.Users = make(map[string]*UserInfo)
+	if info.AllowedUsers == nil {
+		info.AllowedUsers = make(map[string]bool)
+	}
+	rm.rooms[room.ID] = info
 
-		room, err := rm.CreateRoom(roomName, client.UserID, 10)
-		if err != nil {
-			rm.SendToClient(client.UserID, &Message{
-				Type: MessageTypeError,
-				Data: map[string]interface{}{
-					"message": err.Error(),
-				},
-			})
-			return
-		}
+	if err := rm.hub.CreateRoomWithID(room.ID); err != nil {
+		rm.logger.Error("Failed to create room in hub",
+			logger.Err(err),
+			logger.String("room_id", room.ID))
+	}
+	rm.metrics.roomOpened(info.Type)
+}
 
-		// Notify about room creation
-		rm.BroadcastToRoom(LobbyRoomID, &Message{
-			Type: MessageTypeRoomCreated,
-			Data: map[string]interface{}{
-				"room_id": room.ID,
-				"name":    room.Name,
-			},
-		})
-
-		// Also send to creator so they can auto-join
-		rm.SendToClient(client.UserID, &Message{
-			Type: MessageTypeRoomCreated,
-			Data: map[string]interface{}{
-				"room_id": room.ID,
-				"name":    room.Name,
-			},
-		})
-
-	case "close_room":
-		// Handle room closure (admin only)
-		roomID, _ := data["room_id"].(string)
-
-		if err := rm.CloseRoom(roomID); err != nil {
-			rm.SendToClient(client.UserID, &Message{
-				Type: MessageTypeError,
-				Data: map[string]interface{}{
-					"message": err.Error(),
-				},
-			})
-		}
+// Handle registers the handler for an inbound message type, wrapped in
+// middleware that only applies to it, after the manager's own: metrics,
+// logging, panic recovery and the rate limit. Register handlers before
+// clients connect; a type can only have one.
+func (rm *RoomManager) Handle(msgType MessageType, handler HandlerFunc, middleware ...Middleware) {
+	rm.registry.Handle(msgType, handler, middleware...)
+}
 
-	default:
-		config.Logger.Warn("Unknown message type",
-			logger.String("type", msg.Type),
-			logger.String("user_id", client.UserID))
+// Use adds middleware run for every inbound message, after the manager's own
+func (rm *RoomManager) Use(middleware ...Middleware) {
+	rm.registry.Use(middleware...)
+}
+
+// handleMessage passes an incoming WebSocket message to the handler of its
+// type, replying with an error if it fails
+func (rm *RoomManager) handleMessage(client *Client, msg Envelope) {
+	// Each message is its own trace, as the upgrade request that opened the connection has long finished
+	ctx, span := rm.tracer.Start(context.Background(), "websocket.message",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("websocket.message_type", msg.Type),
+			attribute.String("enduser.id", client.UserID),
+		))
+	defer span.End()
+
+	// A message may carry its own request_id for correlation; otherwise one is generated
+	requestID, _ := msg.Data["request_id"].(string)
+	requestID = telemetry.NewRequestID(requestID)
+	ctx = telemetry.WithRequestID(ctx, requestID)
+	span.SetAttributes(attribute.String("websocket.request_id", requestID))
+
+	err := rm.registry.Dispatch(ctx, &Request{
+		Client:    client,
+		Type:      msg.Type,
+		Data:      msg.Data,
+		RequestID: requestID,
+	})
+	if err != nil {
+		rm.replyError(ctx, client, msg.Type, err)
 	}
 }
+
+// replyError replies to a client whose message of type msgType failed with
+// err. An *Error is sent as it is; other unexpected errors are logged, and
+// the client only learns that the server failed.
+func (rm *RoomManager) replyError(ctx context.Context, client *Client, msgType string, err error) {
+	code := errorCode(err)
+	message := err.Error()
+	var replyErr *Error
+	if code == ErrorCodeInternal && !errors.As(err, &replyErr) {
+		telemetry.Logger(ctx, rm.logger).Error("Failed to handle WebSocket message",
+			logger.Err(err),
+			logger.String("user_id", client.UserID),
+			logger.String("type", msgType))
+		message = "internal server error"
+	}
+	rm.sendError(ctx, client, msgType, code, message)
+}
+
+// sendError replies to a client with an error message carrying its code, the
+// offending message type and request ID, and marks the message's span as failed
+func (rm *RoomManager) sendError(ctx context.Context, client *Client, msgType string, code ErrorCode, message string) {
+	trace.SpanFromContext(ctx).SetStatus(codes.Error, message)
+	client.enqueue(reply(MessageTypeError, ErrorReply{
+		Code:      code,
+		Message:   message,
+		Type:      msgType,
+		RequestID: telemetry.RequestIDFromContext(ctx),
+	}))
+}
@@ -0,0 +1,210 @@
+package websocket
+
+import (
+	"context"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+)
+
+// registerHandlers registers the handlers of the built-in message types
+func (rm *RoomManager) registerHandlers() {
+	rm.registry.Handle(MessageTypeJoin, rm.handleJoin)
+	rm.registry.Handle(MessageTypeChat, rm.handleChat)
+	rm.registry.Handle(MessageTypeDirect, rm.handleDirect)
+	rm.registry.Handle(MessageTypeDirectRead, rm.handleDirectRead)
+	rm.registry.Handle(MessageTypePresence, rm.handlePresence)
+	rm.registry.Handle(MessageTypeTypingStart, rm.handleTyping)
+	rm.registry.Handle(MessageTypeTypingStop, rm.handleTyping)
+	rm.registry.Handle(MessageTypeAck, rm.handleAck)
+	rm.registry.Handle(MessageTypeResume, rm.handleResume)
+	rm.registry.Handle(MessageTypeCreateRoom, rm.handleCreateRoom, RequireRole(models.RoleAdmin))
+	rm.registry.Handle(MessageTypeCloseRoom, rm.handleCloseRoom, RequireRole(models.RoleAdmin))
+}
+
+// handleJoin joins the sender to a room
+func (rm *RoomManager) handleJoin(ctx context.Context, req *Request) error {
+	var join JoinRequest
+	if err := req.Decode(&join); err != nil {
+		return err
+	}
+	return rm.JoinRoom(ctx, join.RoomID, req.Client)
+}
+
+// handleChat stores a chat message and broadcasts it to its room
+func (rm *RoomManager) handleChat(ctx context.Context, req *Request) error {
+	var chat ChatRequest
+	if err := req.Decode(&chat); err != nil {
+		return err
+	}
+	userID := req.Client.UserID
+
+	// Get user info from room
+	username := userID
+	rm.mu.RLock()
+	room, exists := rm.rooms[chat.RoomID]
+	active := exists && room.IsActive
+	if exists {
+		if user, joined := room.Users[userID]; joined {
+			username = user.Username
+		}
+	}
+	rm.mu.RUnlock()
+
+	// Only members may post, and only while the room is open
+	if !exists {
+		return errRoomNotFound
+	}
+	if !active {
+		return errRoomInactive
+	}
+	if !rm.hub.IsMember(userID, chat.RoomID) {
+		return errNotInRoom
+	}
+
+	// Keep the message for users who join later; it is still delivered if that fails
+	record := &models.ChatMessage{
+		RoomID:   chat.RoomID,
+		UserID:   userID,
+		Username: username,
+		Content:  chat.Content,
+	}
+	if err := rm.history.Append(ctx, record); err != nil {
+		telemetry.Logger(ctx, rm.logger).Error("Failed to save chat message",
+			logger.Err(err),
+			logger.String("room_id", chat.RoomID))
+	}
+
+	// Sending ends the sender's typing indicator, then broadcast chat message to room
+	rm.stopTyping(ctx, typingKey{userID: userID, roomID: chat.RoomID})
+	rm.BroadcastToRoom(chat.RoomID, &Message{
+		Type:     MessageTypeChat,
+		RoomID:   chat.RoomID,
+		UserID:   userID,
+		Username: username,
+		Content:  chat.Content,
+		Data: map[string]interface{}{
+			"message_id": record.ID,
+		},
+	})
+	return nil
+}
+
+// handleDirect sends a direct message to another user and confirms it to the
+// sender, with the ID read receipts will refer to
+func (rm *RoomManager) handleDirect(ctx context.Context, req *Request) error {
+	var direct DirectRequest
+	if err := req.Decode(&direct); err != nil {
+		return err
+	}
+
+	message, err := rm.SendDirect(ctx, req.Client.UserID, direct.To, direct.Content)
+	if err != nil {
+		return err
+	}
+	req.Client.enqueue(reply(MessageTypeDirectSent, DirectSentReply{
+		MessageID: message.ID,
+		To:        direct.To,
+		RequestID: req.RequestID,
+	}))
+	return nil
+}
+
+// handleDirectRead marks the direct messages from one user read
+func (rm *RoomManager) handleDirectRead(ctx context.Context, req *Request) error {
+	var read DirectReadRequest
+	if err := req.Decode(&read); err != nil {
+		return err
+	}
+	return rm.MarkDirectRead(ctx, req.Client.UserID, read.From, read.MessageID)
+}
+
+// handlePresence sets the status of the sender's connection
+func (rm *RoomManager) handlePresence(ctx context.Context, req *Request) error {
+	var presence PresenceRequest
+	if err := req.Decode(&presence); err != nil {
+		return err
+	}
+	return rm.SetStatus(ctx, req.Client, presence.Status)
+}
+
+// handleTyping starts or stops a typing indicator in a room or to another user
+func (rm *RoomManager) handleTyping(ctx context.Context, req *Request) error {
+	var typing TypingRequest
+	if err := req.Decode(&typing); err != nil {
+		return err
+	}
+	if req.Type == string(MessageTypeTypingStart) {
+		return rm.StartTyping(ctx, req.Client.UserID, typing.RoomID, typing.To)
+	}
+	return rm.StopTyping(ctx, req.Client.UserID, typing.RoomID, typing.To)
+}
+
+// handleAck records that the sender received a room's messages up to a sequence number
+func (rm *RoomManager) handleAck(ctx context.Context, req *Request) error {
+	var ack AckRequest
+	if err := req.Decode(&ack); err != nil {
+		return err
+	}
+	return rm.Ack(ctx, req.Client.UserID, ack.RoomID, ack.Seq)
+}
+
+// handleResume sends a reconnected client the room messages it missed;
+// without a last_seq, it resumes from its last ack
+func (rm *RoomManager) handleResume(ctx context.Context, req *Request) error {
+	var resume ResumeRequest
+	if err := req.Decode(&resume); err != nil {
+		return err
+	}
+
+	lastSeq := int64(-1)
+	if resume.LastSeq != nil {
+		lastSeq = *resume.LastSeq
+	}
+	return rm.Resume(ctx, req.Client, resume.RoomID, lastSeq)
+}
+
+// handleCreateRoom creates a game room and announces it in the lobby
+func (rm *RoomManager) handleCreateRoom(ctx context.Context, req *Request) error {
+	var create NewRoomRequest
+	if err := req.Decode(&create); err != nil {
+		return err
+	}
+	if create.Name == "" {
+		create.Name = "Game Room"
+	}
+
+	room, err := rm.CreateRoom(ctx, create.Name, req.Client.UserID, 10)
+	if err != nil {
+		return err
+	}
+
+	// Notify about room creation
+	rm.BroadcastToRoom(LobbyRoomID, &Message{
+		Type: MessageTypeRoomCreated,
+		Data: map[string]interface{}{
+			"room_id": room.ID,
+			"name":    room.Name,
+		},
+	})
+
+	// Also send to creator so they can auto-join
+	rm.SendToClient(req.Client.UserID, &Message{
+		Type: MessageTypeRoomCreated,
+		Data: map[string]interface{}{
+			"room_id": room.ID,
+			"name":    room.Name,
+		},
+	})
+	return nil
+}
+
+// handleCloseRoom closes a game room
+func (rm *RoomManager) handleCloseRoom(ctx context.Context, req *Request) error {
+	var closeRoom CloseRoomRequest
+	if err := req.Decode(&closeRoom); err != nil {
+		return err
+	}
+	return rm.CloseRoom(ctx, closeRoom.RoomID)
+}
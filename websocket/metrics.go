@@ -0,0 +1,179 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Rejected join reasons, used as the "reason" label
+const (
+	rejectRoomNotFound  = "room_not_found"
+	rejectRoomInactive  = "room_inactive"
+	rejectNotAuthorized = "not_authorized"
+	rejectRoomFull      = "room_full"
+)
+
+// messageBuckets suits message handling, which is mostly much faster than HTTP requests
+var messageBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+// Metrics holds the WebSocket collectors. A nil *Metrics records nothing.
+type Metrics struct {
+	clients       prometheus.Gauge
+	rooms         *prometheus.GaugeVec
+	roomUsers     *prometheus.GaugeVec
+	messagesIn    *prometheus.CounterVec
+	messagesOut   *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	errors        *prometheus.CounterVec
+	joins         *prometheus.CounterVec
+	leaves        *prometheus.CounterVec
+	rejectedJoins *prometheus.CounterVec
+	drops         *prometheus.CounterVec
+}
+
+// NewMetrics creates the WebSocket collectors and registers them (e.g. on the /metrics registry)
+func NewMetrics(registry prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		clients: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "websocket_connected_clients",
+			Help: "Open WebSocket connections",
+		}),
+		rooms: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "websocket_active_rooms",
+			Help: "Active rooms by type",
+		}, []string{"type"}),
+		roomUsers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "websocket_room_users",
+			Help: "Users currently in each room",
+		}, []string{"room_id"}),
+		messagesIn: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "websocket_messages_received_total",
+			Help: "Messages received from clients by type",
+		}, []string{"type"}),
+		messagesOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "websocket_messages_sent_total",
+			Help: "Messages queued to clients by type (one per recipient)",
+		}, []string{"type"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "websocket_message_duration_seconds",
+			Help:    "Time spent handling messages from clients by type",
+			Buckets: messageBuckets,
+		}, []string{"type"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "websocket_message_errors_total",
+			Help: "Messages from clients answered with an error by type and error code",
+		}, []string{"type", "code"}),
+		joins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "websocket_room_joins_total",
+			Help: "Successful room joins by room type",
+		}, []string{"room_type"}),
+		leaves: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "websocket_room_leaves_total",
+			Help: "Room leaves by room type",
+		}, []string{"room_type"}),
+		rejectedJoins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "websocket_room_joins_rejected_total",
+			Help: "Rejected room joins by reason",
+		}, []string{"reason"}),
+		drops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "websocket_send_buffer_drops_total",
+			Help: "Messages dropped because a client's send buffer was full, by type",
+		}, []string{"type"}),
+	}
+
+	for _, collector := range []prometheus.Collector{
+		m.clients, m.rooms, m.roomUsers, m.messagesIn, m.messagesOut, m.duration, m.errors,
+		m.joins, m.leaves, m.rejectedJoins, m.drops,
+	} {
+		if err := registry.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *Metrics) clientConnected() {
+	if m != nil {
+		m.clients.Inc()
+	}
+}
+
+func (m *Metrics) clientDisconnected() {
+	if m != nil {
+		m.clients.Dec()
+	}
+}
+
+func (m *Metrics) roomOpened(roomType RoomType) {
+	if m != nil {
+		m.rooms.WithLabelValues(string(roomType)).Inc()
+	}
+}
+
+func (m *Metrics) roomClosed(roomType RoomType) {
+	if m != nil {
+		m.rooms.WithLabelValues(string(roomType)).Dec()
+	}
+}
+
+func (m *Metrics) setRoomUsers(roomID string, count int) {
+	if m != nil {
+		m.roomUsers.WithLabelValues(roomID).Set(float64(count))
+	}
+}
+
+// deleteRoomUsers drops a closed room's series so closed rooms don't accumulate
+func (m *Metrics) deleteRoomUsers(roomID string) {
+	if m != nil {
+		m.roomUsers.DeleteLabelValues(roomID)
+	}
+}
+
+func (m *Metrics) messageReceived(msgType string) {
+	if m != nil {
+		m.messagesIn.WithLabelValues(msgType).Inc()
+	}
+}
+
+func (m *Metrics) messageHandled(msgType string, duration time.Duration) {
+	if m != nil {
+		m.duration.WithLabelValues(msgType).Observe(duration.Seconds())
+	}
+}
+
+func (m *Metrics) messageFailed(msgType string, code ErrorCode) {
+	if m != nil {
+		m.errors.WithLabelValues(msgType, string(code)).Inc()
+	}
+}
+
+func (m *Metrics) messageSent(msgType string) {
+	if m != nil {
+		m.messagesOut.WithLabelValues(msgType).Inc()
+	}
+}
+
+func (m *Metrics) messageDropped(msgType string) {
+	if m != nil {
+		m.drops.WithLabelValues(msgType).Inc()
+	}
+}
+
+func (m *Metrics) joined(roomType RoomType) {
+	if m != nil {
+		m.joins.WithLabelValues(string(roomType)).Inc()
+	}
+}
+
+func (m *Metrics) left(roomType RoomType) {
+	if m != nil {
+		m.leaves.WithLabelValues(string(roomType)).Inc()
+	}
+}
+
+func (m *Metrics) joinRejected(reason string) {
+	if m != nil {
+		m.rejectedJoins.WithLabelValues(reason).Inc()
+	}
+}
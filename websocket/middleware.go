@@ -0,0 +1,176 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+)
+
+// Message rate limit defaults
+const (
+	// defaultMessageRate is how many messages per second a user may send on average
+	defaultMessageRate = 20
+
+	// defaultMessageBurst is how many messages a user may send at once
+	defaultMessageBurst = 40
+
+	// rateLimitSweepInterval is how often users who stopped sending are forgotten
+	rateLimitSweepInterval = time.Minute
+)
+
+// RequireRole rejects messages from users without role, as forbidden
+func RequireRole(role models.UserRole) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req *Request) error {
+			if req.Client.Role != role {
+				return NewError(ErrorCodeForbidden, strings.ToLower(string(role))+" role required")
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// RateLimit lets each user send perSecond messages on average, in bursts of up
+// to burst, across their connections. Messages over the limit are rejected as
+// rate_limited and not handled.
+func RateLimit(perSecond float64, burst int) Middleware {
+	limiter := &rateLimiter{
+		rate:    perSecond,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req *Request) error {
+			if !limiter.allow(req.Client.UserID, time.Now()) {
+				return NewError(ErrorCodeRateLimited, "too many messages, slow down")
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// rateLimiter keeps a token bucket per user
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// tokenBucket holds a user's unspent messages as of updated
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// allow spends one of a user's tokens, reporting whether they had one left
+func (l *rateLimiter) allow(userID string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, exists := l.buckets[userID]
+	if !exists {
+		bucket = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[userID] = bucket
+	}
+	bucket.tokens = l.refill(bucket, now)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// refill returns a bucket's tokens at now
+func (l *rateLimiter) refill(bucket *tokenBucket, now time.Time) float64 {
+	tokens := bucket.tokens + now.Sub(bucket.updated).Seconds()*l.rate
+	if tokens > l.burst {
+		return l.burst
+	}
+	return tokens
+}
+
+// sweep forgets the buckets that filled up again: a new one is the same
+func (l *rateLimiter) sweep(now time.Time) {
+	for userID, bucket := range l.buckets {
+		if l.refill(bucket, now) >= l.burst {
+			delete(l.buckets, userID)
+		}
+	}
+	l.lastSweep = now
+}
+
+// recoverPanics turns a handler's panic into an internal error, so one bad
+// message doesn't take down the connection's reader, or the server
+func (rm *RoomManager) recoverPanics(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req *Request) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				telemetry.Logger(ctx, rm.logger).Error("Panic in WebSocket message handler",
+					logger.String("error", fmt.Sprint(r)),
+					logger.String("stack", string(debug.Stack())),
+					logger.String("user_id", req.Client.UserID),
+					logger.String("type", req.Type))
+				err = NewError(ErrorCodeInternal, "internal server error")
+			}
+		}()
+		return next(ctx, req)
+	}
+}
+
+// logMessages logs each message, and why it was rejected unless the server failed
+// (those are logged with their cause when replying)
+func (rm *RoomManager) logMessages(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req *Request) error {
+		log := telemetry.Logger(ctx, rm.logger)
+		log.Info("WebSocket message received",
+			logger.String("user_id", req.Client.UserID),
+			logger.String("type", req.Type))
+
+		err := next(ctx, req)
+		if err != nil {
+			if code := errorCode(err); code != ErrorCodeInternal {
+				log.Warn("WebSocket message rejected",
+					logger.String("user_id", req.Client.UserID),
+					logger.String("type", req.Type),
+					logger.String("code", string(code)),
+					logger.String("error", err.Error()))
+			}
+		}
+		return err
+	}
+}
+
+// instrument counts and times each message, and counts rejections by code
+func (rm *RoomManager) instrument(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req *Request) error {
+		// Only registered types are labels, so clients can't create arbitrary label values
+		label := req.Type
+		if !rm.registry.Has(req.Type) {
+			label = "unknown"
+		}
+		rm.metrics.messageReceived(label)
+
+		start := time.Now()
+		err := next(ctx, req)
+		rm.metrics.messageHandled(label, time.Since(start))
+		if err != nil {
+			rm.metrics.messageFailed(label, errorCode(err))
+		}
+		return err
+	}
+}
@@ -0,0 +1,256 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+)
+
+// defaultTypingTimeout is how long a typing indicator lasts without a
+// typing_stop or another typing_start
+const defaultTypingTimeout = 5 * time.Second
+
+// Errors returned for presence and typing messages
+var (
+	errInvalidStatus = errors.New("status must be online or away")
+	errTypingTarget  = errors.New("typing needs either a room_id or a to")
+	errNotInRoom     = errors.New("you are not in this room")
+)
+
+// FriendSource lists the users who follow a user's presence, besides the
+// members of the rooms the user is in
+type FriendSource interface {
+	Friends(ctx context.Context, userID string) ([]string, error)
+}
+
+// typingKey identifies a user typing either in a room or to another user
+type typingKey struct {
+	userID string
+	roomID string
+	to     string
+}
+
+// typingIndicators tracks who is typing on this node, so an indicator whose
+// client never stops it expires on its own
+type typingIndicators struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	timers  map[typingKey]*time.Timer
+}
+
+// Presence returns each user's status across all their connections, in the order given
+func (rm *RoomManager) Presence(ctx context.Context, userIDs ...string) ([]Presence, error) {
+	return rm.backplane.Presence(ctx, userIDs...)
+}
+
+// SetStatus sets a connection's status to online or away. Its user is online
+// on this node while any of their connections here is.
+func (rm *RoomManager) SetStatus(ctx context.Context, client *Client, status PresenceStatus) error {
+	if status != PresenceOnline && status != PresenceAway {
+		return errInvalidStatus
+	}
+	rm.hub.SetStatus(client, status)
+	return rm.refreshStatus(ctx, client.UserID)
+}
+
+// refreshStatus records a user's status across their connections to this node
+func (rm *RoomManager) refreshStatus(ctx context.Context, userID string) error {
+	return rm.updatePresence(ctx, userID, rm.hub.UserRooms(userID), func() error {
+		return rm.backplane.SetPresence(ctx, userID, rm.nodeID, rm.hub.UserStatus(userID))
+	})
+}
+
+// connected marks a new connection, and so its user, as online
+func (rm *RoomManager) connected(ctx context.Context, client *Client) {
+	if err := rm.SetStatus(ctx, client, PresenceOnline); err != nil {
+		telemetry.Logger(ctx, rm.logger).Error("Failed to record presence",
+			logger.Err(err),
+			logger.String("user_id", client.UserID))
+	}
+}
+
+// disconnected stops a user's typing indicators and records that their
+// connection to this node closed, telling the rooms they were in
+func (rm *RoomManager) disconnected(ctx context.Context, userID string, roomIDs []string) {
+	rm.stopAllTyping(ctx, userID)
+
+	err := rm.updatePresence(ctx, userID, roomIDs, func() error {
+		return rm.backplane.RemovePresence(ctx, userID, rm.nodeID)
+	})
+	if err != nil {
+		telemetry.Logger(ctx, rm.logger).Error("Failed to record presence",
+			logger.Err(err),
+			logger.String("user_id", userID))
+	}
+}
+
+// updatePresence applies change to a user's presence and, if their overall
+// status changed, announces it to roomIDs and the user's friends
+func (rm *RoomManager) updatePresence(ctx context.Context, userID string, roomIDs []string, change func() error) error {
+	before, err := rm.backplane.Presence(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	after, err := rm.backplane.Presence(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if before[0].Status == after[0].Status {
+		return nil
+	}
+
+	msg := envelope(&Message{
+		Type:     MessageTypePresence,
+		UserID:   userID,
+		Username: rm.username(userID),
+		Data: map[string]interface{}{
+			"status":    after[0].Status,
+			"last_seen": after[0].LastSeen,
+		},
+	})
+	for _, roomID := range roomIDs {
+		rm.broadcast(ctx, roomID, msg)
+	}
+
+	if rm.friends != nil {
+		friends, err := rm.friends.Friends(ctx, userID)
+		if err != nil {
+			telemetry.Logger(ctx, rm.logger).Error("Failed to load friends for a presence change",
+				logger.Err(err),
+				logger.String("user_id", userID))
+		}
+		for _, friendID := range friends {
+			rm.send(ctx, friendID, msg)
+		}
+	}
+	return nil
+}
+
+// StartTyping shows that a user is typing in a room they are in, or to another
+// user, until StopTyping or until the indicator expires. Starting again while
+// typing only extends the indicator.
+func (rm *RoomManager) StartTyping(ctx context.Context, userID, roomID, to string) error {
+	key, err := rm.typingKey(ctx, userID, roomID, to)
+	if err != nil || key == nil {
+		return err
+	}
+
+	indicators := rm.typing
+	indicators.mu.Lock()
+	timer, typing := indicators.timers[*key]
+	if typing {
+		// Replace rather than reset the timer, so one already firing finds it
+		// was replaced and leaves the indicator alone
+		timer.Stop()
+	}
+	var expire *time.Timer
+	expire = time.AfterFunc(indicators.timeout, func() {
+		indicators.mu.Lock()
+		if indicators.timers[*key] != expire {
+			indicators.mu.Unlock()
+			return
+		}
+		delete(indicators.timers, *key)
+		indicators.mu.Unlock()
+		rm.announceTyping(context.Background(), *key, MessageTypeTypingStop)
+	})
+	indicators.timers[*key] = expire
+	indicators.mu.Unlock()
+
+	if typing {
+		return nil
+	}
+	rm.announceTyping(ctx, *key, MessageTypeTypingStart)
+	return nil
+}
+
+// StopTyping hides a user's typing indicator in a room or to another user
+func (rm *RoomManager) StopTyping(ctx context.Context, userID, roomID, to string) error {
+	if (roomID == "") == (to == "") {
+		return errTypingTarget
+	}
+	rm.stopTyping(ctx, typingKey{userID: userID, roomID: roomID, to: to})
+	return nil
+}
+
+// stopTyping removes an indicator and announces it, if it was shown
+func (rm *RoomManager) stopTyping(ctx context.Context, key typingKey) {
+	indicators := rm.typing
+	indicators.mu.Lock()
+	timer, typing := indicators.timers[key]
+	if typing {
+		timer.Stop()
+		delete(indicators.timers, key)
+	}
+	indicators.mu.Unlock()
+
+	if typing {
+		rm.announceTyping(ctx, key, MessageTypeTypingStop)
+	}
+}
+
+// stopAllTyping removes every indicator of a user who disconnected
+func (rm *RoomManager) stopAllTyping(ctx context.Context, userID string) {
+	indicators := rm.typing
+	indicators.mu.Lock()
+	var keys []typingKey
+	for key := range indicators.timers {
+		if key.userID == userID {
+			keys = append(keys, key)
+		}
+	}
+	indicators.mu.Unlock()
+
+	for _, key := range keys {
+		rm.stopTyping(ctx, key)
+	}
+}
+
+// typingKey validates a typing target. It returns nil without an error when
+// the indicator should silently not be shown: to a user who blocks the typist.
+func (rm *RoomManager) typingKey(ctx context.Context, userID, roomID, to string) (*typingKey, error) {
+	switch {
+	case (roomID == "") == (to == ""):
+		return nil, errTypingTarget
+
+	case roomID != "":
+		if !rm.hub.IsMember(userID, roomID) {
+			return nil, errNotInRoom
+		}
+
+	case to == userID:
+		return nil, errMessageSelf
+
+	default:
+		blocked, err := rm.blocks.IsBlocked(ctx, to, userID)
+		if err != nil || blocked {
+			return nil, err
+		}
+	}
+	return &typingKey{userID: userID, roomID: roomID, to: to}, nil
+}
+
+// announceTyping tells a room, or the other user, that a user started or stopped typing
+func (rm *RoomManager) announceTyping(ctx context.Context, key typingKey, msgType MessageType) {
+	msg := envelope(&Message{
+		Type:     msgType,
+		RoomID:   key.roomID,
+		UserID:   key.userID,
+		Username: rm.username(key.userID),
+		Data: map[string]interface{}{
+			"to": key.to,
+		},
+	})
+	if key.roomID != "" {
+		rm.broadcast(ctx, key.roomID, msg)
+	} else {
+		rm.send(ctx, key.to, msg)
+	}
+}
@@ -0,0 +1,372 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/OkanUysal/go-starter-example-project/models"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/websocket"
+)
+
+// ProtocolVersion is the latest version of the WebSocket protocol, used when
+// a client doesn't ask for one
+const ProtocolVersion = 1
+
+// supportedProtocolVersions are the versions clients may ask for. An
+// incompatible change adds a version; older ones stay until clients moved on.
+var supportedProtocolVersions = []int{1}
+
+// subprotocolPrefix names a protocol version as a WebSocket subprotocol, e.g.
+// "starter.v1", optionally followed by a wire format, e.g. "starter.v1.msgpack"
+const subprotocolPrefix = "starter.v"
+
+// errUnsupportedProtocol is returned when a client asks only for unknown
+// protocol versions, or subprotocols that don't match its query parameters
+var errUnsupportedProtocol = fmt.Errorf("unsupported protocol version, supported: %s", supportedVersionList())
+
+// ErrorCode tells clients why a message was rejected, without parsing the text
+type ErrorCode string
+
+const (
+	// ErrorCodeInvalidMessage is a frame that isn't a JSON message
+	ErrorCodeInvalidMessage ErrorCode = "invalid_message"
+
+	// ErrorCodeUnknownType is a message type the server doesn't handle
+	ErrorCodeUnknownType ErrorCode = "unknown_type"
+
+	// ErrorCodeInvalidRequest is a message whose data is missing, mistyped or out of range
+	ErrorCodeInvalidRequest ErrorCode = "invalid_request"
+
+	// ErrorCodeNotFound is a room that doesn't exist
+	ErrorCodeNotFound ErrorCode = "not_found"
+
+	// ErrorCodeForbidden is an action the user isn't allowed to take
+	ErrorCodeForbidden ErrorCode = "forbidden"
+
+	// ErrorCodeRoomFull is a join to a room with no free place
+	ErrorCodeRoomFull ErrorCode = "room_full"
+
+	// ErrorCodeConflict is an action the room's current state doesn't allow
+	ErrorCodeConflict ErrorCode = "conflict"
+
+	// ErrorCodeRateLimited is a message sent faster than the server accepts; it may be retried later
+	ErrorCodeRateLimited ErrorCode = "rate_limited"
+
+	// ErrorCodeInternal is a failure on the server's side; the message may be retried
+	ErrorCodeInternal ErrorCode = "internal"
+)
+
+// errorCodes lists every ErrorCode, for the protocol description
+var errorCodes = []ErrorCode{
+	ErrorCodeInvalidMessage, ErrorCodeUnknownType, ErrorCodeInvalidRequest, ErrorCodeNotFound,
+	ErrorCodeForbidden, ErrorCodeRoomFull, ErrorCodeConflict, ErrorCodeRateLimited, ErrorCodeInternal,
+}
+
+// JoinRequest is the data of a join message
+type JoinRequest struct {
+	RoomID string `json:"room_id" binding:"required"`
+}
+
+// ChatRequest is the data of a chat message
+type ChatRequest struct {
+	RoomID  string `json:"room_id" binding:"required"`
+	Content string `json:"content" binding:"required,max=4000"`
+}
+
+// DirectRequest is the data of a dm message
+type DirectRequest struct {
+	To      string `json:"to" binding:"required"`
+	Content string `json:"content" binding:"required,max=4000"`
+}
+
+// DirectReadRequest is the data of a dm_read message
+type DirectReadRequest struct {
+	From      string `json:"from" binding:"required"`
+	MessageID int64  `json:"message_id" binding:"required,gt=0"`
+}
+
+// PresenceRequest is the data of a presence message
+type PresenceRequest struct {
+	Status PresenceStatus `json:"status" binding:"required,oneof=online away"`
+}
+
+// TypingRequest is the data of a typing_start or typing_stop message: either
+// a room_id or a to
+type TypingRequest struct {
+	RoomID string `json:"room_id,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+// AckRequest is the data of an ack message
+type AckRequest struct {
+	RoomID string `json:"room_id" binding:"required"`
+	Seq    int64  `json:"seq" binding:"required,gt=0"`
+}
+
+// ResumeRequest is the data of a resume message. Without a last_seq, the
+// room is resumed from the last ack.
+type ResumeRequest struct {
+	RoomID  string `json:"room_id" binding:"required"`
+	LastSeq *int64 `json:"last_seq,omitempty" binding:"omitempty,gte=0"`
+}
+
+// NewRoomRequest is the data of a create_room message
+type NewRoomRequest struct {
+	Name string `json:"name,omitempty" binding:"max=100"`
+}
+
+// CloseRoomRequest is the data of a close_room message
+type CloseRoomRequest struct {
+	RoomID string `json:"room_id" binding:"required"`
+}
+
+// ErrorReply is the data of an error message, replying to the message of
+// type Type with the request ID RequestID
+type ErrorReply struct {
+	Code      ErrorCode `json:"code"`
+	Message   string    `json:"message"`
+	Type      string    `json:"type,omitempty"`
+	RequestID string    `json:"request_id"`
+}
+
+// DirectSentReply is the data of a dm_sent message
+type DirectSentReply struct {
+	MessageID int64  `json:"message_id"`
+	To        string `json:"to"`
+	RequestID string `json:"request_id"`
+}
+
+// ResumedReply is the data of a resumed message
+type ResumedReply struct {
+	RoomID   string `json:"room_id"`
+	Replayed int    `json:"replayed"`
+	Seq      int64  `json:"seq"`
+}
+
+// SnapshotReply is the data of a snapshot message
+type SnapshotReply struct {
+	RoomID   string               `json:"room_id"`
+	Seq      int64                `json:"seq"`
+	Room     *RoomInfo            `json:"room"`
+	Messages []models.ChatMessage `json:"messages"`
+}
+
+// ConnectedReply is the data of a connected message
+type ConnectedReply struct {
+	ConnectionID string `json:"connection_id"`
+	UserID       string `json:"user_id"`
+	Protocol     int    `json:"protocol"`
+	Format       Format `json:"format"`
+}
+
+// KickedReply is the data of a kicked message, naming the connection that
+// replaced the closed one
+type KickedReply struct {
+	Reason       string `json:"reason"`
+	ConnectionID string `json:"connection_id"`
+}
+
+// negotiateProtocol picks the protocol version and wire format of a
+// connection request, from its protocol and format query parameters, or else
+// its WebSocket subprotocols. A client that offers subprotocols fails the
+// handshake unless one is accepted, so the choice is always among those offered.
+func negotiateProtocol(r *http.Request) (int, Format, error) {
+	var (
+		version int
+		format  Format
+		err     error
+	)
+	if query := r.URL.Query().Get("protocol"); query != "" {
+		version, err = strconv.Atoi(query)
+		if err != nil || !supportedProtocol(version) {
+			return 0, "", errUnsupportedProtocol
+		}
+	}
+	if query := r.URL.Query().Get("format"); query != "" {
+		if format, err = parseFormat(query); err != nil {
+			return 0, "", err
+		}
+	}
+
+	offered := websocket.Subprotocols(r)
+	if len(offered) == 0 {
+		if version == 0 {
+			version = ProtocolVersion
+		}
+		if format == "" {
+			format = FormatJSON
+		}
+		return version, format, nil
+	}
+
+	// The first supported subprotocol that agrees with the query parameters wins
+	for _, name := range offered {
+		v, f, ok := parseSubprotocol(name)
+		if ok && supportedProtocol(v) && (version == 0 || v == version) && (format == "" || f == format) {
+			return v, f, nil
+		}
+	}
+	return 0, "", errUnsupportedProtocol
+}
+
+// parseSubprotocol returns the protocol version and wire format a subprotocol
+// names: "starter.v1" is JSON, "starter.v1.msgpack" MessagePack
+func parseSubprotocol(name string) (int, Format, bool) {
+	rest, found := strings.CutPrefix(name, subprotocolPrefix)
+	if !found {
+		return 0, "", false
+	}
+	number, formatName, hasFormat := strings.Cut(rest, ".")
+	version, err := strconv.Atoi(number)
+	if err != nil {
+		return 0, "", false
+	}
+	if !hasFormat {
+		return version, FormatJSON, true
+	}
+	format, err := parseFormat(formatName)
+	return version, format, err == nil
+}
+
+// subprotocolName names a protocol version and wire format as a subprotocol
+func subprotocolName(version int, format Format) string {
+	name := subprotocolPrefix + strconv.Itoa(version)
+	if format != FormatJSON {
+		name += "." + string(format)
+	}
+	return name
+}
+
+// supportedProtocol reports whether a client may use a protocol version
+func supportedProtocol(version int) bool {
+	for _, supported := range supportedProtocolVersions {
+		if version == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// supportedVersionList lists the supported protocol versions for error messages
+func supportedVersionList() string {
+	versions := make([]string, len(supportedProtocolVersions))
+	for i, version := range supportedProtocolVersions {
+		versions[i] = strconv.Itoa(version)
+	}
+	return strings.Join(versions, ", ")
+}
+
+// decodeRequest fills req from a message's data and validates it, returning
+// an error a client can act on
+func decodeRequest(data map[string]interface{}, req interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, req); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("%s must be %s", typeErr.Field, jsonKind(typeErr.Type))
+		}
+		return err
+	}
+
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		var fieldErrs validator.ValidationErrors
+		if errors.As(err, &fieldErrs) {
+			return validationError(reflect.TypeOf(req).Elem(), fieldErrs[0])
+		}
+		return err
+	}
+	return nil
+}
+
+// validationError describes a failed validation rule by the field's JSON name
+func validationError(typ reflect.Type, fieldErr validator.FieldError) error {
+	name := fieldErr.Field()
+	if field, ok := typ.FieldByName(fieldErr.StructField()); ok {
+		name = jsonName(field)
+	}
+
+	switch fieldErr.Tag() {
+	case "required":
+		return fmt.Errorf("%s is required", name)
+	case "max":
+		return fmt.Errorf("%s must be at most %s characters", name, fieldErr.Param())
+	case "gt":
+		return fmt.Errorf("%s must be greater than %s", name, fieldErr.Param())
+	case "gte":
+		return fmt.Errorf("%s must be at least %s", name, fieldErr.Param())
+	case "oneof":
+		return fmt.Errorf("%s must be one of: %s", name, strings.ReplaceAll(fieldErr.Param(), " ", ", "))
+	default:
+		return fmt.Errorf("%s is invalid", name)
+	}
+}
+
+// jsonName returns the name a struct field has in JSON
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// jsonKind names the JSON type a Go type is decoded from
+func jsonKind(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// errorCode classifies an error returned while handling a message. Errors it
+// doesn't know are internal, and their text isn't shown to clients.
+func errorCode(err error) ErrorCode {
+	var replyErr *Error
+	switch {
+	case errors.As(err, &replyErr):
+		return replyErr.Code
+	case errors.Is(err, errRoomNotFound):
+		return ErrorCodeNotFound
+	case errors.Is(err, ErrRoomFull):
+		return ErrorCodeRoomFull
+	case errors.Is(err, errNotAuthorized), errors.Is(err, errJoinNotAuthorized),
+		errors.Is(err, errNotInRoom), errors.Is(err, errBlocked):
+		return ErrorCodeForbidden
+	case errors.Is(err, errRoomInactive), errors.Is(err, errRoomClosed):
+		return ErrorCodeConflict
+	case errors.Is(err, errCloseLobby), errors.Is(err, errMessageSelf), errors.Is(err, errBlockSelf),
+		errors.Is(err, errInvalidStatus), errors.Is(err, errTypingTarget):
+		return ErrorCodeInvalidRequest
+	default:
+		return ErrorCodeInternal
+	}
+}
+
+// reply builds a message to one client from its typed data
+func reply(msgType MessageType, data interface{}) Envelope {
+	raw, _ := json.Marshal(data)
+	var fields map[string]interface{}
+	json.Unmarshal(raw, &fields)
+	return Envelope{Type: string(msgType), Data: fields}
+}
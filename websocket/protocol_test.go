@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestNegotiateProtocol(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		subprotocols string
+		want         int
+		wantFormat   Format
+		wantErr      bool
+	}{
+		{name: "latest by default", want: ProtocolVersion, wantFormat: FormatJSON},
+		{name: "query parameter", query: "?protocol=1", want: 1, wantFormat: FormatJSON},
+		{name: "subprotocol", subprotocols: "other, starter.v1", want: 1, wantFormat: FormatJSON},
+		{name: "query matching a subprotocol", query: "?protocol=1", subprotocols: "starter.v1", want: 1, wantFormat: FormatJSON},
+		{name: "format query", query: "?format=msgpack", want: ProtocolVersion, wantFormat: FormatMsgPack},
+		{name: "format subprotocol", subprotocols: "starter.v1.msgpack, starter.v1", want: 1, wantFormat: FormatMsgPack},
+		{name: "format query picking a subprotocol", query: "?format=json", subprotocols: "starter.v1.msgpack, starter.v1", want: 1, wantFormat: FormatJSON},
+		{name: "unsupported query", query: "?protocol=99", wantErr: true},
+		{name: "malformed query", query: "?protocol=one", wantErr: true},
+		{name: "unsupported format", query: "?format=xml", wantErr: true},
+		{name: "unsupported subprotocols", subprotocols: "starter.v99, starter.v1.xml, other", wantErr: true},
+		{name: "query not among subprotocols", query: "?protocol=1", subprotocols: "other", wantErr: true},
+		{name: "format query not among subprotocols", query: "?format=msgpack", subprotocols: "starter.v1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/ws"+tt.query, nil)
+			if tt.subprotocols != "" {
+				r.Header.Set("Sec-WebSocket-Protocol", tt.subprotocols)
+			}
+
+			version, format, err := negotiateProtocol(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("negotiateProtocol = %d, %s; want an error", version, format)
+				}
+				return
+			}
+			if err != nil || version != tt.want || format != tt.wantFormat {
+				t.Errorf("negotiateProtocol = %d, %s, %v; want %d, %s", version, format, err, tt.want, tt.wantFormat)
+			}
+		})
+	}
+}
+
+func TestDecodeRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		req     interface{}
+		wantErr string
+	}{
+		{name: "valid", data: map[string]interface{}{"room_id": "r1", "content": "hi"}, req: &ChatRequest{}},
+		{name: "missing field", data: map[string]interface{}{"room_id": "r1"}, req: &ChatRequest{}, wantErr: "content is required"},
+		{name: "no data", req: &JoinRequest{}, wantErr: "room_id is required"},
+		{name: "wrong type", data: map[string]interface{}{"room_id": 7}, req: &JoinRequest{}, wantErr: "room_id must be a string"},
+		{name: "fraction", data: map[string]interface{}{"from": "u1", "message_id": 1.5}, req: &DirectReadRequest{}, wantErr: "message_id must be an integer"},
+		{name: "out of range", data: map[string]interface{}{"room_id": "r1", "seq": 0}, req: &AckRequest{}, wantErr: "seq is required"},
+		{name: "not allowed", data: map[string]interface{}{"status": "busy"}, req: &PresenceRequest{}, wantErr: "status must be one of: online, away"},
+		{name: "optional absent", data: map[string]interface{}{"room_id": "r1"}, req: &ResumeRequest{}},
+		{name: "optional invalid", data: map[string]interface{}{"room_id": "r1", "last_seq": -1}, req: &ResumeRequest{}, wantErr: "last_seq must be at least 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decodeRequest(tt.data, tt.req)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("decodeRequest: %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("decodeRequest error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAsyncAPIDocIsUpToDate(t *testing.T) {
+	want, err := AsyncAPI()
+	if err != nil {
+		t.Fatalf("AsyncAPI: %v", err)
+	}
+	got, err := os.ReadFile("../docs/asyncapi.json")
+	if err != nil {
+		t.Fatalf("failed to read the protocol description: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("docs/asyncapi.json is out of date; regenerate it with: go run . asyncapi -o docs/asyncapi.json")
+	}
+}
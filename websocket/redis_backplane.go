@@ -0,0 +1,547 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keys used by the backplane
+const (
+	redisEventsChannel  = "ws:events"
+	redisRoomsKey       = "ws:rooms"
+	redisMembersPrefix  = "ws:room_members:" // + room ID: hash of user ID and node ID -> member, and node liveness
+	redisNodeField      = "#node:"           // + node ID: field of a room's members hash -> Unix milliseconds the node is alive until
+	redisNodePrefix     = "ws:node:"         // + node ID: exists while the node is alive
+	redisPresencePrefix = "ws:presence:"     // + user ID: hash of node ID -> status
+	redisLastSeenKey    = "ws:last_seen"     // hash of user ID -> Unix milliseconds
+	redisSequencePrefix = "ws:seq:"          // + room ID: last sequence number handed out
+	redisAcksPrefix     = "ws:acks:"         // + room ID: hash of user ID -> acknowledged sequence number
+)
+
+// saveAckScript stores an ack unless a later one is already recorded
+var saveAckScript = redis.NewScript(`
+local current = tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0")
+if tonumber(ARGV[2]) > current then
+	redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+end
+return 0
+`)
+
+// redisNowLua sets now to the Redis server's clock in Unix milliseconds, so
+// node expiries don't depend on the clocks of the nodes
+const redisNowLua = `
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+`
+
+// redisMembersLua sets members to the members of the room in KEYS[1] whose
+// node is alive, reading node liveness from the same hash so scripts only
+// touch the keys they declare, as Redis Cluster requires
+const redisMembersLua = `
+local fields, expiries, members = redis.call("HGETALL", KEYS[1]), {}, {}
+for i = 1, #fields, 2 do
+	if string.sub(fields[i], 1, #"` + redisNodeField + `") == "` + redisNodeField + `" then
+		expiries[string.sub(fields[i], #"` + redisNodeField + `" + 1)] = tonumber(fields[i + 1])
+	end
+end
+for i = 1, #fields, 2 do
+	local ok, stored = pcall(cjson.decode, fields[i + 1])
+	if ok and type(stored) == "table" and type(stored.user) == "table" and stored.node_id
+		and expiries[stored.node_id] and expiries[stored.node_id] > now then
+		table.insert(members, stored)
+	end
+end
+`
+
+// addMemberScript stores member ARGV[4] of user ARGV[1] under field ARGV[2]
+// through node ARGV[5], first marking the node alive for ARGV[6] milliseconds
+// (0: leave its liveness as it is), unless the user isn't in the room yet and
+// it already has ARGV[3] users on live nodes (0: no limit). It returns -1 if
+// the room is full, 1 if the user joined it and 0 if they already were in it
+// through another node. Users are counted and added in one step so
+// concurrent joins can't overfill the room.
+var addMemberScript = redis.NewScript(redisNowLua + `
+if tonumber(ARGV[6]) > 0 then
+	redis.call("HSET", KEYS[1], "` + redisNodeField + `" .. ARGV[5], now + tonumber(ARGV[6]))
+end
+` + redisMembersLua + `
+local users, count, member = {}, 0, false
+for _, stored in ipairs(members) do
+	local userID = stored.user.user_id
+	if userID == ARGV[1] then
+		member = true
+	end
+	if not users[userID] then
+		users[userID] = true
+		count = count + 1
+	end
+end
+local limit = tonumber(ARGV[3])
+if not member and limit > 0 and count >= limit then
+	return -1
+end
+redis.call("HSET", KEYS[1], ARGV[2], ARGV[4])
+if member then
+	return 0
+end
+return 1
+`)
+
+// removeMemberScript deletes field ARGV[2], and the liveness of node ARGV[3]
+// once it has no members left in the room. It returns 1 unless user ARGV[1]
+// is still in the room through another live node.
+var removeMemberScript = redis.NewScript(redisNowLua + `
+redis.call("HDEL", KEYS[1], ARGV[2])
+` + redisMembersLua + `
+local left, nodeEmpty = 1, true
+for _, stored in ipairs(members) do
+	if stored.user.user_id == ARGV[1] then
+		left = 0
+	end
+	if stored.node_id == ARGV[3] then
+		nodeEmpty = false
+	end
+end
+if nodeEmpty then
+	redis.call("HDEL", KEYS[1], "` + redisNodeField + `" .. ARGV[3])
+end
+return left
+`)
+
+// touchNodeScript marks node field ARGV[1] alive for another ARGV[2]
+// milliseconds, unless the room in KEYS[1] has been deleted
+var touchNodeScript = redis.NewScript(redisNowLua + `
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("HSET", KEYS[1], ARGV[1], now + tonumber(ARGV[2]))
+end
+return 0
+`)
+
+// Node liveness: a subscribed node refreshes its key and its field in the
+// rooms it has members in every nodeHeartbeat, and its members and
+// connections stop counting once they have expired
+const (
+	nodeHeartbeat = 10 * time.Second
+	nodeTTL       = 3 * nodeHeartbeat
+)
+
+// redisMember is a room member as stored in Redis, once for each node the
+// user is in the room through
+type redisMember struct {
+	NodeID string    `json:"node_id"`
+	User   *UserInfo `json:"user"`
+}
+
+// memberField is the field of a user's membership through a node in a room's members hash
+func memberField(userID, nodeID string) string {
+	return userID + "@" + nodeID
+}
+
+// RedisBackplane is a Backplane shared by every replica through Redis: events go
+// over pub/sub and rooms and members are stored in hashes
+type RedisBackplane struct {
+	client *redis.Client
+	logger *logger.Logger
+
+	mu    sync.Mutex
+	nodes map[string]*redisNode // node ID -> what it holds, while subscribed
+}
+
+// redisNode is what a node subscribed through this backplane has stored, so
+// its heartbeat can keep it alive and unsubscribing can remove it
+type redisNode struct {
+	rooms    map[string]map[string]bool // room ID -> user IDs
+	presence map[string]bool            // user IDs
+}
+
+// NewRedisBackplane creates a backplane on an existing Redis client
+func NewRedisBackplane(client *redis.Client, log *logger.Logger) *RedisBackplane {
+	return &RedisBackplane{client: client, logger: log, nodes: make(map[string]*redisNode)}
+}
+
+// Subscribe implements Backplane. Events are received on a dedicated
+// connection, and the node's liveness is kept fresh until unsubscribe, which
+// removes its members and connections.
+func (b *RedisBackplane) Subscribe(nodeID string, handler func(*ClusterEvent)) (func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b.mu.Lock()
+	if b.nodes[nodeID] == nil {
+		b.nodes[nodeID] = &redisNode{rooms: make(map[string]map[string]bool), presence: make(map[string]bool)}
+	}
+	b.mu.Unlock()
+
+	pubsub := b.client.Subscribe(ctx, redisEventsChannel)
+	// Wait for the confirmation, so events published after Subscribe returns are seen
+	if _, err := pubsub.Receive(ctx); err != nil {
+		cancel()
+		pubsub.Close()
+		b.forget(nodeID)
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", redisEventsChannel, err)
+	}
+	if err := b.heartbeat(ctx, nodeID); err != nil {
+		cancel()
+		pubsub.Close()
+		b.forget(nodeID)
+		return nil, err
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		defer func() { done <- struct{}{} }()
+		for msg := range pubsub.Channel() {
+			var event ClusterEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				b.logger.Warn("Ignoring malformed backplane event", logger.Err(err))
+				continue
+			}
+			handler(&event)
+		}
+	}()
+	go func() {
+		defer func() { done <- struct{}{} }()
+		ticker := time.NewTicker(nodeHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := b.heartbeat(ctx, nodeID); err != nil && ctx.Err() == nil {
+					b.logger.Warn("Backplane heartbeat failed", logger.String("node_id", nodeID), logger.Err(err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		pubsub.Close()
+		<-done
+		<-done
+
+		ctx := context.Background()
+		node := b.forget(nodeID)
+		for roomID := range node.rooms {
+			b.client.HDel(ctx, redisMembersPrefix+roomID, redisNodeField+nodeID)
+		}
+		for userID := range node.presence {
+			b.removePresence(ctx, userID, nodeID)
+		}
+		b.client.Del(ctx, redisNodePrefix+nodeID)
+	}, nil
+}
+
+// forget stops tracking a node and returns what it held
+func (b *RedisBackplane) forget(nodeID string) *redisNode {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	node := b.nodes[nodeID]
+	delete(b.nodes, nodeID)
+	return node
+}
+
+// heartbeat marks a node, and its members in each room, as alive for another nodeTTL
+func (b *RedisBackplane) heartbeat(ctx context.Context, nodeID string) error {
+	if err := b.client.Set(ctx, redisNodePrefix+nodeID, time.Now().Unix(), nodeTTL).Err(); err != nil {
+		return fmt.Errorf("failed to refresh node %s: %w", nodeID, err)
+	}
+
+	b.mu.Lock()
+	var rooms []string
+	if node := b.nodes[nodeID]; node != nil {
+		for roomID := range node.rooms {
+			rooms = append(rooms, roomID)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, roomID := range rooms {
+		err := touchNodeScript.Run(ctx, b.client, []string{redisMembersPrefix + roomID},
+			redisNodeField+nodeID, nodeTTL.Milliseconds()).Err()
+		if err != nil {
+			return fmt.Errorf("failed to refresh node %s in room %s: %w", nodeID, roomID, err)
+		}
+	}
+	return nil
+}
+
+// subscribed reports whether a node is subscribed through this backplane
+func (b *RedisBackplane) subscribed(nodeID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nodes[nodeID] != nil
+}
+
+// track records a change a node subscribed through this backplane made
+func (b *RedisBackplane) track(nodeID string, change func(node *redisNode)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if node := b.nodes[nodeID]; node != nil {
+		change(node)
+	}
+}
+
+// Publish implements Backplane
+func (b *RedisBackplane) Publish(ctx context.Context, event *ClusterEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, redisEventsChannel, payload).Err()
+}
+
+// SaveRoom implements Backplane
+func (b *RedisBackplane) SaveRoom(ctx context.Context, room *RoomInfo) error {
+	payload, err := json.Marshal(room.metadata())
+	if err != nil {
+		return err
+	}
+	return b.client.HSet(ctx, redisRoomsKey, room.ID, payload).Err()
+}
+
+// DeleteRoom implements Backplane
+func (b *RedisBackplane) DeleteRoom(ctx context.Context, roomID string) error {
+	b.mu.Lock()
+	for _, node := range b.nodes {
+		delete(node.rooms, roomID)
+	}
+	b.mu.Unlock()
+
+	_, err := b.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, redisRoomsKey, roomID)
+		pipe.Del(ctx, redisMembersPrefix+roomID, redisSequencePrefix+roomID, redisAcksPrefix+roomID)
+		return nil
+	})
+	return err
+}
+
+// Rooms implements Backplane
+func (b *RedisBackplane) Rooms(ctx context.Context) ([]*RoomInfo, error) {
+	stored, err := b.client.HGetAll(ctx, redisRoomsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	rooms := make([]*RoomInfo, 0, len(stored))
+	for roomID, payload := range stored {
+		var room RoomInfo
+		if err := json.Unmarshal([]byte(payload), &room); err != nil {
+			return nil, fmt.Errorf("failed to decode room %s: %w", roomID, err)
+		}
+		rooms = append(rooms, &room)
+	}
+	return rooms, nil
+}
+
+// AddMember implements Backplane
+func (b *RedisBackplane) AddMember(ctx context.Context, roomID, nodeID string, user *UserInfo, limit int) (bool, error) {
+	payload, err := json.Marshal(redisMember{NodeID: nodeID, User: user})
+	if err != nil {
+		return false, err
+	}
+
+	// Only a node subscribed here is known to be alive; another node's
+	// members count while its own heartbeat keeps them alive
+	var ttl int64
+	if b.subscribed(nodeID) {
+		ttl = nodeTTL.Milliseconds()
+	}
+	result, err := addMemberScript.Run(ctx, b.client, []string{redisMembersPrefix + roomID},
+		user.UserID, memberField(user.UserID, nodeID), limit, payload, nodeID, ttl).Int()
+	if err != nil {
+		return false, err
+	}
+	if result < 0 {
+		return false, ErrRoomFull
+	}
+	b.track(nodeID, func(node *redisNode) {
+		if node.rooms[roomID] == nil {
+			node.rooms[roomID] = make(map[string]bool)
+		}
+		node.rooms[roomID][user.UserID] = true
+	})
+	return result == 1, nil
+}
+
+// RemoveMember implements Backplane
+func (b *RedisBackplane) RemoveMember(ctx context.Context, roomID, nodeID, userID string) (bool, error) {
+	left, err := removeMemberScript.Run(ctx, b.client, []string{redisMembersPrefix + roomID},
+		userID, memberField(userID, nodeID), nodeID).Int()
+	if err != nil {
+		return false, err
+	}
+	b.track(nodeID, func(node *redisNode) {
+		delete(node.rooms[roomID], userID)
+		if len(node.rooms[roomID]) == 0 {
+			delete(node.rooms, roomID)
+		}
+	})
+	return left == 1, nil
+}
+
+// Members implements Backplane. Members of nodes that stopped without cleaning
+// up (e.g. crashed) are skipped and removed.
+func (b *RedisBackplane) Members(ctx context.Context, roomID string) ([]*UserInfo, error) {
+	key := redisMembersPrefix + roomID
+	stored, err := b.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	now, err := b.client.Time(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	alive := make(map[string]bool) // node ID -> alive
+	for field, expiry := range stored {
+		if nodeID, ok := strings.CutPrefix(field, redisNodeField); ok {
+			millis, err := strconv.ParseInt(expiry, 10, 64)
+			alive[nodeID] = err == nil && millis > now.UnixMilli()
+		}
+	}
+
+	// A user in the room through several nodes is listed once, from their first join
+	users := make(map[string]*UserInfo, len(stored))
+	for field, payload := range stored {
+		if nodeID, ok := strings.CutPrefix(field, redisNodeField); ok {
+			if !alive[nodeID] {
+				b.client.HDel(ctx, key, field)
+			}
+			continue
+		}
+		var member redisMember
+		if err := json.Unmarshal([]byte(payload), &member); err != nil || member.User == nil {
+			b.logger.Warn("Removing malformed room member",
+				logger.String("room_id", roomID), logger.String("field", field))
+			b.client.HDel(ctx, key, field)
+			continue
+		}
+		if !alive[member.NodeID] {
+			b.client.HDel(ctx, key, field)
+			continue
+		}
+		user := member.User
+		if first, seen := users[user.UserID]; !seen || user.JoinedAt.Before(first.JoinedAt) {
+			users[user.UserID] = user
+		}
+	}
+	members := make([]*UserInfo, 0, len(users))
+	for _, user := range users {
+		members = append(members, user)
+	}
+	sortMembers(members)
+	return members, nil
+}
+
+// SetPresence implements Backplane
+func (b *RedisBackplane) SetPresence(ctx context.Context, userID, nodeID string, status PresenceStatus) error {
+	if err := b.client.HSet(ctx, redisPresencePrefix+userID, nodeID, string(status)).Err(); err != nil {
+		return err
+	}
+	b.track(nodeID, func(node *redisNode) { node.presence[userID] = true })
+	return nil
+}
+
+// RemovePresence implements Backplane
+func (b *RedisBackplane) RemovePresence(ctx context.Context, userID, nodeID string) error {
+	if err := b.removePresence(ctx, userID, nodeID); err != nil {
+		return err
+	}
+	b.track(nodeID, func(node *redisNode) { delete(node.presence, userID) })
+	return nil
+}
+
+// removePresence deletes a user's connection to a node and records when they were last seen
+func (b *RedisBackplane) removePresence(ctx context.Context, userID, nodeID string) error {
+	_, err := b.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, redisPresencePrefix+userID, nodeID)
+		pipe.HSet(ctx, redisLastSeenKey, userID, time.Now().UnixMilli())
+		return nil
+	})
+	return err
+}
+
+// Presence implements Backplane. Connections to nodes that stopped without
+// cleaning up (e.g. crashed) are skipped and removed.
+func (b *RedisBackplane) Presence(ctx context.Context, userIDs ...string) ([]Presence, error) {
+	alive := make(map[string]bool)
+	presence := make([]Presence, len(userIDs))
+	for i, userID := range userIDs {
+		key := redisPresencePrefix + userID
+		stored, err := b.client.HGetAll(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		statuses := make([]PresenceStatus, 0, len(stored))
+		for nodeID, status := range stored {
+			nodeAlive, checked := alive[nodeID]
+			if !checked {
+				exists, err := b.client.Exists(ctx, redisNodePrefix+nodeID).Result()
+				if err != nil {
+					return nil, err
+				}
+				nodeAlive = exists > 0
+				alive[nodeID] = nodeAlive
+			}
+			if !nodeAlive {
+				b.client.HDel(ctx, key, nodeID)
+				continue
+			}
+			statuses = append(statuses, PresenceStatus(status))
+		}
+
+		var lastSeen *time.Time
+		if millis, err := b.client.HGet(ctx, redisLastSeenKey, userID).Int64(); err == nil {
+			seen := time.UnixMilli(millis)
+			lastSeen = &seen
+		} else if !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		presence[i] = combinePresence(userID, statuses, lastSeen)
+	}
+	return presence, nil
+}
+
+// NextSequence implements Backplane
+func (b *RedisBackplane) NextSequence(ctx context.Context, roomID string) (int64, error) {
+	return b.client.Incr(ctx, redisSequencePrefix+roomID).Result()
+}
+
+// Sequence implements Backplane
+func (b *RedisBackplane) Sequence(ctx context.Context, roomID string) (int64, error) {
+	seq, err := b.client.Get(ctx, redisSequencePrefix+roomID).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return seq, err
+}
+
+// SaveAck implements Backplane
+func (b *RedisBackplane) SaveAck(ctx context.Context, roomID, userID string, seq int64) error {
+	return saveAckScript.Run(ctx, b.client, []string{redisAcksPrefix + roomID}, userID, seq).Err()
+}
+
+// Ack implements Backplane
+func (b *RedisBackplane) Ack(ctx context.Context, roomID, userID string) (int64, error) {
+	seq, err := b.client.HGet(ctx, redisAcksPrefix+roomID, userID).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return seq, err
+}
+
+// Ping implements Backplane
+func (b *RedisBackplane) Ping(ctx context.Context) error {
+	return b.client.Ping(ctx).Err()
+}
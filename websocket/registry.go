@@ -0,0 +1,114 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Request is an inbound message on its way to the handler of its type
+type Request struct {
+	Client    *Client
+	Type      string
+	Data      map[string]interface{}
+	RequestID string
+}
+
+// Decode fills v from the message's data and validates it against v's
+// binding tags. The error is an invalid_request Error, ready to be returned.
+func (r *Request) Decode(v interface{}) error {
+	if err := decodeRequest(r.Data, v); err != nil {
+		return NewError(ErrorCodeInvalidRequest, err.Error())
+	}
+	return nil
+}
+
+// HandlerFunc handles one inbound message. A returned error is sent back to
+// the client as an error message: an *Error with its code, anything else not
+// recognized as an internal error.
+type HandlerFunc func(ctx context.Context, req *Request) error
+
+// Middleware wraps a handler, to check, limit, measure or log the messages
+// reaching it
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Error is an error reply with its code, for handlers to return
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+// NewError returns an error reply with a code and a message for the client
+func NewError(code ErrorCode, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Registry routes inbound messages to the handlers registered for their type
+type Registry struct {
+	mu         sync.RWMutex
+	handlers   map[string]HandlerFunc
+	middleware []Middleware
+}
+
+// NewRegistry creates a registry without handlers
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]HandlerFunc)}
+}
+
+// Use adds middleware run for every message, including those of unknown types.
+// The first added runs first.
+func (r *Registry) Use(middleware ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Handle registers the handler for a message type, wrapped in middleware that
+// only applies to it. Registering a type twice panics, like a duplicate route.
+func (r *Registry) Handle(msgType MessageType, handler HandlerFunc, middleware ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.handlers[string(msgType)]; exists {
+		panic(fmt.Sprintf("websocket: handler for %q registered twice", msgType))
+	}
+	r.handlers[string(msgType)] = chain(handler, middleware)
+}
+
+// Has reports whether a handler is registered for a message type
+func (r *Registry) Has(msgType string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, exists := r.handlers[msgType]
+	return exists
+}
+
+// Dispatch passes a message through the middleware to its handler
+func (r *Registry) Dispatch(ctx context.Context, req *Request) error {
+	r.mu.RLock()
+	handler, exists := r.handlers[req.Type]
+	middleware := r.middleware
+	r.mu.RUnlock()
+
+	if !exists {
+		handler = unknownType
+	}
+	return chain(handler, middleware)(ctx, req)
+}
+
+// unknownType rejects a message no handler is registered for
+func unknownType(ctx context.Context, req *Request) error {
+	return NewError(ErrorCodeUnknownType, fmt.Sprintf("unknown message type %q", req.Type))
+}
+
+// chain wraps handler in middleware, the first outermost
+func chain(handler HandlerFunc, middleware []Middleware) HandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
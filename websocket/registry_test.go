@@ -0,0 +1,120 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OkanUysal/go-starter-example-project/models"
+)
+
+func TestRegistryDispatch(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, req *Request) error {
+				calls = append(calls, name)
+				return next(ctx, req)
+			}
+		}
+	}
+
+	registry := NewRegistry()
+	registry.Use(trace("global"))
+	registry.Handle("ping", func(ctx context.Context, req *Request) error {
+		calls = append(calls, "ping")
+		return nil
+	}, trace("own"))
+
+	client := &Client{UserID: "u1"}
+	if err := registry.Dispatch(context.Background(), &Request{Client: client, Type: "ping"}); err != nil {
+		t.Fatalf("Dispatch(ping): %v", err)
+	}
+	if got := strings.Join(calls, ","); got != "global,own,ping" {
+		t.Errorf("calls = %s, want global,own,ping", got)
+	}
+
+	// Unknown types still pass through the global middleware
+	calls = nil
+	err := registry.Dispatch(context.Background(), &Request{Client: client, Type: "pong"})
+	if errorCode(err) != ErrorCodeUnknownType {
+		t.Errorf("Dispatch(pong) = %v, want an unknown_type error", err)
+	}
+	if got := strings.Join(calls, ","); got != "global" {
+		t.Errorf("calls = %s, want global", got)
+	}
+
+	if !registry.Has("ping") || registry.Has("pong") {
+		t.Error("Has doesn't match the registered types")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering ping twice didn't panic")
+		}
+	}()
+	registry.Handle("ping", func(ctx context.Context, req *Request) error { return nil })
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorCode
+	}{
+		{NewError(ErrorCodeConflict, "not your turn"), ErrorCodeConflict},
+		{ErrRoomFull, ErrorCodeRoomFull},
+		{errors.New("connection refused"), ErrorCodeInternal},
+	}
+	for _, tt := range tests {
+		if got := errorCode(tt.err); got != tt.want {
+			t.Errorf("errorCode(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	handler := RequireRole(models.RoleAdmin)(func(ctx context.Context, req *Request) error { return nil })
+
+	admin := &Request{Client: &Client{UserID: "a", Role: models.RoleAdmin}, Type: "close_room"}
+	if err := handler(context.Background(), admin); err != nil {
+		t.Errorf("admin: %v", err)
+	}
+	user := &Request{Client: &Client{UserID: "u", Role: models.RoleUser}, Type: "close_room"}
+	if err := handler(context.Background(), user); errorCode(err) != ErrorCodeForbidden {
+		t.Errorf("user: %v, want a forbidden error", err)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := &rateLimiter{rate: 2, burst: 3, buckets: make(map[string]*tokenBucket)}
+	start := time.Now()
+
+	// A burst is allowed at once, and a user's limit doesn't affect another's
+	for i := 0; i < 3; i++ {
+		if !limiter.allow("u1", start) {
+			t.Fatalf("message %d of the burst was refused", i+1)
+		}
+	}
+	if limiter.allow("u1", start) {
+		t.Error("a message over the burst was allowed")
+	}
+	if !limiter.allow("u2", start) {
+		t.Error("another user's message was refused")
+	}
+
+	// Tokens come back at the rate
+	if !limiter.allow("u1", start.Add(500*time.Millisecond)) {
+		t.Error("a message after a refill was refused")
+	}
+	if limiter.allow("u1", start.Add(500*time.Millisecond)) {
+		t.Error("a refill allowed more than the rate")
+	}
+
+	// Users whose bucket filled up again are forgotten
+	limiter.allow("u3", start.Add(time.Hour))
+	if _, kept := limiter.buckets["u1"]; kept || len(limiter.buckets) != 1 {
+		t.Errorf("buckets after a sweep = %d, want only the new user's", len(limiter.buckets))
+	}
+}
@@ -0,0 +1,322 @@
+package websocket
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/OkanUysal/go-logger"
+	"github.com/OkanUysal/go-starter-example-project/telemetry"
+)
+
+// defaultReplayBufferSize is how many recent messages per room are kept for
+// clients resuming after a reconnect
+const defaultReplayBufferSize = 256
+
+// gapWait is how long a room's messages that arrived ahead of a missing one
+// are held back, before they are delivered without it (e.g. it was never published)
+const gapWait = 500 * time.Millisecond
+
+// unsequenced are the message types broadcast without a sequence number: they
+// are only meaningful live, so they are neither replayed nor counted as missed
+var unsequenced = map[string]bool{
+	string(MessageTypeTypingStart): true,
+	string(MessageTypeTypingStop):  true,
+}
+
+// replayBuffer keeps each room's latest sequenced messages on this node,
+// ordered by sequence number
+type replayBuffer struct {
+	mu    sync.Mutex
+	size  int
+	rooms map[string][]Envelope
+}
+
+func newReplayBuffer(size int) *replayBuffer {
+	return &replayBuffer{size: size, rooms: make(map[string][]Envelope)}
+}
+
+// add stores a message, dropping the room's oldest once the buffer is full.
+// Messages usually arrive in order, but may not when several are broadcast at once.
+func (b *replayBuffer) add(roomID string, msg Envelope) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	messages := b.rooms[roomID]
+	i := sort.Search(len(messages), func(i int) bool { return messages[i].Seq >= msg.Seq })
+	if i < len(messages) && messages[i].Seq == msg.Seq {
+		return
+	}
+	messages = append(messages, Envelope{})
+	copy(messages[i+1:], messages[i:])
+	messages[i] = msg
+
+	if len(messages) > b.size {
+		messages = append([]Envelope(nil), messages[len(messages)-b.size:]...)
+	}
+	b.rooms[roomID] = messages
+}
+
+// between returns a room's messages numbered after from, up to and including
+// through, and whether the buffer still holds every one of them
+func (b *replayBuffer) between(roomID string, from, through int64) ([]Envelope, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var messages []Envelope
+	for _, msg := range b.rooms[roomID] {
+		if msg.Seq > from && msg.Seq <= through {
+			messages = append(messages, msg)
+		}
+	}
+	// Sequence numbers are unique, so the count tells whether any is missing
+	return messages, int64(len(messages)) == through-from
+}
+
+// drop forgets a closed room's messages
+func (b *replayBuffer) drop(roomID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.rooms, roomID)
+}
+
+// orderedDelivery queues each room's sequenced messages for the room's
+// connections in sequence order, whichever node numbered them and however
+// the broadcasts interleaved. A message arriving ahead of one still on its
+// way is held back until that one arrives, or for gapWait at most. Only rooms
+// with connections on this node are tracked; messages to other rooms reach
+// nobody here and are passed straight through.
+type orderedDelivery struct {
+	mu      sync.Mutex
+	rooms   map[string]*roomDelivery
+	wait    time.Duration
+	deliver func(roomID string, msg Envelope)
+}
+
+// roomDelivery is the delivery state of one room. Its lock is held while
+// the room's messages are queued, so they are queued one at a time, in order.
+type roomDelivery struct {
+	mu      sync.Mutex
+	last    int64              // highest sequence number delivered
+	pending map[int64]Envelope // messages held back until those before them arrive
+	timer   *time.Timer
+}
+
+func newOrderedDelivery(wait time.Duration, deliver func(roomID string, msg Envelope)) *orderedDelivery {
+	return &orderedDelivery{rooms: make(map[string]*roomDelivery), wait: wait, deliver: deliver}
+}
+
+// track starts ordering a room's messages after the one numbered last, unless it already is
+func (d *orderedDelivery) track(roomID string, last int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, tracked := d.rooms[roomID]; !tracked {
+		d.rooms[roomID] = &roomDelivery{last: last, pending: make(map[int64]Envelope)}
+	}
+}
+
+// tracked reports whether a room's messages are being ordered
+func (d *orderedDelivery) tracked(roomID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, tracked := d.rooms[roomID]
+	return tracked
+}
+
+// add delivers a sequenced message once every message numbered before it has
+// been. One that arrives after its turn was given up on is delivered at once.
+func (d *orderedDelivery) add(roomID string, msg Envelope) {
+	d.mu.Lock()
+	room := d.rooms[roomID]
+	d.mu.Unlock()
+	if room == nil {
+		d.deliver(roomID, msg)
+		return
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if msg.Seq > room.last+1 {
+		room.pending[msg.Seq] = msg
+		if room.timer == nil {
+			room.timer = time.AfterFunc(d.wait, func() { d.expire(roomID, room) })
+		}
+		return
+	}
+	d.deliver(roomID, msg)
+	room.last = max(room.last, msg.Seq)
+	d.flush(roomID, room, false)
+}
+
+// flush delivers the held back messages that are now next in line, or with
+// skipGaps all of them, in order. The caller holds the room's lock.
+func (d *orderedDelivery) flush(roomID string, room *roomDelivery, skipGaps bool) {
+	for len(room.pending) > 0 {
+		next, ready := room.pending[room.last+1]
+		if !ready {
+			if !skipGaps {
+				return
+			}
+			lowest := int64(-1)
+			for seq := range room.pending {
+				if lowest < 0 || seq < lowest {
+					lowest = seq
+				}
+			}
+			next = room.pending[lowest]
+		}
+		delete(room.pending, next.Seq)
+		d.deliver(roomID, next)
+		room.last = next.Seq
+	}
+	if room.timer != nil {
+		room.timer.Stop()
+		room.timer = nil
+	}
+}
+
+// expire gives up waiting for the messages missing before the held back ones
+func (d *orderedDelivery) expire(roomID string, room *roomDelivery) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	room.timer = nil
+	d.flush(roomID, room, true)
+}
+
+// drop delivers what a closing room still holds back and stops tracking it
+func (d *orderedDelivery) drop(roomID string) {
+	d.mu.Lock()
+	room := d.rooms[roomID]
+	delete(d.rooms, roomID)
+	d.mu.Unlock()
+	if room == nil {
+		return
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	d.flush(roomID, room, true)
+}
+
+// sequence numbers a message broadcast to a room and keeps it for replay,
+// reporting whether it was numbered. If the backplane can't hand out a
+// number, the message is still delivered, just without one.
+func (rm *RoomManager) sequence(ctx context.Context, roomID string, msg *Envelope) bool {
+	if unsequenced[msg.Type] {
+		return false
+	}
+
+	seq, err := rm.backplane.NextSequence(ctx, roomID)
+	if err != nil {
+		telemetry.Logger(ctx, rm.logger).Error("Failed to number a room message",
+			logger.Err(err),
+			logger.String("room_id", roomID))
+		return false
+	}
+	msg.RoomID = roomID
+	msg.Seq = seq
+	rm.replay.add(roomID, *msg)
+	return true
+}
+
+// trackDelivery starts ordering a room's messages on this node, from its
+// current sequence number, before the first connection here joins it
+func (rm *RoomManager) trackDelivery(ctx context.Context, roomID string) {
+	if rm.delivery.tracked(roomID) {
+		return
+	}
+	seq, err := rm.backplane.Sequence(ctx, roomID)
+	if err != nil {
+		telemetry.Logger(ctx, rm.logger).Error("Failed to read a room's sequence number",
+			logger.Err(err),
+			logger.String("room_id", roomID))
+		return
+	}
+	rm.delivery.track(roomID, seq)
+}
+
+// Ack records that a user received a room's messages up to seq, the default
+// point a later resume starts from
+func (rm *RoomManager) Ack(ctx context.Context, userID, roomID string, seq int64) error {
+	if !rm.hub.IsMember(userID, roomID) {
+		return errNotInRoom
+	}
+	return rm.backplane.SaveAck(ctx, roomID, userID, seq)
+}
+
+// Resume sends a connection the messages of a room its user is in that came
+// after lastSeq, followed by a resumed message. A negative lastSeq resumes
+// from the user's last ack. If this node no longer holds every missed
+// message, the connection receives a snapshot of the room's current state instead.
+func (rm *RoomManager) Resume(ctx context.Context, client *Client, roomID string, lastSeq int64) error {
+	userID := client.UserID
+	if !rm.hub.IsMember(userID, roomID) {
+		return errNotInRoom
+	}
+
+	if lastSeq < 0 {
+		ack, err := rm.backplane.Ack(ctx, roomID, userID)
+		if err != nil {
+			return err
+		}
+		lastSeq = ack
+	}
+
+	latest, err := rm.backplane.Sequence(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	if lastSeq > latest {
+		// The room was closed and reopened, or the client is confused: start over
+		lastSeq = 0
+	}
+
+	missed, complete := rm.replay.between(roomID, lastSeq, latest)
+	if !complete {
+		return rm.sendSnapshot(ctx, client, roomID, latest)
+	}
+
+	for _, msg := range missed {
+		if !client.enqueue(msg) {
+			return nil
+		}
+	}
+	client.enqueue(reply(MessageTypeResumed, ResumedReply{
+		RoomID:   roomID,
+		Replayed: len(missed),
+		Seq:      latest,
+	}))
+
+	telemetry.Logger(ctx, rm.logger).Info("Room messages replayed",
+		logger.String("user_id", userID),
+		logger.String("room_id", roomID),
+		logger.Int("message_count", len(missed)))
+	return nil
+}
+
+// sendSnapshot sends a connection a room's current members and recent chat
+// messages, as of sequence number seq
+func (rm *RoomManager) sendSnapshot(ctx context.Context, client *Client, roomID string, seq int64) error {
+	room, err := rm.GetRoom(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	messages, err := rm.history.ListBefore(ctx, roomID, 0, rm.historyOnJoin)
+	if err != nil {
+		return err
+	}
+
+	client.enqueue(reply(MessageTypeSnapshot, SnapshotReply{
+		RoomID:   roomID,
+		Seq:      seq,
+		Room:     room,
+		Messages: messages,
+	}))
+
+	telemetry.Logger(ctx, rm.logger).Info("Room snapshot sent instead of a replay",
+		logger.String("user_id", client.UserID),
+		logger.String("room_id", roomID))
+	return nil
+}
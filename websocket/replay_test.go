@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrderedDeliveryQueuesInSequenceOrder(t *testing.T) {
+	var mu sync.Mutex
+	var delivered []int64
+	d := newOrderedDelivery(time.Minute, func(roomID string, msg Envelope) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, msg.Seq)
+	})
+	d.track("room-1", 0)
+
+	// Broadcasts numbered at once reach the room in any order
+	seqs := rand.Perm(100)
+	var wg sync.WaitGroup
+	for _, i := range seqs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.add("room-1", Envelope{Seq: int64(i + 1)})
+		}()
+	}
+	wg.Wait()
+
+	if len(delivered) != len(seqs) {
+		t.Fatalf("delivered %d messages, want %d", len(delivered), len(seqs))
+	}
+	for i, seq := range delivered {
+		if seq != int64(i+1) {
+			t.Fatalf("message %d delivered as number %d: %v", seq, i+1, delivered)
+		}
+	}
+}
+
+func TestOrderedDeliveryGivesUpOnGaps(t *testing.T) {
+	delivered := make(chan int64, 10)
+	d := newOrderedDelivery(20*time.Millisecond, func(roomID string, msg Envelope) {
+		delivered <- msg.Seq
+	})
+	d.track("room-1", 4)
+
+	// 6 waits for 5, which never comes
+	d.add("room-1", Envelope{Seq: 6})
+	select {
+	case seq := <-delivered:
+		t.Fatalf("message %d delivered before the wait", seq)
+	case <-time.After(5 * time.Millisecond):
+	}
+	if seq := <-delivered; seq != 6 {
+		t.Errorf("delivered %d after the wait, want 6", seq)
+	}
+
+	// Rooms nobody here joined aren't held back
+	d.add("room-2", Envelope{Seq: 9})
+	if seq := <-delivered; seq != 9 {
+		t.Errorf("delivered %d for an untracked room, want 9", seq)
+	}
+
+	// Closing a room delivers what it held back
+	d.add("room-1", Envelope{Seq: 8})
+	d.drop("room-1")
+	if seq := <-delivered; seq != 8 {
+		t.Errorf("delivered %d on drop, want 8", seq)
+	}
+}
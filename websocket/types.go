@@ -1,6 +1,10 @@
 package websocket
 
-import "time"
+import (
+	"time"
+
+	"github.com/OkanUysal/go-starter-example-project/models"
+)
 
 // RoomType defines the type of room
 type RoomType string
@@ -46,8 +50,80 @@ const (
 
 	// MessageTypeError for error messages
 	MessageTypeError MessageType = "error"
+
+	// MessageTypeServerShutdown when the server is about to stop
+	MessageTypeServerShutdown MessageType = "server_shutdown"
+
+	// MessageTypeHistory carries a room's recent chat messages to a user who joined it
+	MessageTypeHistory MessageType = "history"
+
+	// MessageTypeDirect is a direct message from one user to another
+	MessageTypeDirect MessageType = "dm"
+
+	// MessageTypeDirectSent confirms to its sender that a direct message was stored
+	MessageTypeDirectSent MessageType = "dm_sent"
+
+	// MessageTypeDirectRead tells a direct message's sender that it was read
+	MessageTypeDirectRead MessageType = "dm_read"
+
+	// MessageTypePresence sets a user's own status, and announces a user's new status
+	MessageTypePresence MessageType = "presence"
+
+	// MessageTypeTypingStart when a user starts typing in a room or to another user
+	MessageTypeTypingStart MessageType = "typing_start"
+
+	// MessageTypeTypingStop when a user stops typing, or their indicator expires
+	MessageTypeTypingStop MessageType = "typing_stop"
+
+	// MessageTypeAck acknowledges a room's messages up to a sequence number
+	MessageTypeAck MessageType = "ack"
+
+	// MessageTypeResume asks for the room messages missed since a sequence number
+	MessageTypeResume MessageType = "resume"
+
+	// MessageTypeResumed ends the replay of missed messages
+	MessageTypeResumed MessageType = "resumed"
+
+	// MessageTypeSnapshot replaces the replay when too many messages were
+	// missed, with the room's current state
+	MessageTypeSnapshot MessageType = "snapshot"
+
+	// MessageTypeCreateRoom asks to create a game room (admin only)
+	MessageTypeCreateRoom MessageType = "create_room"
+
+	// MessageTypeCloseRoom asks to close a game room (admin only)
+	MessageTypeCloseRoom MessageType = "close_room"
+
+	// MessageTypeConnected tells a new connection its ID, the first message it receives
+	MessageTypeConnected MessageType = "connected"
+
+	// MessageTypeKicked tells a connection it is being closed because its user
+	// connected from another device
+	MessageTypeKicked MessageType = "kicked"
+)
+
+// PresenceStatus is whether a user is connected, and whether they are active
+type PresenceStatus string
+
+const (
+	// PresenceOnline is a connected user on at least one connection
+	PresenceOnline PresenceStatus = "online"
+
+	// PresenceAway is a connected user who marked every connection away
+	PresenceAway PresenceStatus = "away"
+
+	// PresenceOffline is a user with no connection
+	PresenceOffline PresenceStatus = "offline"
 )
 
+// Presence is a user's status across all their connections
+type Presence struct {
+	UserID string         `json:"user_id"`
+	Status PresenceStatus `json:"status"`
+	// LastSeen is when an offline user last disconnected, if known
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
 // Message represents a WebSocket message
 type Message struct {
 	Type      MessageType            `json:"type"`
@@ -73,6 +149,57 @@ type RoomInfo struct {
 	AllowedUsers map[string]bool      `json:"allowed_users,omitempty"` // Authorized users (if room auth enabled)
 }
 
+// metadata returns a copy of the room's shared settings, without the member
+// list and player count, which are tracked separately
+func (r *RoomInfo) metadata() *RoomInfo {
+	info := *r
+	info.Users = nil
+	info.PlayerCount = 0
+	if r.AllowedUsers != nil {
+		info.AllowedUsers = make(map[string]bool, len(r.AllowedUsers))
+		for userID, allowed := range r.AllowedUsers {
+			info.AllowedUsers[userID] = allowed
+		}
+	}
+	return &info
+}
+
+// record returns the room as stored in the room repository
+func (r *RoomInfo) record() *models.Room {
+	room := &models.Room{
+		ID:         r.ID,
+		Name:       r.Name,
+		CreatedBy:  r.CreatedBy,
+		MaxPlayers: r.MaxPlayers,
+		IsActive:   r.IsActive,
+		CreatedAt:  r.CreatedAt,
+	}
+	for userID, allowed := range r.AllowedUsers {
+		if allowed {
+			room.Invitations = append(room.Invitations, models.RoomInvitation{RoomID: r.ID, UserID: userID})
+		}
+	}
+	return room
+}
+
+// roomFromRecord returns a game room loaded from the room repository
+func roomFromRecord(room models.Room) *RoomInfo {
+	info := &RoomInfo{
+		ID:           room.ID,
+		Type:         RoomTypeGame,
+		Name:         room.Name,
+		CreatedBy:    room.CreatedBy,
+		CreatedAt:    room.CreatedAt,
+		MaxPlayers:   room.MaxPlayers,
+		IsActive:     room.IsActive,
+		AllowedUsers: make(map[string]bool, len(room.Invitations)),
+	}
+	for _, invitation := range room.Invitations {
+		info.AllowedUsers[invitation.UserID] = true
+	}
+	return info
+}
+
 // UserInfo represents user information in a room
 type UserInfo struct {
 	UserID   string    `json:"user_id"`
@@ -0,0 +1,118 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+// Format is how messages are encoded on a connection
+type Format string
+
+const (
+	// FormatJSON sends messages as JSON text frames (the default)
+	FormatJSON Format = "json"
+
+	// FormatMsgPack sends messages as MessagePack binary frames, smaller and
+	// faster to parse for clients sending frequent small updates
+	FormatMsgPack Format = "msgpack"
+)
+
+// formats are the wire formats clients may ask for
+var formats = []Format{FormatJSON, FormatMsgPack}
+
+// errUnsupportedFormat is returned when a client asks for an unknown wire format
+var errUnsupportedFormat = errors.New("unsupported format, supported: json, msgpack")
+
+// defaultCompressionMinSize is the smallest message compressed when compression is enabled
+const defaultCompressionMinSize = 256
+
+// msgpackHandle encodes messages with their JSON field names. Maps decode as
+// map[string]interface{} and strings as strings, as JSON would, so requests
+// are validated the same way in either format.
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	return h
+}()
+
+// parseFormat returns the wire format a client named
+func parseFormat(name string) (Format, error) {
+	for _, format := range formats {
+		if Format(name) == format {
+			return format, nil
+		}
+	}
+	return "", errUnsupportedFormat
+}
+
+// encodeMessage encodes a message in a wire format
+func encodeMessage(msg Envelope, format Format) ([]byte, error) {
+	if format == FormatMsgPack {
+		var data []byte
+		err := codec.NewEncoderBytes(&data, msgpackHandle).Encode(msg)
+		return data, err
+	}
+	return json.Marshal(msg)
+}
+
+// decodeMessage decodes an inbound frame: text frames are JSON, binary frames MessagePack
+func decodeMessage(frameType int, data []byte, msg *Envelope) error {
+	if frameType == websocket.BinaryMessage {
+		return codec.NewDecoderBytes(data, msgpackHandle).Decode(msg)
+	}
+	return json.Unmarshal(data, msg)
+}
+
+// frame is a message encoded for connections of one format. Compressing it,
+// for connections that negotiated compression, is also done only once.
+type frame struct {
+	msgType  string
+	size     int
+	prepared *websocket.PreparedMessage
+}
+
+// newFrame encodes a message for connections of a format
+func newFrame(msg Envelope, format Format) (*frame, error) {
+	data, err := encodeMessage(msg, format)
+	if err != nil {
+		return nil, err
+	}
+	frameType := websocket.TextMessage
+	if format == FormatMsgPack {
+		frameType = websocket.BinaryMessage
+	}
+	prepared, err := websocket.NewPreparedMessage(frameType, data)
+	if err != nil {
+		return nil, err
+	}
+	return &frame{msgType: msg.Type, size: len(data), prepared: prepared}, nil
+}
+
+// frames encodes a message broadcast to several clients once per format
+// among them, rather than once per recipient
+type frames struct {
+	msg     Envelope
+	encoded map[Format]*frame
+}
+
+func newFrames(msg Envelope) *frames {
+	return &frames{msg: msg, encoded: make(map[Format]*frame, len(formats))}
+}
+
+// get returns the message encoded in a format, encoding it on first use
+func (f *frames) get(format Format) (*frame, error) {
+	if encoded, done := f.encoded[format]; done {
+		return encoded, nil
+	}
+	encoded, err := newFrame(f.msg, format)
+	if err != nil {
+		return nil, err
+	}
+	f.encoded[format] = encoded
+	return encoded, nil
+}
@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMessagePackRoundTrip(t *testing.T) {
+	sent := Envelope{
+		Type:   string(MessageTypeChat),
+		RoomID: "r1",
+		Seq:    7,
+		Data: map[string]interface{}{
+			"content":   "hi",
+			"timestamp": time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			"nested":    map[string]interface{}{"x": 1},
+		},
+	}
+
+	data, err := encodeMessage(sent, FormatMsgPack)
+	if err != nil {
+		t.Fatalf("encodeMessage: %v", err)
+	}
+	text, err := encodeMessage(sent, FormatJSON)
+	if err != nil {
+		t.Fatalf("encodeMessage: %v", err)
+	}
+	if len(data) >= len(text) {
+		t.Errorf("MessagePack is %d bytes, JSON %d; want it smaller", len(data), len(text))
+	}
+
+	var got Envelope
+	if err := decodeMessage(websocket.BinaryMessage, data, &got); err != nil {
+		t.Fatalf("decodeMessage: %v", err)
+	}
+	if got.Type != sent.Type || got.RoomID != "r1" || got.Seq != 7 || got.Data["content"] != "hi" {
+		t.Errorf("decoded %+v, want %+v", got, sent)
+	}
+	// Nested maps decode with string keys, so requests validate as they do from JSON
+	if _, ok := got.Data["nested"].(map[string]interface{}); !ok {
+		t.Errorf("nested map decoded as %T", got.Data["nested"])
+	}
+
+	var req JoinRequest
+	if err := decodeRequest(map[string]interface{}{"room_id": got.RoomID}, &req); err != nil || req.RoomID != "r1" {
+		t.Errorf("decodeRequest = %+v, %v", req, err)
+	}
+}
+
+func TestFramesEncodeOncePerFormat(t *testing.T) {
+	encoded := newFrames(Envelope{Type: string(MessageTypeChat), Data: map[string]interface{}{"content": "hi"}})
+
+	first, err := encoded.get(FormatMsgPack)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	again, _ := encoded.get(FormatMsgPack)
+	asJSON, _ := encoded.get(FormatJSON)
+	if first != again {
+		t.Error("the same format was encoded twice")
+	}
+	if asJSON == first || asJSON.size == first.size {
+		t.Error("JSON and MessagePack share a frame")
+	}
+}